@@ -2,12 +2,19 @@ package abs
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
 )
 
 type Client struct {
@@ -25,7 +32,7 @@ func New(clientID, clientSecret, tenantID string) (*Client, error) {
 	}, nil
 }
 
-func (x *Client) NewReader(ctx context.Context, storageAccountName, containerName, blobName string) (io.ReadCloser, error) {
+func (x *Client) NewReader(ctx context.Context, storageAccountName, containerName, blobName, etag string) (io.ReadCloser, error) {
 	accountUrl := fmt.Sprintf("https://%s.blob.core.windows.net/", storageAccountName)
 
 	serviceClient, err := azblob.NewClient(accountUrl, x.cred, nil)
@@ -33,7 +40,18 @@ func (x *Client) NewReader(ctx context.Context, storageAccountName, containerNam
 		return nil, goerr.Wrap(err, "fail to create service client").With("accountUrl", accountUrl)
 	}
 
-	stream, err := serviceClient.DownloadStream(ctx, containerName, blobName, &azblob.DownloadStreamOptions{})
+	var options azblob.DownloadStreamOptions
+	if etag != "" {
+		ifMatch := azcore.ETag(etag)
+		options.AccessConditions = &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfMatch: &ifMatch},
+		}
+	}
+
+	stream, err := serviceClient.DownloadStream(ctx, containerName, blobName, &options)
+	if etag != "" && bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobNotFound) {
+		return nil, goerr.Wrap(model.ErrSourceChanged, "blob has been changed").With("containerName", containerName).With("blobName", blobName).With("etag", etag).With("error", err)
+	}
 	if err != nil {
 		return nil, goerr.Wrap(err, "fail to download stream").With("containerName", containerName).With("blobName", blobName).With("accountUrl", accountUrl)
 	}
@@ -58,7 +76,7 @@ func (x *pipeWriter) Close() error {
 	return <-x.errCh
 }
 
-func (x *Client) NewWriter(ctx context.Context, storageAccountName, containerName, blobName string) (io.WriteCloser, error) {
+func (x *Client) NewWriter(ctx context.Context, storageAccountName, containerName, blobName string, attrs *model.ObjectAttrs, opts *model.AzureBlobStorageWriteOptions) (io.WriteCloser, error) {
 	accountUrl := fmt.Sprintf("https://%s.blob.core.windows.net/", storageAccountName)
 
 	serviceClient, err := azblob.NewClient(accountUrl, x.cred, nil)
@@ -66,7 +84,43 @@ func (x *Client) NewWriter(ctx context.Context, storageAccountName, containerNam
 		return nil, goerr.Wrap(err, "fail to create service client").With("accountUrl", accountUrl)
 	}
 
-	errCh := make(chan error)
+	options := azblob.UploadStreamOptions{
+		// Service verifies CRC64 of each block
+		TransactionalValidation: blob.TransferValidationTypeComputeCRC64(),
+	}
+	if attrs != nil {
+		options.HTTPHeaders = &blob.HTTPHeaders{
+			BlobContentType:        optionalString(attrs.ContentType),
+			BlobContentEncoding:    optionalString(attrs.ContentEncoding),
+			BlobCacheControl:       optionalString(attrs.CacheControl),
+			BlobContentDisposition: optionalString(attrs.ContentDisposition),
+			BlobContentLanguage:    optionalString(attrs.ContentLanguage),
+			// Content-MD5 of committed blob is stored as is, because blocks are committed without verification of the whole content
+			BlobContentMD5: attrs.MD5,
+		}
+		// Keys of metadata must be converted by ObjectAttrs.ForStorage in advance
+		if len(attrs.Metadata) > 0 {
+			options.Metadata = make(map[string]*string, len(attrs.Metadata))
+			for k, v := range attrs.Metadata {
+				options.Metadata[k] = &v
+			}
+		}
+	}
+	if opts != nil {
+		if opts.AccessTier != "" {
+			tier := blob.AccessTier(opts.AccessTier)
+			options.AccessTier = &tier
+		}
+		if opts.EncryptionScope != "" {
+			options.CPKScopeInfo = &blob.CPKScopeInfo{EncryptionScope: &opts.EncryptionScope}
+		}
+		if opts.CustomerKey != nil {
+			options.CPKInfo = cpkInfo(opts.CustomerKey)
+		}
+		options.Tags = opts.Tags
+	}
+
+	errCh := make(chan error, 1)
 	r, w := io.Pipe()
 
 	writer := &pipeWriter{
@@ -77,15 +131,169 @@ func (x *Client) NewWriter(ctx context.Context, storageAccountName, containerNam
 	go func() {
 		defer close(errCh)
 
-		if _, err := serviceClient.UploadStream(ctx, containerName, blobName, r, nil); err != nil {
+		if _, err := serviceClient.UploadStream(ctx, containerName, blobName, r, &options); err != nil {
 			errCh <- goerr.Wrap(err, "fail to create writer").With("containerName", containerName).With("blobName", blobName).With("accountUrl", accountUrl)
+			_ = r.CloseWithError(err)
 			return
 		}
 
 		if err := r.Close(); err != nil {
 			errCh <- goerr.Wrap(err, "fail to close reader").With("containerName", containerName).With("blobName", blobName).With("accountUrl", accountUrl)
+			return
+		}
+
+		if opts != nil {
+			blobClient := serviceClient.ServiceClient().NewContainerClient(containerName).NewBlobClient(blobName)
+			if err := setImmutability(ctx, blobClient, opts); err != nil {
+				errCh <- goerr.Wrap(model.ErrImmutabilityNotSet, "fail to set immutability of blob").With("error", err).With("containerName", containerName).With("blobName", blobName).With("accountUrl", accountUrl)
+			}
 		}
 	}()
 
 	return writer, nil
 }
+
+func (x *Client) List(ctx context.Context, storageAccountName, containerName, prefix, startAfter string, fn func(*model.ObjectInfo) error) error {
+	accountUrl := fmt.Sprintf("https://%s.blob.core.windows.net/", storageAccountName)
+
+	serviceClient, err := azblob.NewClient(accountUrl, x.cred, nil)
+	if err != nil {
+		return goerr.Wrap(err, "fail to create service client").With("accountUrl", accountUrl)
+	}
+
+	pager := serviceClient.NewListBlobsFlatPager(containerName, &azblob.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return goerr.Wrap(err, "fail to list blobs").With("containerName", containerName).With("prefix", prefix).With("accountUrl", accountUrl)
+		}
+
+		for _, item := range page.Segment.BlobItems {
+			// Blob Storage has no option to start listing after a name, then blobs are skipped here
+			if startAfter != "" && *item.Name <= startAfter {
+				continue
+			}
+
+			info := &model.ObjectInfo{
+				Name: *item.Name,
+			}
+			if props := item.Properties; props != nil {
+				if props.ContentLength != nil {
+					info.Size = *props.ContentLength
+				}
+				if props.ETag != nil {
+					info.ETag = string(*props.ETag)
+				}
+				if props.LastModified != nil {
+					info.UpdatedAt = *props.LastModified
+				}
+			}
+
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (x *Client) Stat(ctx context.Context, storageAccountName, containerName, blobName string, customerKey []byte) (*model.ObjectStat, error) {
+	accountUrl := fmt.Sprintf("https://%s.blob.core.windows.net/", storageAccountName)
+
+	serviceClient, err := azblob.NewClient(accountUrl, x.cred, nil)
+	if err != nil {
+		return nil, goerr.Wrap(err, "fail to create service client").With("accountUrl", accountUrl)
+	}
+
+	// Properties of a blob encrypted by customer-provided key can not be read without the key
+	var options blob.GetPropertiesOptions
+	if customerKey != nil {
+		options.CPKInfo = cpkInfo(customerKey)
+	}
+
+	props, err := serviceClient.ServiceClient().NewContainerClient(containerName).NewBlobClient(blobName).GetProperties(ctx, &options)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, goerr.Wrap(model.ErrObjectNotFound, "blob not found").With("containerName", containerName).With("blobName", blobName).With("accountUrl", accountUrl)
+	}
+	if err != nil {
+		return nil, goerr.Wrap(err, "fail to get blob properties").With("containerName", containerName).With("blobName", blobName).With("accountUrl", accountUrl)
+	}
+
+	stat := &model.ObjectStat{
+		Metadata: make(map[string]string, len(props.Metadata)),
+	}
+	// Content-MD5 is available only if it is set at upload
+	if len(props.ContentMD5) > 0 {
+		stat.MD5 = props.ContentMD5
+	}
+	if props.ContentLength != nil {
+		stat.Size = *props.ContentLength
+	}
+	if props.ETag != nil {
+		stat.ETag = string(*props.ETag)
+	}
+	if props.ContentType != nil {
+		stat.ContentType = *props.ContentType
+	}
+	if props.ContentEncoding != nil {
+		stat.ContentEncoding = *props.ContentEncoding
+	}
+	if props.CacheControl != nil {
+		stat.CacheControl = *props.CacheControl
+	}
+	if props.ContentDisposition != nil {
+		stat.ContentDisposition = *props.ContentDisposition
+	}
+	if props.ContentLanguage != nil {
+		stat.ContentLanguage = *props.ContentLanguage
+	}
+	if props.LastModified != nil {
+		stat.UpdatedAt = *props.LastModified
+	}
+	for k, v := range props.Metadata {
+		if v != nil {
+			stat.Metadata[k] = *v
+		}
+	}
+
+	return stat, nil
+}
+
+// setImmutability sets immutability policy and legal hold to the uploaded blob, because they can not be set by UploadStream. Version-level immutability must be enabled for the container.
+func setImmutability(ctx context.Context, blobClient *blob.Client, opts *model.AzureBlobStorageWriteOptions) error {
+	if opts.Retention != nil {
+		mode := blob.ImmutabilityPolicySetting(opts.Retention.Mode)
+		if _, err := blobClient.SetImmutabilityPolicy(ctx, opts.Retention.RetainUntil(time.Now()), &blob.SetImmutabilityPolicyOptions{Mode: &mode}); err != nil {
+			return goerr.Wrap(err, "fail to set immutability policy").With("retention", opts.Retention)
+		}
+	}
+	if opts.LegalHold {
+		if _, err := blobClient.SetLegalHold(ctx, true, nil); err != nil {
+			return goerr.Wrap(err, "fail to set legal hold")
+		}
+	}
+	return nil
+}
+
+// cpkInfo returns customer-provided key information of request
+func cpkInfo(key []byte) *blob.CPKInfo {
+	sum := sha256.Sum256(key)
+	algorithm := blob.EncryptionAlgorithmTypeAES256
+	return &blob.CPKInfo{
+		EncryptionAlgorithm: &algorithm,
+		EncryptionKey:       optionalString(base64.StdEncoding.EncodeToString(key)),
+		EncryptionKeySHA256: optionalString(base64.StdEncoding.EncodeToString(sum[:])),
+	}
+}
+
+// optionalString returns nil if s is empty, because empty header value overwrites the default one
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
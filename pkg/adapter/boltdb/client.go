@@ -0,0 +1,277 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	jobBucket      = []byte("jobs")
+	transferBucket = []byte("transfers")
+)
+
+// Client is a job store and a dedup store backed by a bbolt database file
+type Client struct {
+	db          *bolt.DB
+	readOnly    bool
+	timeout     time.Duration
+	transferTTL time.Duration
+}
+
+type Option func(*Client)
+
+// WithReadOnly opens the database in read-only mode. The database can not be opened while another process opens it in read-write mode.
+func WithReadOnly() Option {
+	return func(c *Client) {
+		c.readOnly = true
+	}
+}
+
+// WithTimeout sets timeout to obtain lock of the database file. Default is 1 second.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithTransferTTL sets retention period of completed transfer records of dedup store. Expired records are deleted when the database is opened. Records never expire if 0.
+func WithTransferTTL(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.transferTTL = ttl
+	}
+}
+
+func New(path string, options ...Option) (*Client, error) {
+	c := &Client{
+		timeout: time.Second,
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{
+		Timeout:  c.timeout,
+		ReadOnly: c.readOnly,
+	})
+	if err != nil {
+		return nil, goerr.Wrap(err, "fail to open job store").With("path", path)
+	}
+	c.db = db
+
+	if !c.readOnly {
+		if err := db.Update(func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{jobBucket, transferBucket} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			_ = db.Close()
+			return nil, goerr.Wrap(err, "fail to create bucket").With("path", path)
+		}
+
+		if err := c.deleteExpiredTransfers(); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (x *Client) Close() error {
+	if err := x.db.Close(); err != nil {
+		return goerr.Wrap(err, "fail to close job store")
+	}
+	return nil
+}
+
+func (x *Client) PutJob(ctx context.Context, job *model.Job) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return goerr.Wrap(err, "fail to marshal job").With("id", job.ID)
+	}
+
+	if err := x.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobBucket).Put([]byte(job.ID), raw)
+	}); err != nil {
+		return goerr.Wrap(err, "fail to put job").With("id", job.ID)
+	}
+
+	return nil
+}
+
+func (x *Client) GetJob(ctx context.Context, id model.JobID) (*model.Job, error) {
+	var job *model.Job
+	if err := x.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(jobBucket)
+		if b == nil {
+			return nil
+		}
+		raw := b.Get([]byte(id))
+		if raw == nil {
+			return nil
+		}
+
+		job = &model.Job{}
+		return json.Unmarshal(raw, job)
+	}); err != nil {
+		return nil, goerr.Wrap(err, "fail to get job").With("id", id)
+	}
+
+	if job == nil {
+		return nil, goerr.Wrap(model.ErrJobNotFound, "fail to get job").With("id", id)
+	}
+	return job, nil
+}
+
+func (x *Client) ListJobs(ctx context.Context, states ...model.JobState) ([]*model.Job, error) {
+	var jobs []*model.Job
+	if err := x.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(jobBucket)
+		if b == nil {
+			return nil
+		}
+
+		// Keys are sorted in order of creation because job ID is UUID v7
+		return b.ForEach(func(k, v []byte) error {
+			var job model.Job
+			if err := json.Unmarshal(v, &job); err != nil {
+				return goerr.Wrap(err, "fail to unmarshal job").With("id", string(k))
+			}
+			if len(states) == 0 || slices.Contains(states, job.State) {
+				jobs = append(jobs, &job)
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, goerr.Wrap(err, "fail to list jobs")
+	}
+
+	return jobs, nil
+}
+
+func (x *Client) DeleteJobs(ctx context.Context, before time.Time) (int, error) {
+	var deleted int
+	if err := x.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(jobBucket)
+
+		var keys [][]byte
+		if err := b.ForEach(func(k, v []byte) error {
+			var job model.Job
+			if err := json.Unmarshal(v, &job); err != nil {
+				return goerr.Wrap(err, "fail to unmarshal job").With("id", string(k))
+			}
+			if job.State.Finished() && job.UpdatedAt.Before(before) {
+				keys = append(keys, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// Bucket must not be modified in ForEach
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		deleted = len(keys)
+		return nil
+	}); err != nil {
+		return 0, goerr.Wrap(err, "fail to delete jobs").With("before", before)
+	}
+
+	return deleted, nil
+}
+
+// transferRecord is a completed transfer in dedup store
+type transferRecord struct {
+	Key         *model.TransferKey `json:"key"`
+	CompletedAt time.Time          `json:"completed_at"`
+}
+
+func (x *Client) expired(record *transferRecord) bool {
+	return x.transferTTL > 0 && time.Since(record.CompletedAt) > x.transferTTL
+}
+
+func (x *Client) HasTransfer(ctx context.Context, key *model.TransferKey) (bool, error) {
+	var found bool
+	if err := x.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(transferBucket)
+		if b == nil {
+			return nil
+		}
+		raw := b.Get([]byte(key.ID()))
+		if raw == nil {
+			return nil
+		}
+
+		var record transferRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return err
+		}
+		found = !x.expired(&record)
+		return nil
+	}); err != nil {
+		return false, goerr.Wrap(err, "fail to get transfer record").With("key", key)
+	}
+
+	return found, nil
+}
+
+func (x *Client) PutTransfer(ctx context.Context, key *model.TransferKey) error {
+	raw, err := json.Marshal(&transferRecord{Key: key, CompletedAt: time.Now().UTC()})
+	if err != nil {
+		return goerr.Wrap(err, "fail to marshal transfer record").With("key", key)
+	}
+
+	if err := x.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(transferBucket).Put([]byte(key.ID()), raw)
+	}); err != nil {
+		return goerr.Wrap(err, "fail to put transfer record").With("key", key)
+	}
+
+	return nil
+}
+
+func (x *Client) deleteExpiredTransfers() error {
+	if x.transferTTL <= 0 {
+		return nil
+	}
+
+	if err := x.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(transferBucket)
+
+		var keys [][]byte
+		if err := b.ForEach(func(k, v []byte) error {
+			var record transferRecord
+			// Broken record is deleted as well
+			if err := json.Unmarshal(v, &record); err != nil || x.expired(&record) {
+				keys = append(keys, k)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// Bucket must not be modified in ForEach
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return goerr.Wrap(err, "fail to delete expired transfer records")
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package adapter
 
 import (
 	"net/http"
+	"sync/atomic"
 
 	"github.com/m-mizutani/opac"
 	"github.com/secmon-lab/nydus/pkg/domain/interfaces"
@@ -9,15 +10,22 @@ import (
 
 type Clients struct {
 	httpClient interfaces.HTTPClient
-	query      *opac.Client
+	policy     atomic.Pointer[policy]
 
 	gcsClient interfaces.GoogleCloudStorage
 	absClient interfaces.AzureBlobStorage
 	s3Client  interfaces.AmazonS3
+
+	jobStore interfaces.JobStore
+}
+
+// policy is a compiled route policy and its version
+type policy struct {
+	query   *opac.Client
+	version string
 }
 
 func (x *Clients) HTTPClient() interfaces.HTTPClient { return x.httpClient }
-func (x *Clients) Query() *opac.Client               { return x.query }
 func (x *Clients) GoogleCloudStorage() interfaces.GoogleCloudStorage {
 	return x.gcsClient
 }
@@ -25,6 +33,21 @@ func (x *Clients) AzureBlobStorage() interfaces.AzureBlobStorage {
 	return x.absClient
 }
 func (x *Clients) AmazonS3() interfaces.AmazonS3 { return x.s3Client }
+func (x *Clients) JobStore() interfaces.JobStore { return x.jobStore }
+
+// Policy returns route policy client and its version together. Version is empty if it is not set.
+func (x *Clients) Policy() (*opac.Client, string) {
+	p := x.policy.Load()
+	if p == nil {
+		return nil, ""
+	}
+	return p.query, p.version
+}
+
+// SetPolicy replaces route policy atomically. Queries in progress continue with the previous policy.
+func (x *Clients) SetPolicy(query *opac.Client, version string) {
+	x.policy.Store(&policy{query: query, version: version})
+}
 
 func New(options ...Option) *Clients {
 	clients := &Clients{
@@ -48,7 +71,7 @@ func WithHTTPClient(httpClient interfaces.HTTPClient) Option {
 
 func WithPolicy(query *opac.Client) Option {
 	return func(c *Clients) {
-		c.query = query
+		c.SetPolicy(query, "")
 	}
 }
 
@@ -69,3 +92,9 @@ func WithAmazonS3(client interfaces.AmazonS3) Option {
 		c.s3Client = client
 	}
 }
+
+func WithJobStore(store interfaces.JobStore) Option {
+	return func(c *Clients) {
+		c.jobStore = store
+	}
+}
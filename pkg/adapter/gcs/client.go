@@ -2,10 +2,14 @@ package gcs
 
 import (
 	"context"
+	"errors"
 	"io"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
@@ -41,8 +45,17 @@ func WithGoogleAPIOption(opts ...option.ClientOption) Option {
 	}
 }
 
-func (x *Client) NewReader(ctx context.Context, bucket, object string) (io.ReadCloser, error) {
-	reader, err := x.client.Bucket(bucket).Object(object).NewReader(ctx)
+func (x *Client) NewReader(ctx context.Context, bucket, object string, generation int64) (io.ReadCloser, error) {
+	obj := x.client.Bucket(bucket).Object(object)
+	if generation != 0 {
+		obj = obj.Generation(generation)
+	}
+
+	// Read content as stored without decompressive transcoding of gzip encoded object, because Content-Encoding is preserved in destination
+	reader, err := obj.ReadCompressed(true).NewReader(ctx)
+	if generation != 0 && errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, goerr.Wrap(model.ErrSourceChanged, "generation of object no longer exists").With("bucket", bucket).With("object", object).With("generation", generation)
+	}
 	if err != nil {
 		return nil, goerr.Wrap(err, "fail to create reader").With("bucket", bucket).With("object", object)
 	}
@@ -50,7 +63,100 @@ func (x *Client) NewReader(ctx context.Context, bucket, object string) (io.ReadC
 	return reader, nil
 }
 
-func (x *Client) NewWriter(ctx context.Context, bucket, object string) (io.WriteCloser, error) {
-	writer := x.client.Bucket(bucket).Object(object).NewWriter(ctx)
+func (x *Client) NewWriter(ctx context.Context, bucket, object string, attrs *model.ObjectAttrs, opts *model.GoogleCloudStorageWriteOptions) (io.WriteCloser, error) {
+	obj := x.client.Bucket(bucket).Object(object)
+	if opts != nil && opts.CustomerKey != nil {
+		obj = obj.Key(opts.CustomerKey)
+	}
+	writer := obj.NewWriter(ctx)
+	if attrs != nil {
+		writer.ContentType = attrs.ContentType
+		writer.ContentEncoding = attrs.ContentEncoding
+		writer.CacheControl = attrs.CacheControl
+		writer.ContentDisposition = attrs.ContentDisposition
+		writer.ContentLanguage = attrs.ContentLanguage
+		writer.Metadata = attrs.Metadata
+
+		// Upload fails on Close if the content does not match with the checksums
+		writer.MD5 = attrs.MD5
+		if attrs.CRC32C != nil {
+			writer.CRC32C = *attrs.CRC32C
+			writer.SendCRC32C = true
+		}
+	}
+	if opts != nil {
+		writer.StorageClass = opts.StorageClass
+		writer.KMSKeyName = opts.KMSKeyName
+		writer.PredefinedACL = opts.PredefinedACL
+		writer.TemporaryHold = opts.TemporaryHold
+		writer.EventBasedHold = opts.EventBasedHold
+		if opts.Retention != nil {
+			writer.Retention = &storage.ObjectRetention{
+				Mode:        opts.Retention.Mode,
+				RetainUntil: opts.Retention.RetainUntil(time.Now()),
+			}
+		}
+	}
 	return writer, nil
 }
+
+func (x *Client) List(ctx context.Context, bucket, prefix, startAfter string, fn func(*model.ObjectInfo) error) error {
+	// StartOffset is inclusive, so the object of startAfter itself is skipped below
+	it := x.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix, StartOffset: startAfter})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return goerr.Wrap(err, "fail to list objects").With("bucket", bucket).With("prefix", prefix).With("startAfter", startAfter)
+		}
+		if startAfter != "" && attrs.Name == startAfter {
+			continue
+		}
+
+		if err := fn(&model.ObjectInfo{
+			Name:      attrs.Name,
+			Size:      attrs.Size,
+			ETag:      attrs.Etag,
+			UpdatedAt: attrs.Updated,
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func (x *Client) Stat(ctx context.Context, bucket, object string, customerKey []byte) (*model.ObjectStat, error) {
+	obj := x.client.Bucket(bucket).Object(object)
+	// Checksums of an object encrypted by customer-supplied key are not returned without the key
+	if customerKey != nil {
+		obj = obj.Key(customerKey)
+	}
+
+	attrs, err := obj.Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, goerr.Wrap(model.ErrObjectNotFound, "object not found").With("bucket", bucket).With("object", object)
+	}
+	if err != nil {
+		return nil, goerr.Wrap(err, "fail to get object attributes").With("bucket", bucket).With("object", object)
+	}
+
+	// CRC32C is always available in GCS, but MD5 is not for composite objects
+	crc32c := attrs.CRC32C
+	stat := &model.ObjectStat{
+		Size:               attrs.Size,
+		ETag:               attrs.Etag,
+		CRC32C:             &crc32c,
+		ContentType:        attrs.ContentType,
+		ContentEncoding:    attrs.ContentEncoding,
+		CacheControl:       attrs.CacheControl,
+		ContentDisposition: attrs.ContentDisposition,
+		ContentLanguage:    attrs.ContentLanguage,
+		Metadata:           attrs.Metadata,
+		UpdatedAt:          attrs.Updated,
+	}
+	if len(attrs.MD5) > 0 {
+		stat.MD5 = attrs.MD5
+	}
+	return stat, nil
+}
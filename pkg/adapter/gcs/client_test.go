@@ -2,6 +2,7 @@ package gcs_test
 
 import (
 	"context"
+	"crypto/md5"
 	"io"
 	"os"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/m-mizutani/gt"
 	"github.com/secmon-lab/nydus/pkg/adapter/gcs"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
 )
 
 func TestIntegration(t *testing.T) {
@@ -24,14 +26,15 @@ func TestIntegration(t *testing.T) {
 	objectKey := time.Now().Format("nydus-test/2006/01/02/15/")
 	objectName := objectKey + uuid.NewString() + ".txt"
 
-	// Write object
-	w, err := client.NewWriter(ctx, bucketName, objectName)
+	// Write object with expected checksum
+	sum := md5.Sum([]byte("timeless words"))
+	w, err := client.NewWriter(ctx, bucketName, objectName, &model.ObjectAttrs{MD5: sum[:]}, nil)
 	gt.NoError(t, err)
 	gt.R1(w.Write([]byte("timeless words"))).NoError(t)
 	gt.NoError(t, w.Close())
 
 	// Read object
-	r, err := client.NewReader(ctx, bucketName, objectName)
+	r, err := client.NewReader(ctx, bucketName, objectName, 0)
 	gt.NoError(t, err)
 	buf := gt.R1(io.ReadAll(r)).NoError(t)
 	gt.NoError(t, r.Close())
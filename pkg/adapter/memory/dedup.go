@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// DedupStore is an in-memory dedup store. The least recently used record is evicted if the number of records exceeds the size. Records are lost on restart.
+type DedupStore struct {
+	mutex   sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	records map[string]*list.Element
+}
+
+type dedupRecord struct {
+	id          string
+	completedAt time.Time
+}
+
+// NewDedupStore creates in-memory dedup store that has up to size records. Records expire after ttl, or never expire if ttl is 0.
+func NewDedupStore(size int, ttl time.Duration) *DedupStore {
+	return &DedupStore{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		records: map[string]*list.Element{},
+	}
+}
+
+func (x *DedupStore) HasTransfer(ctx context.Context, key *model.TransferKey) (bool, error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	elem, ok := x.records[key.ID()]
+	if !ok {
+		return false, nil
+	}
+
+	record := elem.Value.(*dedupRecord)
+	if x.ttl > 0 && time.Since(record.completedAt) > x.ttl {
+		x.order.Remove(elem)
+		delete(x.records, record.id)
+		return false, nil
+	}
+
+	x.order.MoveToFront(elem)
+	return true, nil
+}
+
+func (x *DedupStore) PutTransfer(ctx context.Context, key *model.TransferKey) error {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	id := key.ID()
+	if elem, ok := x.records[id]; ok {
+		elem.Value.(*dedupRecord).completedAt = time.Now()
+		x.order.MoveToFront(elem)
+		return nil
+	}
+
+	x.records[id] = x.order.PushFront(&dedupRecord{id: id, completedAt: time.Now()})
+	for x.size > 0 && x.order.Len() > x.size {
+		oldest := x.order.Back()
+		x.order.Remove(oldest)
+		delete(x.records, oldest.Value.(*dedupRecord).id)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"bytes"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/m-mizutani/opac"
+	"github.com/open-policy-agent/opa/bundle"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// ReadBundle reads OPA bundle (gzipped tarball) and compiles Rego modules in it. It returns the policy client and version of the policy in the same form as Load. The bundle must be signed by key, and signature is not verified only if key is nil and insecure is true. Data documents in the bundle are not supported.
+func ReadBundle(raw []byte, key *model.PolicyBundleKey, insecure bool) (*opac.Client, string, error) {
+	reader := bundle.NewReader(bytes.NewReader(raw))
+	switch {
+	case key != nil:
+		algorithm := key.Algorithm
+		if algorithm == "" {
+			algorithm = "RS256"
+		}
+		keyConfig := &bundle.KeyConfig{Key: key.Key, Algorithm: algorithm, Scope: key.Scope}
+		reader = reader.WithBundleVerificationConfig(bundle.NewVerificationConfig(
+			map[string]*bundle.KeyConfig{key.KeyID: keyConfig},
+			key.KeyID, key.Scope, nil,
+		))
+	case insecure:
+		reader = reader.WithSkipBundleVerification(true)
+	default:
+		return nil, "", goerr.New("verification key of policy bundle is not configured")
+	}
+
+	b, err := reader.Read()
+	if err != nil {
+		return nil, "", goerr.Wrap(err, "fail to read policy bundle")
+	}
+	if len(b.Data) > 0 {
+		return nil, "", goerr.New("data documents in policy bundle are not supported").With("revision", b.Manifest.Revision)
+	}
+
+	policies := map[string]string{}
+	for _, module := range b.Modules {
+		policies[module.Path] = string(module.Raw)
+	}
+
+	client, err := opac.New(opac.Data(policies))
+	if err != nil {
+		return nil, "", goerr.Wrap(err, "fail to compile policy bundle").With("revision", b.Manifest.Revision)
+	}
+
+	return client, Version(policies), nil
+}
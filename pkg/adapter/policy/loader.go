@@ -0,0 +1,110 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+)
+
+// ReadDir reads policy files (*.rego) in the directory recursively. Key of the map is slash separated path relative to dir, and value is content of the file.
+func ReadDir(dir string) (map[string]string, error) {
+	policies := map[string]string{}
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".rego" {
+			return nil
+		}
+
+		raw, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return goerr.Wrap(err, "fail to read policy file").With("path", path)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return goerr.Wrap(err, "fail to get relative path of policy file").With("path", path)
+		}
+
+		policies[filepath.ToSlash(rel)] = string(raw)
+		return nil
+	}); err != nil {
+		return nil, goerr.Wrap(err, "fail to walk policy directory").With("dir", dir)
+	}
+
+	return policies, nil
+}
+
+// Version returns hex encoded SHA256 hash of the policy files. It changes if any file is added, removed, renamed or modified.
+func Version(policies map[string]string) string {
+	h := sha256.New()
+	for _, name := range slices.Sorted(maps.Keys(policies)) {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(policies[name]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Load reads and compiles policy files in the directory. It returns the policy client and version of the policy.
+func Load(dir string) (*opac.Client, string, error) {
+	policies, err := ReadDir(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := opac.New(opac.Data(policies))
+	if err != nil {
+		return nil, "", goerr.Wrap(err, "fail to compile policy files").With("dir", dir)
+	}
+
+	return client, Version(policies), nil
+}
+
+// Watch checks policy files in the directory every interval until ctx is canceled. If version of the files is changed from current and the files are compiled successfully, fn is called with the new policy client and version. Otherwise the current policy is kept.
+func Watch(ctx context.Context, dir string, interval time.Duration, current string, fn func(*opac.Client, string)) {
+	logger := logging.From(ctx).With("dir", dir)
+	var failed string // version of files that failed to compile
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		policies, err := ReadDir(dir)
+		if err != nil {
+			logger.Warn("Failed to read policy files, keep current policy", "error", err, "version", current)
+			continue
+		}
+		version := Version(policies)
+		if version == current || version == failed {
+			continue
+		}
+
+		client, err := opac.New(opac.Data(policies))
+		if err != nil {
+			logger.Warn("Failed to compile updated policy files, keep current policy", "error", err, "version", current, "new_version", version)
+			failed = version
+			continue
+		}
+
+		logger.Info("Policy is reloaded", "version", version, "previous_version", current)
+		current, failed = version, ""
+		fn(client, version)
+	}
+}
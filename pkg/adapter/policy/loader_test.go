@@ -0,0 +1,118 @@
+package policy_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter/policy"
+)
+
+const policyV1 = `package route
+
+gcs[dst] {
+	dst := {"bucket": "bucket-v1", "name": input.gcs.object.name}
+}
+`
+
+const policyV2 = `package route
+
+gcs[dst] {
+	dst := {"bucket": "bucket-v2", "name": input.gcs.object.name}
+}
+`
+
+func queryBucket(t *testing.T, client *opac.Client) string {
+	var output struct {
+		GCS []struct {
+			Bucket string `json:"bucket"`
+		} `json:"gcs"`
+	}
+	input := map[string]any{"gcs": map[string]any{"object": map[string]any{"name": "blue.txt"}}}
+	gt.NoError(t, client.Query(context.Background(), "data.route", input, &output))
+	gt.A(t, output.GCS).Length(1)
+	return output.GCS[0].Bucket
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	gt.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	gt.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "route.rego"), []byte(policyV1), 0600))
+	gt.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not policy"), 0600))
+
+	client, version, err := policy.Load(dir)
+	gt.NoError(t, err)
+	gt.Equal(t, queryBucket(t, client), "bucket-v1")
+	gt.Equal(t, len(version), 64)
+
+	// Version depends on only policy files
+	gt.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("updated"), 0600))
+	gt.Equal(t, gt.R1(policy.ReadDir(dir)).NoError(t)["sub/route.rego"], policyV1)
+	_, same, err := policy.Load(dir)
+	gt.NoError(t, err)
+	gt.Equal(t, same, version)
+
+	gt.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "route.rego"), []byte(policyV2), 0600))
+	_, updated, err := policy.Load(dir)
+	gt.NoError(t, err)
+	gt.True(t, updated != version)
+
+	// No policy file
+	_, _, err = policy.Load(t.TempDir())
+	gt.Error(t, err)
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "route.rego")
+	gt.NoError(t, os.WriteFile(path, []byte(policyV1), 0600))
+
+	_, version, err := policy.Load(dir)
+	gt.NoError(t, err)
+
+	var mutex sync.Mutex
+	var reloaded []*opac.Client
+	var versions []string
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		policy.Watch(ctx, dir, 10*time.Millisecond, version, func(client *opac.Client, version string) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			reloaded = append(reloaded, client)
+			versions = append(versions, version)
+		})
+	}()
+
+	count := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(reloaded)
+	}
+	waitFor := func(n int) {
+		for i := 0; i < 100 && count() < n; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Broken policy is not applied
+	gt.NoError(t, os.WriteFile(path, []byte("package route\n\ngcs[dst] {"), 0600))
+	time.Sleep(50 * time.Millisecond)
+	gt.Equal(t, count(), 0)
+
+	gt.NoError(t, os.WriteFile(path, []byte(policyV2), 0600))
+	waitFor(1)
+
+	cancel()
+	<-done
+
+	gt.A(t, reloaded).Length(1)
+	gt.Equal(t, queryBucket(t, reloaded[0]), "bucket-v2")
+	gt.True(t, versions[0] != version)
+}
@@ -2,15 +2,28 @@ package s3
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"io"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
 )
 
 type Client struct {
-	cred aws.CredentialsProvider
+	cred   aws.CredentialsProvider
+	region string
 }
 
 type Option func(*Client)
@@ -21,6 +34,13 @@ func WithCredentials(cred aws.CredentialsProvider) Option {
 	}
 }
 
+// WithRegion sets default region. It is used when region of the object is not specified.
+func WithRegion(region string) Option {
+	return func(c *Client) {
+		c.region = region
+	}
+}
+
 func New(options ...Option) (*Client, error) {
 	c := &Client{}
 
@@ -31,23 +51,54 @@ func New(options ...Option) (*Client, error) {
 	return c, nil
 }
 
-func (x *Client) NewReader(ctx context.Context, region, bucket, key string) (io.ReadCloser, error) {
-	s3Client := s3.NewFromConfig(aws.Config{
+func (x *Client) newS3Client(region string) *s3.Client {
+	if region == "" {
+		region = x.region
+	}
+
+	return s3.NewFromConfig(aws.Config{
 		Region:      region,
-		Credentials: aws.NewCredentialsCache(nil),
+		Credentials: x.cred,
 	})
+}
+
+func (x *Client) NewReader(ctx context.Context, region, bucket, key, versionID, etag string) (io.ReadCloser, error) {
+	s3Client := x.newS3Client(region)
 
 	input := &s3.GetObjectInput{
 		Bucket: &bucket,
 		Key:    &key,
 	}
+	// ETag in event notification is not quoted
+	switch {
+	case versionID != "":
+		input.VersionId = &versionID
+	case etag != "":
+		input.IfMatch = aws.String(`"` + strings.Trim(etag, `"`) + `"`)
+	}
+
 	output, err := s3Client.GetObject(ctx, input)
+	if (versionID != "" || etag != "") && isSourceChanged(err) {
+		return nil, goerr.Wrap(model.ErrSourceChanged, "object has been changed").With("bucket", bucket).With("key", key).With("versionID", versionID).With("etag", etag).With("error", err)
+	}
 	if err != nil {
 		return nil, goerr.Wrap(err, "fail to get object").With("bucket", bucket).With("key", key)
 	}
 	return output.Body, nil
 }
 
+// isSourceChanged returns true if the error of GetObject means that the specified version or ETag of the object no longer exists
+func isSourceChanged(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "PreconditionFailed", "NoSuchKey", "NoSuchVersion":
+			return true
+		}
+	}
+	return false
+}
+
 type pipeWriter struct {
 	w     io.WriteCloser
 	errCh chan error
@@ -65,11 +116,9 @@ func (x *pipeWriter) Close() error {
 	return <-x.errCh
 }
 
-func (x *Client) NewWriter(ctx context.Context, region, bucket, key string) (io.WriteCloser, error) {
-	s3Client := s3.NewFromConfig(aws.Config{
-		Region:      region,
-		Credentials: x.cred,
-	})
+func (x *Client) NewWriter(ctx context.Context, region, bucket, key string, attrs *model.ObjectAttrs, opts *model.AmazonS3WriteOptions) (io.WriteCloser, error) {
+	// PutObject can not upload a stream with unknown size. Uploader splits the stream into parts and uploads them by multipart upload if needed.
+	uploader := manager.NewUploader(x.newS3Client(region))
 
 	errCh := make(chan error, 1)
 	r, w := io.Pipe()
@@ -83,12 +132,32 @@ func (x *Client) NewWriter(ctx context.Context, region, bucket, key string) (io.
 		Bucket: &bucket,
 		Key:    &key,
 		Body:   r,
+		// SDK computes checksum of each request and S3 verifies it
+		ChecksumAlgorithm: types.ChecksumAlgorithmCrc32c,
+	}
+	if attrs != nil {
+		input.ContentType = optionalString(attrs.ContentType)
+		input.ContentEncoding = optionalString(attrs.ContentEncoding)
+		input.CacheControl = optionalString(attrs.CacheControl)
+		input.ContentDisposition = optionalString(attrs.ContentDisposition)
+		input.ContentLanguage = optionalString(attrs.ContentLanguage)
+		// Keys of metadata must be converted by ObjectAttrs.ForStorage in advance
+		input.Metadata = attrs.Metadata
+
+		// Uploader ignores ContentMD5 for multipart upload
+		if attrs.MD5 != nil {
+			input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(attrs.MD5))
+		}
+	}
+	if opts != nil {
+		setWriteOptions(input, opts)
 	}
 
 	go func() {
 		defer close(errCh)
-		if _, err := s3Client.PutObject(ctx, input); err != nil {
+		if _, err := uploader.Upload(ctx, input); err != nil {
 			errCh <- goerr.Wrap(err, "fail to put object").With("bucket", bucket).With("key", key)
+			_ = r.CloseWithError(err)
 			return
 		}
 
@@ -99,3 +168,137 @@ func (x *Client) NewWriter(ctx context.Context, region, bucket, key string) (io.
 
 	return writer, nil
 }
+
+func (x *Client) List(ctx context.Context, region, bucket, prefix, startAfter string, fn func(*model.ObjectInfo) error) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: &bucket,
+		Prefix: &prefix,
+	}
+	if startAfter != "" {
+		input.StartAfter = &startAfter
+	}
+	paginator := s3.NewListObjectsV2Paginator(x.newS3Client(region), input)
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return goerr.Wrap(err, "fail to list objects").With("bucket", bucket).With("prefix", prefix).With("startAfter", startAfter)
+		}
+
+		for _, obj := range page.Contents {
+			if err := fn(&model.ObjectInfo{
+				Name:      aws.ToString(obj.Key),
+				Size:      aws.ToInt64(obj.Size),
+				ETag:      aws.ToString(obj.ETag),
+				UpdatedAt: aws.ToTime(obj.LastModified),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (x *Client) Stat(ctx context.Context, region, bucket, key string, customerKey []byte) (*model.ObjectStat, error) {
+	input := &s3.HeadObjectInput{
+		Bucket:       &bucket,
+		Key:          &key,
+		ChecksumMode: types.ChecksumModeEnabled,
+	}
+	// HEAD of an object encrypted by SSE-C fails without the key
+	if customerKey != nil {
+		input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKey(customerKey)
+	}
+
+	output, err := x.newS3Client(region).HeadObject(ctx, input)
+	if nf := (*types.NotFound)(nil); errors.As(err, &nf) {
+		return nil, goerr.Wrap(model.ErrObjectNotFound, "object not found").With("bucket", bucket).With("key", key)
+	}
+	if err != nil {
+		return nil, goerr.Wrap(err, "fail to head object").With("bucket", bucket).With("key", key)
+	}
+
+	etag := aws.ToString(output.ETag)
+	stat := &model.ObjectStat{
+		Size:               aws.ToInt64(output.ContentLength),
+		ETag:               etag,
+		ContentType:        aws.ToString(output.ContentType),
+		ContentEncoding:    aws.ToString(output.ContentEncoding),
+		CacheControl:       aws.ToString(output.CacheControl),
+		ContentDisposition: aws.ToString(output.ContentDisposition),
+		ContentLanguage:    aws.ToString(output.ContentLanguage),
+		Metadata:           output.Metadata,
+		UpdatedAt:          aws.ToTime(output.LastModified),
+	}
+
+	// ETag is MD5 digest in hex unless the object is uploaded by multipart upload or encrypted by KMS or customer key. ETag of multipart upload has "-" and the number of parts.
+	if raw, err := hex.DecodeString(strings.Trim(etag, `"`)); err == nil && len(raw) == 16 &&
+		output.SSEKMSKeyId == nil && output.SSECustomerAlgorithm == nil {
+		stat.MD5 = raw
+	}
+	// CRC32C is available only if it is specified at upload
+	if raw, err := base64.StdEncoding.DecodeString(aws.ToString(output.ChecksumCRC32C)); err == nil && len(raw) == 4 {
+		crc32c := binary.BigEndian.Uint32(raw)
+		stat.CRC32C = &crc32c
+	}
+
+	return stat, nil
+}
+
+// setWriteOptions sets storage class, encryption, ACL, object lock and tags of the destination object to input
+func setWriteOptions(input *s3.PutObjectInput, opts *model.AmazonS3WriteOptions) {
+	if opts.StorageClass != "" {
+		input.StorageClass = types.StorageClass(opts.StorageClass)
+	}
+
+	switch {
+	case opts.ServerSideEncryption != "":
+		input.ServerSideEncryption = types.ServerSideEncryption(opts.ServerSideEncryption)
+	case opts.KMSKeyID != "":
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+	}
+	input.SSEKMSKeyId = optionalString(opts.KMSKeyID)
+
+	// Uploader passes the customer key to each part of multipart upload
+	if opts.CustomerKey != nil {
+		input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = sseCustomerKey(opts.CustomerKey)
+	}
+
+	if opts.ACL != "" {
+		input.ACL = types.ObjectCannedACL(opts.ACL)
+	}
+
+	// Checksum of the request required by object lock is provided by ChecksumAlgorithm
+	if opts.Retention != nil {
+		input.ObjectLockMode = types.ObjectLockMode(opts.Retention.Mode)
+		input.ObjectLockRetainUntilDate = aws.Time(opts.Retention.RetainUntil(time.Now()))
+	}
+	if opts.LegalHold {
+		input.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+	}
+
+	if len(opts.Tags) > 0 {
+		tags := url.Values{}
+		for k, v := range opts.Tags {
+			tags.Set(k, v)
+		}
+		input.Tagging = aws.String(tags.Encode())
+	}
+}
+
+// sseCustomerKey returns algorithm, base64 encoded key and base64 encoded MD5 digest of the key for SSE-C request
+func sseCustomerKey(key []byte) (*string, *string, *string) {
+	sum := md5.Sum(key)
+	return aws.String("AES256"),
+		aws.String(base64.StdEncoding.EncodeToString(key)),
+		aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+// optionalString returns nil if s is empty, because empty header value overwrites the default one
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
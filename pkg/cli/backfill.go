@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/cli/config"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdBackfill() *cli.Command {
+	var (
+		since   string
+		until   string
+		options model.BackfillOptions
+
+		policyCfg     policyConfig
+		storageCfg    storageConfig
+		retryCfg      config.Retry
+		deadLetterCfg config.DeadLetter
+		policyEnvCfg  config.PolicyEnv
+		checksumCfg   config.Checksum
+		dedupCfg      dedupConfig
+	)
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "since",
+			Usage:       "Only route objects updated at the time or later, in RFC3339 format",
+			Destination: &since,
+		},
+		&cli.StringFlag{
+			Name:        "until",
+			Usage:       "Only route objects updated before the time, in RFC3339 format",
+			Destination: &until,
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "Only evaluate policy and show destinations without transfer",
+			Destination: &options.DryRun,
+		},
+		&cli.IntFlag{
+			Name:        "concurrency",
+			Aliases:     []string{"c"},
+			Usage:       "Number of objects routed concurrently",
+			Value:       4,
+			Destination: &options.Concurrency,
+		},
+		&cli.StringFlag{
+			Name:        "checkpoint",
+			Usage:       "File path to save progress. Backfill resumes from the checkpoint if the file exists",
+			Destination: &options.Checkpoint,
+		},
+	}
+	flags = append(flags, policyCfg.Flags()...)
+	flags = append(flags, storageCfg.Flags()...)
+	flags = append(flags, retryCfg.Flags()...)
+	flags = append(flags, deadLetterCfg.Flags()...)
+	flags = append(flags, policyEnvCfg.Flags()...)
+	flags = append(flags, checksumCfg.Flags()...)
+	flags = append(flags, dedupCfg.Flags()...)
+
+	return &cli.Command{
+		Name:      "backfill",
+		Usage:     "Route existing objects under a prefix with the current policy",
+		ArgsUsage: "[gs://bucket/prefix, s3://region/bucket/prefix or abs://account/container/prefix]",
+		Flags:     flags,
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() != 1 {
+				return goerr.New("exactly one source prefix URI must be specified")
+			}
+
+			src, err := model.ParseDestination(ctx.Args().First())
+			if err != nil {
+				return goerr.Wrap(err, "invalid source prefix URI")
+			}
+
+			if since != "" {
+				if options.Since, err = time.Parse(time.RFC3339, since); err != nil {
+					return goerr.Wrap(err, "invalid since time").With("since", since)
+				}
+			}
+			if until != "" {
+				if options.Until, err = time.Parse(time.RFC3339, until); err != nil {
+					return goerr.Wrap(err, "invalid until time").With("until", until)
+				}
+			}
+
+			logger := logging.Default()
+			logger.Info("start backfill",
+				"source", src,
+				"policy", policyCfg,
+				"since", options.Since,
+				"until", options.Until,
+				"dryRun", options.DryRun,
+				"concurrency", options.Concurrency,
+				"checkpoint", options.Checkpoint,
+				"retry", retryCfg,
+				"policyEnv", policyEnvCfg,
+				"checksum", checksumCfg,
+				"dedup", dedupCfg,
+				"deadLetter", deadLetterCfg,
+			)
+
+			adaptorOptions, err := storageCfg.adapterOptions()
+			if err != nil {
+				return err
+			}
+
+			retryPolicy, err := retryCfg.Policy()
+			if err != nil {
+				return goerr.Wrap(err, "invalid retry configuration")
+			}
+			ucOptions := []usecase.Option{
+				usecase.WithRetryPolicy(retryPolicy),
+				usecase.WithEnvFilter(policyEnvCfg.Filter()),
+				usecase.WithChecksumVerification(checksumCfg.Verify()),
+			}
+			if location, err := deadLetterCfg.Location(); err != nil {
+				return goerr.Wrap(err, "invalid dead letter configuration")
+			} else if location != nil {
+				ucOptions = append(ucOptions, usecase.WithDeadLetter(location))
+			}
+			if option, closer, err := dedupCfg.usecaseOption(); err != nil {
+				return goerr.Wrap(err, "invalid dedup configuration")
+			} else if option != nil {
+				if closer != nil {
+					defer closer.Close()
+				}
+				ucOptions = append(ucOptions, option)
+			}
+
+			uc, err := policyCfg.newUseCase(ctx.Context, adapter.New(adaptorOptions...), ucOptions...)
+			if err != nil {
+				return err
+			}
+			defer uc.Close()
+
+			// Stop listing by signal to save checkpoint, then backfill can be resumed
+			sigCtx, stop := signal.NotifyContext(ctx.Context, syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			report, err := uc.Backfill(sigCtx, *src, options)
+			if report != nil {
+				label := "succeeded"
+				if options.DryRun {
+					label = "routed (dry-run)"
+				}
+				fmt.Fprintf(os.Stdout, "Backfilled %d objects: %d %s, %d skipped, %d filtered, %d failed\n",
+					report.Listed, report.Succeeded, label, report.Skipped, report.Filtered, report.Failed)
+				if report.Checkpoint != "" {
+					fmt.Fprintf(os.Stdout, "Last processed object: %s\n", report.Checkpoint)
+				}
+
+				if err == nil && report.Failed > 0 {
+					err = goerr.New("some objects failed to backfill").With("failed", report.Failed)
+				}
+			}
+			return err
+		},
+	}
+}
@@ -18,6 +18,12 @@ func Run(argv []string) error {
 		Flags: flags,
 		Commands: []*cli.Command{
 			cmdServe(),
+			cmdJobs(),
+			cmdReplay(),
+			cmdCopy(),
+			cmdBackfill(),
+			cmdEval(),
+			cmdTest(),
 		},
 		Before: func(ctx *cli.Context) error {
 			logger, err := loggingCfg.NewLogger()
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter/s3"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/urfave/cli/v2"
+)
+
+type AmazonS3 struct {
+	enable          bool
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	profile         string
+}
+
+func (x *AmazonS3) Flags() []cli.Flag {
+	const category = "Amazon S3"
+
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "enable-s3",
+			Usage:       "Enable Amazon S3",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_ENABLE_S3"},
+			Destination: &x.enable,
+		},
+		&cli.StringFlag{
+			Name:        "s3-region",
+			Usage:       "Default AWS region. It is used when region of the object is not specified",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_S3_REGION"},
+			Destination: &x.region,
+		},
+		&cli.StringFlag{
+			Name:        "s3-access-key-id",
+			Usage:       "AWS access key ID. Default credential chain is used if not set",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_S3_ACCESS_KEY_ID"},
+			Destination: &x.accessKeyID,
+		},
+		&cli.StringFlag{
+			Name:        "s3-secret-access-key",
+			Usage:       "AWS secret access key",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_S3_SECRET_ACCESS_KEY"},
+			Destination: &x.secretAccessKey,
+		},
+		&cli.StringFlag{
+			Name:        "s3-session-token",
+			Usage:       "AWS session token for temporary credentials",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_S3_SESSION_TOKEN"},
+			Destination: &x.sessionToken,
+		},
+		&cli.StringFlag{
+			Name:        "s3-profile",
+			Usage:       "AWS shared config profile name",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_S3_PROFILE"},
+			Destination: &x.profile,
+		},
+	}
+}
+
+func (x AmazonS3) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("enable", x.enable),
+		slog.String("region", x.region),
+		slog.String("accessKeyID", x.accessKeyID),
+		slog.Int("secretAccessKey(len)", len(x.secretAccessKey)),
+		slog.Int("sessionToken(len)", len(x.sessionToken)),
+		slog.String("profile", x.profile),
+	)
+}
+
+func (x *AmazonS3) NewClient() (*s3.Client, error) {
+	if !x.enable {
+		if x.accessKeyID != "" || x.secretAccessKey != "" || x.profile != "" {
+			logging.Default().Warn("Amazon S3 configuration is ignored because Amazon S3 is disabled")
+		}
+		return nil, nil
+	}
+
+	var loadOptions []func(*awsconfig.LoadOptions) error
+	if x.region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(x.region))
+	}
+
+	switch {
+	case x.accessKeyID != "" || x.secretAccessKey != "":
+		if x.accessKeyID == "" {
+			return nil, goerr.New("AWS access key ID is required with secret access key")
+		}
+		if x.secretAccessKey == "" {
+			return nil, goerr.New("AWS secret access key is required with access key ID")
+		}
+		if x.profile != "" {
+			return nil, goerr.New("AWS profile can not be used with static credentials")
+		}
+
+		loadOptions = append(loadOptions, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(x.accessKeyID, x.secretAccessKey, x.sessionToken),
+		))
+
+	case x.profile != "":
+		loadOptions = append(loadOptions, awsconfig.WithSharedConfigProfile(x.profile))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), loadOptions...)
+	if err != nil {
+		return nil, goerr.Wrap(err, "fail to load AWS config").With("region", x.region).With("profile", x.profile)
+	}
+
+	return s3.New(
+		s3.WithCredentials(cfg.Credentials),
+		s3.WithRegion(cfg.Region),
+	)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/m-mizutani/goerr"
 	"github.com/secmon-lab/nydus/pkg/adapter/abs"
 	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
 	"github.com/urfave/cli/v2"
 )
 
@@ -14,6 +15,12 @@ type Azure struct {
 	tenantID     string
 	clientID     string
 	clientSecret string
+
+	eventGridSecret    string
+	eventGridTenantID  string
+	eventGridAudiences cli.StringSlice
+	eventGridJWKSURL   string
+	eventGridCallers   cli.StringSlice
 }
 
 func (x *Azure) Flags() []cli.Flag {
@@ -49,6 +56,42 @@ func (x *Azure) Flags() []cli.Flag {
 			EnvVars:     []string{"NYDUS_AZURE_CLIENT_SECRET"},
 			Destination: &x.clientSecret,
 		},
+
+		&cli.StringFlag{
+			Name:        "azure-eventgrid-secret",
+			Usage:       "Shared secret to authenticate Event Grid delivery. It must be set in query parameter 'secret' or header 'X-Nydus-Secret' of the webhook",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_AZURE_EVENTGRID_SECRET"},
+			Destination: &x.eventGridSecret,
+		},
+		&cli.StringFlag{
+			Name:        "azure-eventgrid-tenant-id",
+			Usage:       "Entra ID tenant ID to verify bearer token of Event Grid delivery. Entra ID token verification is enabled if it is set",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_AZURE_EVENTGRID_TENANT_ID"},
+			Destination: &x.eventGridTenantID,
+		},
+		&cli.StringSliceFlag{
+			Name:        "azure-eventgrid-app-id",
+			Usage:       "Allowed Entra ID application ID or URI (audience) of bearer token of Event Grid delivery",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_AZURE_EVENTGRID_APP_ID"},
+			Destination: &x.eventGridAudiences,
+		},
+		&cli.StringFlag{
+			Name:        "azure-eventgrid-jwks-url",
+			Usage:       "JWKS URL to verify bearer token of Event Grid delivery. Default is derived from tenant ID",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_AZURE_EVENTGRID_JWKS_URL"},
+			Destination: &x.eventGridJWKSURL,
+		},
+		&cli.StringSliceFlag{
+			Name:        "azure-eventgrid-caller-app-id",
+			Usage:       "Allowed application ID of requester (appid or azp) of bearer token of Event Grid delivery. Default is Azure Event Grid application " + model.AzureEventGridAppID,
+			Category:    category,
+			EnvVars:     []string{"NYDUS_AZURE_EVENTGRID_CALLER_APP_ID"},
+			Destination: &x.eventGridCallers,
+		},
 	}
 }
 
@@ -58,9 +101,44 @@ func (x Azure) LogValue() slog.Value {
 		slog.String("tenantID", x.tenantID),
 		slog.String("clientID", x.clientID),
 		slog.Int("clientSecret(len)", len(x.clientSecret)),
+		slog.Int("eventGridSecret(len)", len(x.eventGridSecret)),
+		slog.String("eventGridTenantID", x.eventGridTenantID),
+		slog.Any("eventGridAppIDs", x.eventGridAudiences.Value()),
+		slog.String("eventGridJWKSURL", x.eventGridJWKSURL),
+		slog.Any("eventGridCallerAppIDs", x.eventGridCallers.Value()),
 	)
 }
 
+// EventGridAuth returns configuration to authenticate Event Grid delivery. It returns nil if neither shared secret nor tenant ID is set.
+func (x *Azure) EventGridAuth() (*model.AzureEventGridAuth, error) {
+	if x.eventGridSecret == "" && x.eventGridTenantID == "" {
+		if len(x.eventGridAudiences.Value()) > 0 {
+			logging.Default().Warn("Event Grid app ID is ignored because Event Grid tenant ID is not set")
+		}
+		return nil, nil
+	}
+
+	auth := &model.AzureEventGridAuth{
+		Secret: x.eventGridSecret,
+	}
+
+	if x.eventGridTenantID != "" {
+		if len(x.eventGridAudiences.Value()) == 0 {
+			return nil, goerr.New("Event Grid app ID is required to verify Entra ID token")
+		}
+
+		auth.TenantID = x.eventGridTenantID
+		auth.Audiences = x.eventGridAudiences.Value()
+		auth.JWKSURL = x.eventGridJWKSURL
+		auth.CallerAppIDs = x.eventGridCallers.Value()
+		if auth.JWKSURL == "" {
+			auth.JWKSURL = "https://login.microsoftonline.com/" + x.eventGridTenantID + "/discovery/v2.0/keys"
+		}
+	}
+
+	return auth, nil
+}
+
 func (x *Azure) NewClient() (*abs.Client, error) {
 	if !x.enable {
 		if x.tenantID != "" || x.clientID != "" || x.clientSecret != "" {
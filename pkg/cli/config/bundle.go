@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/urfave/cli/v2"
+)
+
+type PolicyBundle struct {
+	source    string
+	interval  time.Duration
+	token     string
+	key       string
+	keyID     string
+	algorithm string
+	scope     string
+	insecure  bool
+}
+
+func (x *PolicyBundle) Flags() []cli.Flag {
+	const category = "Policy Bundle"
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "policy-bundle",
+			Usage:       "Location of OPA bundle to load policy from, instead of policy directory. URL of bundle server (http or https), gs://bucket/name, s3://region/bucket/key or abs://account/container/blob",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_POLICY_BUNDLE"},
+			Destination: &x.source,
+		},
+		&cli.DurationFlag{
+			Name:        "policy-bundle-interval",
+			Usage:       "Interval of polling OPA bundle. The bundle is loaded only at startup if 0",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_POLICY_BUNDLE_INTERVAL"},
+			Value:       time.Minute,
+			Destination: &x.interval,
+		},
+		&cli.StringFlag{
+			Name:        "policy-bundle-token",
+			Usage:       "Bearer token to download OPA bundle from bundle server",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_POLICY_BUNDLE_TOKEN"},
+			Destination: &x.token,
+		},
+		&cli.StringFlag{
+			Name:        "policy-bundle-verification-key",
+			Usage:       "PEM encoded public key (or secret for HMAC) to verify signature of OPA bundle, or path of the key file. Required unless --policy-bundle-insecure is set",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_POLICY_BUNDLE_VERIFICATION_KEY"},
+			Destination: &x.key,
+		},
+		&cli.StringFlag{
+			Name:        "policy-bundle-key-id",
+			Usage:       "Key ID of the verification key",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_POLICY_BUNDLE_KEY_ID"},
+			Value:       "default",
+			Destination: &x.keyID,
+		},
+		&cli.StringFlag{
+			Name:        "policy-bundle-key-algorithm",
+			Usage:       "Signing algorithm of the verification key, such as RS256, ES256 or HS256",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_POLICY_BUNDLE_KEY_ALGORITHM"},
+			Value:       "RS256",
+			Destination: &x.algorithm,
+		},
+		&cli.StringFlag{
+			Name:        "policy-bundle-scope",
+			Usage:       "Scope of the bundle signature. It is not checked if not set",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_POLICY_BUNDLE_SCOPE"},
+			Destination: &x.scope,
+		},
+		&cli.BoolFlag{
+			Name:        "policy-bundle-insecure",
+			Usage:       "Load unsigned OPA bundle without verification key. Not allowed for bundle server over plain HTTP",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_POLICY_BUNDLE_INSECURE"},
+			Destination: &x.insecure,
+		},
+	}
+}
+
+func (x PolicyBundle) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("source", x.source),
+		slog.Duration("interval", x.interval),
+		slog.Int("token(len)", len(x.token)),
+		slog.Bool("verification", x.key != ""),
+		slog.String("keyID", x.keyID),
+		slog.String("algorithm", x.algorithm),
+		slog.String("scope", x.scope),
+		slog.Bool("insecure", x.insecure),
+	)
+}
+
+// Bundle returns configuration of OPA bundle. It returns nil if bundle is not configured.
+func (x *PolicyBundle) Bundle() (*model.PolicyBundle, error) {
+	if x.source == "" {
+		return nil, nil
+	}
+
+	bundle := &model.PolicyBundle{
+		Source:   x.source,
+		Interval: x.interval,
+		Token:    x.token,
+		Insecure: x.insecure,
+	}
+
+	if x.key != "" {
+		key := x.key
+		// The key can be given as a file path in the same manner as OPA
+		if raw, err := os.ReadFile(x.key); err == nil {
+			key = string(raw)
+		} else if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, os.ErrInvalid) {
+			return nil, goerr.Wrap(err, "fail to read verification key file")
+		}
+
+		bundle.Verification = &model.PolicyBundleKey{
+			Key:       key,
+			KeyID:     x.keyID,
+			Algorithm: x.algorithm,
+			Scope:     x.scope,
+		}
+	}
+
+	if err := bundle.Validate(); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
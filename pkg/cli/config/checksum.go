@@ -0,0 +1,34 @@
+package config
+
+import (
+	"log/slog"
+
+	"github.com/urfave/cli/v2"
+)
+
+type Checksum struct {
+	skip bool
+}
+
+func (x *Checksum) Flags() []cli.Flag {
+	const category = "Checksum"
+
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "skip-checksum",
+			Usage:       "Skip verification of size and checksum of copied content against the values declared by the event. MD5 of Amazon S3 source objects is compared only if it is available from attributes of the object",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_SKIP_CHECKSUM"},
+			Destination: &x.skip,
+		},
+	}
+}
+
+func (x Checksum) LogValue() slog.Value {
+	return slog.BoolValue(!x.skip)
+}
+
+// Verify returns true if copied content must be verified
+func (x *Checksum) Verify() bool {
+	return !x.skip
+}
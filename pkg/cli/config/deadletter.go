@@ -0,0 +1,38 @@
+package config
+
+import (
+	"log/slog"
+
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/urfave/cli/v2"
+)
+
+type DeadLetter struct {
+	location string
+}
+
+func (x *DeadLetter) Flags() []cli.Flag {
+	const category = "Dead Letter"
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "dead-letter",
+			Usage:       "Location to write dead letters of failed transfers. Local directory path, gs://bucket/prefix, s3://region/bucket/prefix or abs://account/container/prefix. Disabled if not set",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_DEAD_LETTER"},
+			Destination: &x.location,
+		},
+	}
+}
+
+func (x DeadLetter) LogValue() slog.Value {
+	return slog.StringValue(x.location)
+}
+
+// Location returns location of dead letters. It returns nil if dead letter is not configured.
+func (x *DeadLetter) Location() (*model.DeadLetterLocation, error) {
+	if x.location == "" {
+		return nil, nil
+	}
+	return model.ParseDeadLetterLocation(x.location)
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"log/slog"
+
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/urfave/cli/v2"
+)
+
+type PolicyEnv struct {
+	allow cli.StringSlice
+}
+
+func (x *PolicyEnv) Flags() []cli.Flag {
+	const category = "Policy"
+
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "policy-env",
+			Usage:       "Names of environment variables passed to route policy as input.env. A name ending with '*' is a prefix, such as 'NYDUS_ROUTE_*'. Variables that look like secrets are never passed",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_POLICY_ENV"},
+			Destination: &x.allow,
+		},
+	}
+}
+
+func (x PolicyEnv) LogValue() slog.Value {
+	return slog.AnyValue(x.allow.Value())
+}
+
+func (x *PolicyEnv) Filter() *model.EnvFilter {
+	return &model.EnvFilter{Allow: x.allow.Value()}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/m-mizutani/goerr"
 	"github.com/secmon-lab/nydus/pkg/adapter/gcs"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
 	"github.com/urfave/cli/v2"
 	"google.golang.org/api/option"
 )
@@ -12,6 +13,11 @@ import (
 type GoogleCloudStorage struct {
 	enable         bool
 	credentialFile string
+
+	pubsubJWKSURL   string
+	pubsubIssuers   cli.StringSlice
+	pubsubAudiences cli.StringSlice
+	pubsubEmails    cli.StringSlice
 }
 
 func (x *GoogleCloudStorage) Flags() []cli.Flag {
@@ -32,6 +38,36 @@ func (x *GoogleCloudStorage) Flags() []cli.Flag {
 			EnvVars:     []string{"NYDUS_GCS_CREDENTIAL_FILE"},
 			Destination: &x.credentialFile,
 		},
+		&cli.StringFlag{
+			Name:        "gcs-pubsub-jwks-url",
+			Usage:       "JWKS URL to verify OIDC token of Pub/Sub push request",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_GCS_PUBSUB_JWKS_URL"},
+			Destination: &x.pubsubJWKSURL,
+			Value:       "https://www.googleapis.com/oauth2/v3/certs",
+		},
+		&cli.StringSliceFlag{
+			Name:        "gcs-pubsub-issuer",
+			Usage:       "Allowed issuer of OIDC token of Pub/Sub push request",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_GCS_PUBSUB_ISSUER"},
+			Destination: &x.pubsubIssuers,
+			Value:       cli.NewStringSlice("https://accounts.google.com", "accounts.google.com"),
+		},
+		&cli.StringSliceFlag{
+			Name:        "gcs-pubsub-audience",
+			Usage:       "Allowed audience of OIDC token of Pub/Sub push request. OIDC token verification is enabled if audience or email is set",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_GCS_PUBSUB_AUDIENCE"},
+			Destination: &x.pubsubAudiences,
+		},
+		&cli.StringSliceFlag{
+			Name:        "gcs-pubsub-email",
+			Usage:       "Allowed service account email of OIDC token of Pub/Sub push request. OIDC token verification is enabled if audience or email is set",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_GCS_PUBSUB_EMAIL"},
+			Destination: &x.pubsubEmails,
+		},
 	}
 }
 
@@ -39,9 +75,39 @@ func (x GoogleCloudStorage) LogValue() slog.Value {
 	return slog.GroupValue(
 		slog.Bool("enable", x.enable),
 		slog.String("credentialFile", x.credentialFile),
+		slog.String("pubsubJWKSURL", x.pubsubJWKSURL),
+		slog.Any("pubsubIssuers", x.pubsubIssuers.Value()),
+		slog.Any("pubsubAudiences", x.pubsubAudiences.Value()),
+		slog.Any("pubsubEmails", x.pubsubEmails.Value()),
 	)
 }
 
+// PubSubAuth returns configuration to verify OIDC token of Pub/Sub push request. It returns nil if neither audience nor email is set.
+func (x *GoogleCloudStorage) PubSubAuth() (*model.GooglePubSubAuth, error) {
+	audiences := x.pubsubAudiences.Value()
+	emails := x.pubsubEmails.Value()
+	if len(audiences) == 0 && len(emails) == 0 {
+		return nil, nil
+	}
+
+	if len(audiences) == 0 {
+		return nil, goerr.New("Pub/Sub audience is required to verify OIDC token")
+	}
+	if len(emails) == 0 {
+		return nil, goerr.New("Pub/Sub email is required to verify OIDC token")
+	}
+	if x.pubsubJWKSURL == "" {
+		return nil, goerr.New("JWKS URL is required to verify OIDC token")
+	}
+
+	return &model.GooglePubSubAuth{
+		JWKSURL:   x.pubsubJWKSURL,
+		Issuers:   x.pubsubIssuers.Value(),
+		Audiences: audiences,
+		Emails:    emails,
+	}, nil
+}
+
 func (x *GoogleCloudStorage) NewClient() (*gcs.Client, error) {
 	if !x.enable {
 		return nil, nil
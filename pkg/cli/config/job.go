@@ -0,0 +1,55 @@
+package config
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/secmon-lab/nydus/pkg/adapter/boltdb"
+	"github.com/urfave/cli/v2"
+)
+
+type JobStore struct {
+	path      string
+	retention time.Duration
+}
+
+func (x *JobStore) Flags() []cli.Flag {
+	const category = "Job Store"
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "job-store",
+			Usage:       "File path of job store database. Transfer jobs are recorded and unfinished jobs are resumed at startup. Disabled if not set",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_JOB_STORE"},
+			Destination: &x.path,
+		},
+		&cli.DurationFlag{
+			Name:        "job-retention",
+			Usage:       "Retention period of finished jobs in job store. Expired jobs are deleted at startup",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_JOB_RETENTION"},
+			Value:       7 * 24 * time.Hour,
+			Destination: &x.retention,
+		},
+	}
+}
+
+func (x JobStore) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("path", x.path),
+		slog.Duration("retention", x.retention),
+	)
+}
+
+func (x *JobStore) Path() string             { return x.path }
+func (x *JobStore) Retention() time.Duration { return x.retention }
+
+// NewClient opens job store. It returns nil if job store is not configured.
+func (x *JobStore) NewClient() (*boltdb.Client, error) {
+	if x.path == "" {
+		return nil, nil
+	}
+
+	return boltdb.New(x.path)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/fatih/color"
 	"github.com/m-mizutani/clog"
 	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
 	"github.com/urfave/cli/v2"
 )
 
@@ -111,5 +113,6 @@ func (x *Logging) NewLogger() (*slog.Logger, error) {
 		return nil, goerr.New("invalid log format").With("format", x.format)
 	}
 
-	return slog.New(handler), nil
+	// Secrets in environment variables must not appear in logs, even in error values
+	return slog.New(logging.NewRedactHandler(handler, model.NewSecrets(os.Environ()))), nil
 }
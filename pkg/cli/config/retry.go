@@ -0,0 +1,77 @@
+package config
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/urfave/cli/v2"
+)
+
+type Retry struct {
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	jitter      float64
+}
+
+func (x *Retry) Flags() []cli.Flag {
+	const category = "Retry"
+
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        "retry-max-attempts",
+			Usage:       "Max number of attempts to transfer an object to a destination. Set 1 to disable retry",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_RETRY_MAX_ATTEMPTS"},
+			Value:       3,
+			Destination: &x.maxAttempts,
+		},
+		&cli.DurationFlag{
+			Name:        "retry-base-backoff",
+			Usage:       "Wait time before the first retry. It is doubled for each retry",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_RETRY_BASE_BACKOFF"},
+			Value:       time.Second,
+			Destination: &x.baseBackoff,
+		},
+		&cli.DurationFlag{
+			Name:        "retry-max-backoff",
+			Usage:       "Max wait time before retry",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_RETRY_MAX_BACKOFF"},
+			Value:       30 * time.Second,
+			Destination: &x.maxBackoff,
+		},
+		&cli.Float64Flag{
+			Name:        "retry-jitter",
+			Usage:       "Ratio to randomly reduce wait time, between 0 and 1",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_RETRY_JITTER"},
+			Value:       0.2,
+			Destination: &x.jitter,
+		},
+	}
+}
+
+func (x Retry) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("maxAttempts", x.maxAttempts),
+		slog.Duration("baseBackoff", x.baseBackoff),
+		slog.Duration("maxBackoff", x.maxBackoff),
+		slog.Float64("jitter", x.jitter),
+	)
+}
+
+func (x *Retry) Policy() (*model.RetryPolicy, error) {
+	policy := &model.RetryPolicy{
+		MaxAttempts: x.maxAttempts,
+		BaseBackoff: x.baseBackoff,
+		MaxBackoff:  x.maxBackoff,
+		Jitter:      x.jitter,
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/cli/config"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdCopy() *cli.Command {
+	var (
+		dstURIs cli.StringSlice
+
+		policyCfg     policyConfig
+		storageCfg    storageConfig
+		retryCfg      config.Retry
+		deadLetterCfg config.DeadLetter
+		policyEnvCfg  config.PolicyEnv
+		checksumCfg   config.Checksum
+	)
+
+	flags := []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "dst",
+			Aliases:     []string{"d"},
+			Usage:       "Destination URI such as gs://bucket/name. Route policy is bypassed if specified. It can be specified multiple times",
+			Destination: &dstURIs,
+		},
+	}
+	flags = append(flags, policyCfg.Flags()...)
+	flags = append(flags, storageCfg.Flags()...)
+	flags = append(flags, retryCfg.Flags()...)
+	flags = append(flags, deadLetterCfg.Flags()...)
+	flags = append(flags, policyEnvCfg.Flags()...)
+	flags = append(flags, checksumCfg.Flags()...)
+
+	return &cli.Command{
+		Name:      "copy",
+		Usage:     "Copy an object to destinations decided by policy or specified explicitly",
+		ArgsUsage: "[gs://bucket/name, s3://region/bucket/key or abs://account/container/blob]",
+		Flags:     flags,
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() != 1 {
+				return goerr.New("exactly one source object URI must be specified")
+			}
+
+			src, err := model.ParseDestination(ctx.Args().First())
+			if err != nil {
+				return goerr.Wrap(err, "invalid source object URI")
+			}
+
+			var dsts []model.Destination
+			for _, uri := range dstURIs.Value() {
+				dst, err := model.ParseDestination(uri)
+				if err != nil {
+					return goerr.Wrap(err, "invalid destination URI")
+				}
+				dsts = append(dsts, *dst)
+			}
+
+			logger := logging.Default()
+			logger.Info("start copy",
+				"source", src,
+				"destinations", dsts,
+				"policy", policyCfg,
+				"retry", retryCfg,
+				"policyEnv", policyEnvCfg,
+				"checksum", checksumCfg,
+				"deadLetter", deadLetterCfg,
+			)
+
+			adaptorOptions, err := storageCfg.adapterOptions()
+			if err != nil {
+				return err
+			}
+
+			retryPolicy, err := retryCfg.Policy()
+			if err != nil {
+				return goerr.Wrap(err, "invalid retry configuration")
+			}
+			ucOptions := []usecase.Option{
+				usecase.WithRetryPolicy(retryPolicy),
+				usecase.WithEnvFilter(policyEnvCfg.Filter()),
+				usecase.WithChecksumVerification(checksumCfg.Verify()),
+			}
+			if location, err := deadLetterCfg.Location(); err != nil {
+				return goerr.Wrap(err, "invalid dead letter configuration")
+			} else if location != nil {
+				ucOptions = append(ucOptions, usecase.WithDeadLetter(location))
+			}
+
+			clients := adapter.New(adaptorOptions...)
+			var uc *usecase.UseCase
+			if len(dsts) > 0 {
+				// Route policy is bypassed and not loaded
+				uc = usecase.New(clients, ucOptions...)
+			} else if uc, err = policyCfg.newUseCase(ctx.Context, clients, ucOptions...); err != nil {
+				return err
+			}
+			defer uc.Close()
+
+			if err := uc.Copy(ctx.Context, *src, dsts); err != nil {
+				return goerr.Wrap(err, "fail to copy object").With("source", src)
+			}
+
+			return nil
+		},
+	}
+}
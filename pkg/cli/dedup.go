@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter/boltdb"
+	"github.com/secmon-lab/nydus/pkg/adapter/memory"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+	"github.com/urfave/cli/v2"
+)
+
+// dedupConfig is configuration of dedup store that skips transfers already completed
+type dedupConfig struct {
+	store        string
+	ttl          time.Duration
+	size         int
+	path         string
+	markerPrefix string
+}
+
+func (x *dedupConfig) Flags() []cli.Flag {
+	const category = "Dedup"
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "dedup",
+			Usage:       "Dedup store to skip transfers already completed for the same source version and destination, one of memory, file or marker. Disabled if not set",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_DEDUP"},
+			Destination: &x.store,
+		},
+		&cli.DurationFlag{
+			Name:        "dedup-ttl",
+			Usage:       "Period to remember a completed transfer. Never expire if 0",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_DEDUP_TTL"},
+			Value:       24 * time.Hour,
+			Destination: &x.ttl,
+		},
+		&cli.IntFlag{
+			Name:        "dedup-size",
+			Usage:       "Max number of completed transfers in memory dedup store. The least recently used one is evicted",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_DEDUP_SIZE"},
+			Value:       10000,
+			Destination: &x.size,
+		},
+		&cli.StringFlag{
+			Name:        "dedup-file",
+			Usage:       "File path of file dedup store database. It must be different from job store",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_DEDUP_FILE"},
+			Destination: &x.path,
+		},
+		&cli.StringFlag{
+			Name:        "dedup-marker-prefix",
+			Usage:       "Prefix of marker objects in the bucket or container of each destination for marker dedup store",
+			Category:    category,
+			EnvVars:     []string{"NYDUS_DEDUP_MARKER_PREFIX"},
+			Value:       ".nydus/transfers",
+			Destination: &x.markerPrefix,
+		},
+	}
+}
+
+func (x dedupConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("store", x.store),
+		slog.Duration("ttl", x.ttl),
+		slog.Int("size", x.size),
+		slog.String("path", x.path),
+		slog.String("markerPrefix", x.markerPrefix),
+	)
+}
+
+// usecaseOption creates dedup store. It returns nil option if dedup is disabled. Returned closer must be closed after use if not nil.
+func (x *dedupConfig) usecaseOption() (usecase.Option, io.Closer, error) {
+	if x.ttl < 0 {
+		return nil, nil, goerr.New("dedup TTL must not be negative").With("ttl", x.ttl)
+	}
+
+	switch x.store {
+	case "":
+		return nil, nil, nil
+
+	case "memory":
+		if x.size < 1 {
+			return nil, nil, goerr.New("dedup size must be 1 or more").With("size", x.size)
+		}
+		return usecase.WithDedupStore(memory.NewDedupStore(x.size, x.ttl)), nil, nil
+
+	case "file":
+		if x.path == "" {
+			return nil, nil, goerr.New("dedup file is required for file dedup store")
+		}
+		store, err := boltdb.New(x.path, boltdb.WithTransferTTL(x.ttl))
+		if err != nil {
+			return nil, nil, goerr.Wrap(err, "fail to open dedup store")
+		}
+		return usecase.WithDedupStore(store), store, nil
+
+	case "marker":
+		if x.markerPrefix == "" {
+			return nil, nil, goerr.New("dedup marker prefix is required for marker dedup store")
+		}
+		return usecase.WithDedupMarker(x.markerPrefix, x.ttl), nil, nil
+
+	default:
+		return nil, nil, goerr.New("invalid dedup store, must be one of memory, file or marker").With("store", x.store)
+	}
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/cli/config"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdEval() *cli.Command {
+	var (
+		policyCfg    policyConfig
+		policyEnvCfg config.PolicyEnv
+		storageCfg   storageConfig
+	)
+
+	var flags []cli.Flag
+	flags = append(flags, policyCfg.Flags()...)
+	flags = append(flags, policyEnvCfg.Flags()...)
+	// Storage clients are used only to fetch policy bundle from object storage
+	flags = append(flags, storageCfg.Flags()...)
+
+	return &cli.Command{
+		Name:      "eval",
+		Usage:     "Evaluate route policy with a route input or an event payload, and print the route output without transfer",
+		ArgsUsage: "[file of route input, Azure CloudEvent, Google Pub/Sub message or Amazon SNS message. Read from stdin if omitted]",
+		Flags:     flags,
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() > 1 {
+				return goerr.New("only one payload file can be specified")
+			}
+
+			var r io.Reader = os.Stdin
+			if path := ctx.Args().First(); path != "" && path != "-" {
+				f, err := os.Open(path)
+				if err != nil {
+					return goerr.Wrap(err, "fail to open payload file").With("path", path)
+				}
+				defer f.Close()
+				r = f
+			}
+
+			payload, err := io.ReadAll(r)
+			if err != nil {
+				return goerr.Wrap(err, "fail to read payload")
+			}
+
+			adaptorOptions, err := storageCfg.adapterOptions()
+			if err != nil {
+				return err
+			}
+
+			uc, err := policyCfg.newUseCase(ctx.Context, adapter.New(adaptorOptions...), usecase.WithEnvFilter(policyEnvCfg.Filter()))
+			if err != nil {
+				return err
+			}
+			defer uc.Close()
+
+			results, err := uc.EvaluatePayload(ctx.Context, payload)
+			if err != nil {
+				return err
+			}
+			if len(results) == 0 {
+				fmt.Fprintln(os.Stderr, "No route input is built from the payload. The event is ignored by server.")
+				return nil
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			for _, result := range results {
+				if err := enc.Encode(result.Output); err != nil {
+					return goerr.Wrap(err, "fail to encode route output")
+				}
+			}
+
+			return nil
+		},
+	}
+}
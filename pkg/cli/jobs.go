@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter/boltdb"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdJobs() *cli.Command {
+	var (
+		path   string
+		states cli.StringSlice
+		jobID  string
+	)
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "job-store",
+			Usage:       "File path of job store database",
+			EnvVars:     []string{"NYDUS_JOB_STORE"},
+			Required:    true,
+			Destination: &path,
+		},
+		&cli.StringSliceFlag{
+			Name:        "state",
+			Usage:       "Show only jobs in the state (pending, running, succeeded, failed)",
+			Destination: &states,
+		},
+		&cli.StringFlag{
+			Name:        "id",
+			Usage:       "Show only the job of the ID",
+			Destination: &jobID,
+		},
+	}
+
+	return &cli.Command{
+		Name:  "jobs",
+		Usage: "Show transfer jobs recorded in job store as JSON lines. The job store can not be opened while nydus server is running with it",
+		Flags: flags,
+		Action: func(ctx *cli.Context) error {
+			store, err := boltdb.New(path, boltdb.WithReadOnly())
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			var jobs []*model.Job
+			if jobID != "" {
+				job, err := store.GetJob(ctx.Context, model.JobID(jobID))
+				if err != nil {
+					return err
+				}
+				jobs = append(jobs, job)
+			} else {
+				var filter []model.JobState
+				for _, state := range states.Value() {
+					filter = append(filter, model.JobState(state))
+				}
+				if jobs, err = store.ListJobs(ctx.Context, filter...); err != nil {
+					return err
+				}
+			}
+
+			encoder := json.NewEncoder(os.Stdout)
+			for _, job := range jobs {
+				if err := encoder.Encode(job); err != nil {
+					return goerr.Wrap(err, "fail to encode job").With("id", job.ID)
+				}
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/adapter/policy"
+	"github.com/secmon-lab/nydus/pkg/cli/config"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+	"github.com/urfave/cli/v2"
+)
+
+// policyConfig is configuration of route policy shared by subcommands. Every subcommand loads the policy in the same way as server, then eval and test check exactly the policy that server runs.
+type policyConfig struct {
+	dir    string
+	bundle config.PolicyBundle
+}
+
+func (x *policyConfig) Flags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "policy-dir",
+			Aliases:     []string{"p"},
+			EnvVars:     []string{"NYDUS_POLICY_DIR"},
+			Usage:       "Directory path of policy files. It is ignored if policy bundle is configured",
+			Value:       "policy",
+			Destination: &x.dir,
+		},
+	}
+	return append(flags, x.bundle.Flags()...)
+}
+
+func (x policyConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("dir", x.dir),
+		slog.Any("bundle", x.bundle),
+	)
+}
+
+// isBundle returns true if route policy is loaded from OPA bundle instead of policy files
+func (x *policyConfig) isBundle() bool {
+	bundle, err := x.bundle.Bundle()
+	return err == nil && bundle != nil
+}
+
+// newUseCase creates usecase with route policy loaded from policy files or OPA bundle. Policy bundle is loaded after setting up usecase because it may be fetched from object storage.
+func (x *policyConfig) newUseCase(ctx context.Context, clients *adapter.Clients, options ...usecase.Option) (*usecase.UseCase, error) {
+	bundle, err := x.bundle.Bundle()
+	if err != nil {
+		return nil, goerr.Wrap(err, "invalid policy bundle configuration")
+	}
+
+	if bundle != nil {
+		options = append(options, usecase.WithPolicyBundle(bundle))
+	} else {
+		query, version, err := policy.Load(x.dir)
+		if err != nil {
+			return nil, goerr.Wrap(err, "fail to load policy files")
+		}
+		clients.SetPolicy(query, version)
+	}
+
+	uc := usecase.New(clients, options...)
+	if err := uc.LoadPolicyBundle(ctx); err != nil {
+		uc.Close()
+		return nil, goerr.Wrap(err, "fail to load policy bundle")
+	}
+
+	_, version := clients.Policy()
+	logging.Default().Info("policy is loaded", "version", version)
+
+	return uc, nil
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/cli/config"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdReplay() *cli.Command {
+	var (
+		dryRun      bool
+		concurrency int
+
+		policyCfg     policyConfig
+		storageCfg    storageConfig
+		retryCfg      config.Retry
+		deadLetterCfg config.DeadLetter
+		policyEnvCfg  config.PolicyEnv
+		checksumCfg   config.Checksum
+	)
+
+	flags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "Only evaluate policy and show destinations without transfer",
+			Destination: &dryRun,
+		},
+		&cli.IntFlag{
+			Name:        "concurrency",
+			Aliases:     []string{"c"},
+			Usage:       "Number of route inputs replayed concurrently",
+			Value:       4,
+			Destination: &concurrency,
+		},
+	}
+	flags = append(flags, policyCfg.Flags()...)
+	flags = append(flags, storageCfg.Flags()...)
+	flags = append(flags, retryCfg.Flags()...)
+	flags = append(flags, deadLetterCfg.Flags()...)
+	flags = append(flags, policyEnvCfg.Flags()...)
+	flags = append(flags, checksumCfg.Flags()...)
+
+	return &cli.Command{
+		Name:      "replay",
+		Usage:     "Replay stored route inputs, dead letters or jobs with the current policy",
+		ArgsUsage: "[file, directory, gs://bucket/prefix, s3://region/bucket/prefix or abs://account/container/prefix ...]",
+		Flags:     flags,
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() == 0 {
+				return goerr.New("no replay source is specified")
+			}
+
+			logger := logging.Default()
+			logger.Info("start replay",
+				"sources", ctx.Args().Slice(),
+				"policy", policyCfg,
+				"dryRun", dryRun,
+				"concurrency", concurrency,
+				"retry", retryCfg,
+				"policyEnv", policyEnvCfg,
+				"checksum", checksumCfg,
+				"deadLetter", deadLetterCfg,
+			)
+
+			adaptorOptions, err := storageCfg.adapterOptions()
+			if err != nil {
+				return err
+			}
+
+			retryPolicy, err := retryCfg.Policy()
+			if err != nil {
+				return goerr.Wrap(err, "invalid retry configuration")
+			}
+			ucOptions := []usecase.Option{
+				usecase.WithRetryPolicy(retryPolicy),
+				usecase.WithEnvFilter(policyEnvCfg.Filter()),
+				usecase.WithChecksumVerification(checksumCfg.Verify()),
+			}
+			if location, err := deadLetterCfg.Location(); err != nil {
+				return goerr.Wrap(err, "invalid dead letter configuration")
+			} else if location != nil {
+				ucOptions = append(ucOptions, usecase.WithDeadLetter(location))
+			}
+
+			uc, err := policyCfg.newUseCase(ctx.Context, adapter.New(adaptorOptions...), ucOptions...)
+			if err != nil {
+				return err
+			}
+			defer uc.Close()
+
+			report, err := uc.Replay(ctx.Context, ctx.Args().Slice(), dryRun, concurrency)
+			if report != nil {
+				for _, result := range report.Results {
+					switch {
+					case result.Err != nil:
+						fmt.Fprintf(os.Stdout, "FAIL %s: %s\n", result.Source, result.Err.Error())
+					case len(result.Destinations) == 0:
+						fmt.Fprintf(os.Stdout, "SKIP %s: no destination\n", result.Source)
+					default:
+						fmt.Fprintf(os.Stdout, "OK   %s -> %v\n", result.Source, result.Destinations)
+					}
+				}
+
+				succeeded, skipped, failed := report.Count()
+				label := "succeeded"
+				if dryRun {
+					label = "routed (dry-run)"
+				}
+				fmt.Fprintf(os.Stdout, "\nReplayed %d route inputs: %d %s, %d skipped, %d failed\n",
+					len(report.Results), succeeded, label, skipped, failed)
+
+				if err == nil && failed > 0 {
+					err = goerr.New("some route inputs failed to replay").With("failed", failed)
+				}
+			}
+
+			return err
+		},
+	}
+}
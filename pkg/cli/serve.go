@@ -1,12 +1,16 @@
 package cli
 
 import (
+	"context"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/m-mizutani/goerr"
-	"github.com/m-mizutani/opac"
 	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/adapter/policy"
 	"github.com/secmon-lab/nydus/pkg/cli/config"
 	"github.com/secmon-lab/nydus/pkg/controller/server"
 	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
@@ -14,10 +18,22 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+const (
+	// serverWriteTimeout is timeout of writing response. In synchronous mode, it includes time to transfer objects.
+	serverWriteTimeout = 20 * time.Second
+	// serverRequestTimeout is deadline of handling a request including transfer and its retries in synchronous mode. It is shorter than the write timeout to respond error before the connection is cut.
+	serverRequestTimeout = serverWriteTimeout - 2*time.Second
+)
+
 func cmdServe() *cli.Command {
 	var (
-		addr      string
-		policyDir string
+		addr                 string
+		policyReloadInterval time.Duration
+
+		async           bool
+		workers         int
+		queueSize       int
+		shutdownTimeout time.Duration
 	)
 
 	flags := []cli.Flag{
@@ -29,22 +45,68 @@ func cmdServe() *cli.Command {
 			Value:       "127.0.0.1:8080",
 			Destination: &addr,
 		},
-		&cli.StringFlag{
-			Name:        "policy-dir",
-			Aliases:     []string{"p"},
-			EnvVars:     []string{"NYDUS_POLICY_DIR"},
-			Usage:       "Directory path of policy files",
-			Value:       "policy",
-			Destination: &policyDir,
-			Required:    true,
+		&cli.DurationFlag{
+			Name:        "policy-reload-interval",
+			EnvVars:     []string{"NYDUS_POLICY_RELOAD_INTERVAL"},
+			Usage:       "Interval to check changes of policy files and reload them. Reloading is disabled if 0",
+			Destination: &policyReloadInterval,
+		},
+		&cli.BoolFlag{
+			Name:        "async",
+			EnvVars:     []string{"NYDUS_ASYNC"},
+			Usage:       "Enable asynchronous transfer. Requests are responded with 202 after the transfer job is enqueued",
+			Category:    "Transfer",
+			Destination: &async,
+		},
+		&cli.IntFlag{
+			Name:        "workers",
+			EnvVars:     []string{"NYDUS_WORKERS"},
+			Usage:       "Number of workers to perform asynchronous transfer",
+			Category:    "Transfer",
+			Value:       4,
+			Destination: &workers,
+		},
+		&cli.IntFlag{
+			Name:        "queue-size",
+			EnvVars:     []string{"NYDUS_QUEUE_SIZE"},
+			Usage:       "Max number of queued transfer jobs. Requests are responded with 503 if the queue is full",
+			Category:    "Transfer",
+			Value:       100,
+			Destination: &queueSize,
+		},
+		&cli.DurationFlag{
+			Name:        "shutdown-timeout",
+			EnvVars:     []string{"NYDUS_SHUTDOWN_TIMEOUT"},
+			Usage:       "Time to wait for queued and resumed transfer jobs on shutdown. Unfinished jobs are canceled after the timeout and resumed from job store at the next start",
+			Category:    "Transfer",
+			Value:       5 * time.Second,
+			Destination: &shutdownTimeout,
 		},
 	}
 
-	var azureCfg config.Azure
-	flags = append(flags, azureCfg.Flags()...)
+	var policyEnvCfg config.PolicyEnv
+	flags = append(flags, policyEnvCfg.Flags()...)
+
+	var checksumCfg config.Checksum
+	flags = append(flags, checksumCfg.Flags()...)
+
+	var policyCfg policyConfig
+	flags = append(flags, policyCfg.Flags()...)
 
-	var gcsCfg config.GoogleCloudStorage
-	flags = append(flags, gcsCfg.Flags()...)
+	var storageCfg storageConfig
+	flags = append(flags, storageCfg.Flags()...)
+
+	var jobStoreCfg config.JobStore
+	flags = append(flags, jobStoreCfg.Flags()...)
+
+	var retryCfg config.Retry
+	flags = append(flags, retryCfg.Flags()...)
+
+	var deadLetterCfg config.DeadLetter
+	flags = append(flags, deadLetterCfg.Flags()...)
+
+	var dedupCfg dedupConfig
+	flags = append(flags, dedupCfg.Flags()...)
 
 	return &cli.Command{
 		Name:    "serve",
@@ -56,52 +118,164 @@ func cmdServe() *cli.Command {
 			logger := logging.Default()
 			logger.Info("start nydus server",
 				"addr", addr,
-				"policyDir", policyDir,
-				"azure", azureCfg,
-				"gcs", gcsCfg,
+				"policy", policyCfg,
+				"policyReloadInterval", policyReloadInterval,
+				"policyEnv", policyEnvCfg,
+				"checksum", checksumCfg,
+				"async", async,
+				"workers", workers,
+				"queueSize", queueSize,
+				"azure", storageCfg.azure,
+				"gcs", storageCfg.gcs,
+				"s3", storageCfg.s3,
+				"jobStore", jobStoreCfg,
+				"retry", retryCfg,
+				"deadLetter", deadLetterCfg,
+				"dedup", dedupCfg,
 			)
 
-			policy, err := opac.New(opac.Files(policyDir))
+			adaptorOptions, err := storageCfg.adapterOptions()
 			if err != nil {
-				return goerr.Wrap(err, "fail to load policy files")
+				return err
 			}
 
-			adaptorOptions := []adapter.Option{
-				adapter.WithPolicy(policy),
+			// Setup job store
+			store, err := jobStoreCfg.NewClient()
+			if err != nil {
+				return goerr.Wrap(err, "fail to open job store")
+			} else if store != nil {
+				defer func() {
+					if err := store.Close(); err != nil {
+						logger.Error("fail to close job store", "error", err)
+					}
+				}()
+				adaptorOptions = append(adaptorOptions, adapter.WithJobStore(store))
 			}
 
-			// Setup Azure Blob Storage client
-			if client, err := azureCfg.NewClient(); err != nil {
-				return goerr.Wrap(err, "fail to create Azure Blob Storage client")
-			} else if client != nil {
-				adaptorOptions = append(adaptorOptions, adapter.WithAzureBlobStorage(client))
+			clients := adapter.New(adaptorOptions...)
+
+			ucOptions := []usecase.Option{
+				usecase.WithEnvFilter(policyEnvCfg.Filter()),
+				usecase.WithChecksumVerification(checksumCfg.Verify()),
 			}
 
-			// Setup Google Cloud Storage client
-			if client, err := gcsCfg.NewClient(); err != nil {
-				return goerr.Wrap(err, "fail to create Google Cloud Storage client")
-			} else if client != nil {
-				adaptorOptions = append(adaptorOptions, adapter.WithGoogleCloudStorage(client))
+			// Setup OIDC token verification for Pub/Sub push
+			if auth, err := storageCfg.gcs.PubSubAuth(); err != nil {
+				return goerr.Wrap(err, "invalid Pub/Sub authentication configuration")
+			} else if auth != nil {
+				ucOptions = append(ucOptions, usecase.WithGooglePubSubAuth(auth))
 			}
 
-			clients := adapter.New(adaptorOptions...)
+			// Setup authentication for Event Grid delivery
+			if auth, err := storageCfg.azure.EventGridAuth(); err != nil {
+				return goerr.Wrap(err, "invalid Event Grid authentication configuration")
+			} else if auth != nil {
+				ucOptions = append(ucOptions, usecase.WithAzureEventGridAuth(auth))
+			}
 
-			uc := usecase.New(clients)
+			// Setup retry of transfer
+			retryPolicy, err := retryCfg.Policy()
+			if err != nil {
+				return goerr.Wrap(err, "invalid retry configuration")
+			}
+			ucOptions = append(ucOptions, usecase.WithRetryPolicy(retryPolicy))
+
+			// Setup dead letter of failed transfer
+			if location, err := deadLetterCfg.Location(); err != nil {
+				return goerr.Wrap(err, "invalid dead letter configuration")
+			} else if location != nil {
+				ucOptions = append(ucOptions, usecase.WithDeadLetter(location))
+			}
+
+			// Setup dedup of transfers by duplicated events
+			if dedupCfg.path != "" && dedupCfg.path == jobStoreCfg.Path() {
+				return goerr.New("dedup file must be different from job store").With("path", dedupCfg.path)
+			}
+			if option, closer, err := dedupCfg.usecaseOption(); err != nil {
+				return goerr.Wrap(err, "invalid dedup configuration")
+			} else if option != nil {
+				if closer != nil {
+					defer func() {
+						if err := closer.Close(); err != nil {
+							logger.Error("fail to close dedup store", "error", err)
+						}
+					}()
+				}
+				ucOptions = append(ucOptions, option)
+			}
 
-			mux := server.New(uc)
+			// Setup asynchronous transfer
+			if async {
+				if workers < 1 {
+					return goerr.New("workers must be 1 or more").With("workers", workers)
+				}
+				if queueSize < 1 {
+					return goerr.New("queue size must be 1 or more").With("queueSize", queueSize)
+				}
+				ucOptions = append(ucOptions, usecase.WithTransferQueue(workers, queueSize))
+			}
 
-			logging.Default().Info("starting server", "addr", addr, "policyDir", policyDir)
+			// Server does not start without policy. After that, the last good policy is kept if the policy files or the bundle is broken.
+			uc, err := policyCfg.newUseCase(ctx.Context, clients, ucOptions...)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				// Jobs are not waited beyond the timeout to finish within the grace period of the platform. Jobs recorded in job store are resumed at the next start.
+				ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+				defer cancel()
+				uc.Shutdown(ctx)
+			}()
+
+			watchCtx, cancel := context.WithCancel(ctx.Context)
+			defer cancel()
+			if policyCfg.isBundle() {
+				go uc.WatchPolicyBundle(watchCtx)
+			} else if policyReloadInterval > 0 {
+				// Reload policy files if changed. Policy is replaced only if the files are compiled successfully.
+				_, version := clients.Policy()
+				go policy.Watch(watchCtx, policyCfg.dir, policyReloadInterval, version, clients.SetPolicy)
+			}
+
+			if err := uc.PurgeJobs(ctx.Context, jobStoreCfg.Retention()); err != nil {
+				return err
+			}
+			if err := uc.ResumeJobs(ctx.Context); err != nil {
+				return err
+			}
+
+			mux := server.New(uc, server.WithRequestTimeout(serverRequestTimeout))
+
+			logging.Default().Info("starting server", "addr", addr, "policy", policyCfg)
 
 			httpServer := &http.Server{
 				ReadTimeout:  20 * time.Second,
-				WriteTimeout: 20 * time.Second,
+				WriteTimeout: serverWriteTimeout,
 				IdleTimeout:  120 * time.Second,
 				Handler:      mux,
 				Addr:         addr,
 			}
 
-			if err := httpServer.ListenAndServe(); err != nil {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- httpServer.ListenAndServe()
+			}()
+
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+			select {
+			case err := <-errCh:
 				return goerr.Wrap(err, "fail to start server")
+
+			case sig := <-sigCh:
+				// Queued transfer jobs are finished or canceled by deferred uc.Shutdown() after the server is shut down. Requests still running after the timeout get 503 once the queue is closed.
+				logging.Default().Info("shutting down server", "signal", sig)
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+				if err := httpServer.Shutdown(ctx); err != nil {
+					return goerr.Wrap(err, "fail to shutdown server")
+				}
 			}
 
 			return nil
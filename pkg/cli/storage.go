@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/cli/config"
+	"github.com/urfave/cli/v2"
+)
+
+// storageConfig is configuration of object storage clients shared by subcommands
+type storageConfig struct {
+	azure config.Azure
+	gcs   config.GoogleCloudStorage
+	s3    config.AmazonS3
+}
+
+func (x *storageConfig) Flags() []cli.Flag {
+	var flags []cli.Flag
+	flags = append(flags, x.azure.Flags()...)
+	flags = append(flags, x.gcs.Flags()...)
+	flags = append(flags, x.s3.Flags()...)
+	return flags
+}
+
+// adapterOptions creates clients of enabled object storages
+func (x *storageConfig) adapterOptions() ([]adapter.Option, error) {
+	var options []adapter.Option
+
+	// Setup Azure Blob Storage client
+	if client, err := x.azure.NewClient(); err != nil {
+		return nil, goerr.Wrap(err, "fail to create Azure Blob Storage client")
+	} else if client != nil {
+		options = append(options, adapter.WithAzureBlobStorage(client))
+	}
+
+	// Setup Google Cloud Storage client
+	if client, err := x.gcs.NewClient(); err != nil {
+		return nil, goerr.Wrap(err, "fail to create Google Cloud Storage client")
+	} else if client != nil {
+		options = append(options, adapter.WithGoogleCloudStorage(client))
+	}
+
+	// Setup Amazon S3 client
+	if client, err := x.s3.NewClient(); err != nil {
+		return nil, goerr.Wrap(err, "fail to create Amazon S3 client")
+	} else if client != nil {
+		options = append(options, adapter.WithAmazonS3(client))
+	}
+
+	return options, nil
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/cli/config"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdTest() *cli.Command {
+	var (
+		fixtureDir string
+
+		policyCfg    policyConfig
+		policyEnvCfg config.PolicyEnv
+		storageCfg   storageConfig
+	)
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "fixture-dir",
+			Aliases:     []string{"f"},
+			Usage:       "Directory path of fixture files (*.json). A fixture has input (route input or event payload) and output (expected route output) fields",
+			Destination: &fixtureDir,
+		},
+	}
+	flags = append(flags, policyCfg.Flags()...)
+	flags = append(flags, policyEnvCfg.Flags()...)
+	// Storage clients are used only to fetch policy bundle from object storage
+	flags = append(flags, storageCfg.Flags()...)
+
+	return &cli.Command{
+		Name:  "test",
+		Usage: "Run Rego test rules in policy files and fixtures of route input and expected route output",
+		Flags: flags,
+		Action: func(ctx *cli.Context) error {
+			report := &model.PolicyTestReport{}
+
+			// Rego test rules are in policy files. Only fixtures are tested with policy bundle.
+			if policyCfg.isBundle() {
+				fmt.Fprintln(os.Stderr, "Rego test rules are not run because policy bundle is configured.")
+			} else {
+				results, err := usecase.TestRegoPolicy(ctx.Context, policyCfg.dir)
+				if err != nil {
+					return err
+				}
+				report.Results = append(report.Results, results...)
+			}
+
+			if fixtureDir != "" {
+				adaptorOptions, err := storageCfg.adapterOptions()
+				if err != nil {
+					return err
+				}
+
+				uc, err := policyCfg.newUseCase(ctx.Context, adapter.New(adaptorOptions...), usecase.WithEnvFilter(policyEnvCfg.Filter()))
+				if err != nil {
+					return err
+				}
+				defer uc.Close()
+
+				results, err := uc.TestPolicyFixtures(ctx.Context, fixtureDir)
+				if err != nil {
+					return err
+				}
+				report.Results = append(report.Results, results...)
+			}
+
+			for _, result := range report.Results {
+				switch {
+				case result.Skipped:
+					fmt.Fprintf(os.Stdout, "SKIP %s\n", result.Name)
+				case result.Passed():
+					fmt.Fprintf(os.Stdout, "PASS %s\n", result.Name)
+				default:
+					fmt.Fprintf(os.Stdout, "FAIL %s\n", result.Name)
+					if result.Err != nil {
+						fmt.Fprintf(os.Stdout, "    %s\n", result.Err.Error())
+						if goErr := goerr.Unwrap(result.Err); goErr != nil {
+							values := goErr.Values()
+							for _, k := range slices.Sorted(maps.Keys(values)) {
+								fmt.Fprintf(os.Stdout, "    %s: %v\n", k, values[k])
+							}
+						}
+					}
+					for _, line := range result.Diff {
+						fmt.Fprintf(os.Stdout, "    %s\n", line)
+					}
+				}
+			}
+
+			passed, failed, skipped := report.Count()
+			fmt.Fprintf(os.Stdout, "\n%d tests: %d passed, %d failed, %d skipped\n", len(report.Results), passed, failed, skipped)
+
+			if len(report.Results) == 0 {
+				return goerr.New("no test is found").With("policyDir", policyCfg.dir).With("fixtureDir", fixtureDir)
+			}
+			if failed > 0 {
+				return goerr.New("some policy tests failed").With("failed", failed)
+			}
+			return nil
+		},
+	}
+}
@@ -1,9 +1,13 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -14,34 +18,70 @@ import (
 
 type Server struct {
 	route *chi.Mux
+
+	// requestTimeout is deadline of handling a request. It is not limited if 0.
+	requestTimeout time.Duration
+}
+
+type Option func(*Server)
+
+// WithRequestTimeout sets deadline of handling a request. Context of the request is canceled at the deadline, then transfer and its retries in the request end and error is responded before the connection is cut by write timeout of the server.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(x *Server) {
+		x.requestTimeout = timeout
+	}
 }
 
 func (x *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	x.route.ServeHTTP(w, r)
 }
 
-func New(uc interfaces.UseCase) *Server {
+func New(uc interfaces.UseCase, options ...Option) *Server {
+	x := &Server{}
+	for _, opt := range options {
+		opt(x)
+	}
+
 	route := chi.NewRouter()
 	route.Use(middlewareLogging)
+	if x.requestTimeout > 0 {
+		route.Use(middlewareTimeout(x.requestTimeout))
+	}
 
 	route.Route("/google/pubsub", func(r chi.Router) {
-		r.Post("/cloud-storage", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusNotImplemented)
-		})
+		r.Use(middlewareGooglePubSubAuth(uc))
+		r.Post("/cloud-storage", handleGooglePubSubMessage(uc))
 	})
-	route.Route("/aws/sqs", func(r chi.Router) {
-		r.Post("/s3", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusNotImplemented)
-		})
+	route.Route("/aws/sns", func(r chi.Router) {
+		r.Post("/s3", handleAmazonSNSMessage(uc))
 	})
+	// Deprecated alias of /aws/sns/s3 for subscriptions created before the endpoint was renamed
+	route.Post("/aws/sqs/s3", handleAmazonSNSMessage(uc))
 	route.Route("/azure/cloud-event", func(r chi.Router) {
 		r.Options("/blob-storage", handleAzureCloudEventValidate(uc))
-		r.Post("/blob-storage", handleAzureCloudEventMessage(uc))
+		r.With(middlewareAzureEventGridAuth(uc)).Post("/blob-storage", handleAzureCloudEventMessage(uc))
 	})
 
-	return &Server{
-		route: route,
+	x.route = route
+	return x
+}
+
+// respondAccepted writes 202 Accepted if transfer is performed asynchronously, otherwise 200 OK
+func respondAccepted(w http.ResponseWriter, uc interfaces.UseCase) {
+	if uc.Async() {
+		w.WriteHeader(http.StatusAccepted)
+		return
 	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// respondError writes 503 Service Unavailable if transfer queue is full to make the event source retry later, otherwise 500 Internal Server Error
+func respondError(w http.ResponseWriter, err error) {
+	if errors.Is(err, model.ErrQueueFull) {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, "internal server error", http.StatusInternalServerError)
 }
 
 type statusWriter struct {
@@ -70,12 +110,92 @@ func middlewareLogging(next http.Handler) http.Handler {
 			"path", r.URL.Path,
 			"status", sw.status,
 			"remote_addr", r.RemoteAddr,
-			"header", r.Header,
+			"header", redactHeader(r.Header),
 			"user_agent", r.UserAgent(),
 		)
 	})
 }
 
+func middlewareTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// credentialHeaders are not logged because they contain credentials
+var credentialHeaders = []string{
+	"Authorization",
+	azureEventGridSecretHeader,
+}
+
+func redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, key := range credentialHeaders {
+		if redacted.Get(key) != "" {
+			redacted.Set(key, "[REDACTED]")
+		}
+	}
+	return redacted
+}
+
+const (
+	azureEventGridSecretQuery  = "secret"
+	azureEventGridSecretHeader = "X-Nydus-Secret"
+)
+
+func middlewareAzureEventGridAuth(uc interfaces.UseCase) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := logging.From(r.Context())
+
+			cred := &model.AzureEventGridCredential{
+				Secret: r.Header.Get(azureEventGridSecretHeader),
+			}
+			if cred.Secret == "" {
+				cred.Secret = r.URL.Query().Get(azureEventGridSecretQuery)
+			}
+			cred.Token, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+			if err := uc.VerifyAzureEventGridCredential(r.Context(), cred); err != nil {
+				logger.Warn("failed to authenticate Azure Event Grid delivery", "err", err)
+				if errors.Is(err, model.ErrForbidden) {
+					http.Error(w, "forbidden", http.StatusForbidden)
+				} else {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func middlewareGooglePubSubAuth(uc interfaces.UseCase) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := logging.From(r.Context())
+
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if err := uc.VerifyGooglePubSubToken(r.Context(), token); err != nil {
+				logger.Warn("failed to verify OIDC token of Google Pub/Sub", "err", err)
+				if errors.Is(err, model.ErrForbidden) {
+					http.Error(w, "forbidden", http.StatusForbidden)
+				} else {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 /*
 func handleAzureEventGridValidation(w http.ResponseWriter, r *http.Request) {
 	logger := logging.From(r.Context())
@@ -148,14 +268,14 @@ func handleAzureCloudEventMessage(uc interfaces.UseCase) http.HandlerFunc {
 		if ev.Type == "Microsoft.Storage.BlobCreated" {
 			if err := uc.HandleAzureCloudEvent(r.Context(), &ev); err != nil {
 				logger.Warn("failed to handle Azure CloudEvent", "err", err)
-				http.Error(w, "internal server error", http.StatusInternalServerError)
+				respondError(w, err)
 				return
 			}
 		} else {
 			logger.Warn("unexpected event type", "type", ev.Type)
 		}
 
-		w.WriteHeader(http.StatusOK)
+		respondAccepted(w, uc)
 	}
 }
 
@@ -177,3 +297,95 @@ func handleAzureCloudEventValidate(uc interfaces.UseCase) http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+func handleGooglePubSubMessage(uc interfaces.UseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.From(r.Context())
+
+		// Do not use json.Decoder to avoid missing the request body for logging
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Warn("failed to read request body from Google Pub/Sub", "err", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		var ev model.GooglePubSubEvent
+		if err := json.Unmarshal(body, &ev); err != nil {
+			logger.Warn("failed to unmarshal request body from Google Pub/Sub", "err", err, "body", string(body))
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if eventType := ev.Message.Attributes["eventType"]; eventType == "OBJECT_FINALIZE" {
+			if err := uc.HandleGooglePubSubEvent(r.Context(), &ev); err != nil {
+				logger.Warn("failed to handle Google Pub/Sub event", "err", err)
+				respondError(w, err)
+				return
+			}
+		} else {
+			logger.Info("ignore Google Cloud Storage event", "eventType", eventType)
+		}
+
+		respondAccepted(w, uc)
+	}
+}
+
+func handleAmazonSNSMessage(uc interfaces.UseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.From(r.Context())
+
+		// Do not use json.Decoder to avoid missing the request body for logging
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Warn("failed to read request body from Amazon SNS", "err", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		var ev model.AmazonSNSEvent
+		if err := json.Unmarshal(body, &ev); err != nil {
+			logger.Warn("failed to unmarshal request body from Amazon SNS", "err", err, "body", string(body))
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if err := uc.VerifyAmazonSNSMessage(r.Context(), &ev); err != nil {
+			logger.Warn("failed to verify Amazon SNS message",
+				"err", err,
+				"type", ev.Type,
+				"messageID", ev.MessageID,
+				"topicArn", ev.TopicArn,
+				"signingCertURL", ev.SigningCertURL,
+			)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		switch ev.Type {
+		case "SubscriptionConfirmation":
+			if err := uc.ConfirmAmazonSNSSubscription(r.Context(), &ev); err != nil {
+				logger.Warn("failed to confirm Amazon SNS subscription", "err", err)
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+
+		case "UnsubscribeConfirmation":
+			logger.Info("Amazon SNS subscription is unsubscribed", "topicArn", ev.TopicArn)
+
+		case "Notification":
+			if err := uc.HandleAmazonSNSEvent(r.Context(), &ev); err != nil {
+				logger.Warn("failed to handle Amazon SNS event", "err", err)
+				respondError(w, err)
+				return
+			}
+			respondAccepted(w, uc)
+			return
+
+		default:
+			logger.Warn("unexpected message type", "type", ev.Type)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
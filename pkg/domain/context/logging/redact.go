@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// redactHandler replaces secrets in log records, including values of goerr.Error, before passing them to the next handler
+type redactHandler struct {
+	next    slog.Handler
+	secrets model.Secrets
+}
+
+// NewRedactHandler returns a handler that redacts values of attributes whose key looks like a secret, and the secret values in any attribute and message
+func NewRedactHandler(next slog.Handler, secrets model.Secrets) slog.Handler {
+	// Secrets are also redacted in JSON encoded values, where some characters are escaped
+	var all model.Secrets
+	for _, secret := range secrets {
+		all = append(all, secret)
+		if raw, err := json.Marshal(secret); err == nil {
+			if escaped := strings.Trim(string(raw), `"`); escaped != secret {
+				all = append(all, escaped)
+			}
+		}
+	}
+
+	return &redactHandler{next: next, secrets: all}
+}
+
+func (x *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return x.next.Enabled(ctx, level)
+}
+
+func (x *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, x.secrets.Redact(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(x.redact(attr))
+		return true
+	})
+	return x.next.Handle(ctx, redacted)
+}
+
+func (x *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = x.redact(attr)
+	}
+	return &redactHandler{next: x.next.WithAttrs(redacted), secrets: x.secrets}
+}
+
+func (x *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: x.next.WithGroup(name), secrets: x.secrets}
+}
+
+func (x *redactHandler) redact(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+
+	switch value.Kind() {
+	case slog.KindGroup:
+		attrs := value.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, a := range attrs {
+			redacted[i] = x.redact(a)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+
+	case slog.KindString:
+		if model.IsSecretName(attr.Key) && value.String() != "" {
+			return slog.String(attr.Key, model.Redacted)
+		}
+		return slog.String(attr.Key, x.secrets.Redact(value.String()))
+
+	case slog.KindAny:
+		if model.IsSecretName(attr.Key) && value.Any() != nil {
+			return slog.String(attr.Key, model.Redacted)
+		}
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, x.secrets.Redact(err.Error()))
+		}
+		if len(x.secrets) == 0 {
+			return slog.Attr{Key: attr.Key, Value: value}
+		}
+
+		// Struct and map may have secrets in their fields. They are checked in JSON encoded form.
+		raw, err := json.Marshal(value.Any())
+		if err != nil {
+			if s := fmt.Sprint(value.Any()); x.secrets.Contains(s) {
+				return slog.String(attr.Key, x.secrets.Redact(s))
+			}
+			return slog.Attr{Key: attr.Key, Value: value}
+		}
+		if !x.secrets.Contains(string(raw)) {
+			return slog.Attr{Key: attr.Key, Value: value}
+		}
+
+		var redacted any
+		if err := json.Unmarshal([]byte(x.secrets.Redact(string(raw))), &redacted); err != nil {
+			return slog.String(attr.Key, model.Redacted)
+		}
+		return slog.Any(attr.Key, redacted)
+
+	default:
+		return slog.Attr{Key: attr.Key, Value: value}
+	}
+}
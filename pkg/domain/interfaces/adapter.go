@@ -4,6 +4,9 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"time"
+
+	"github.com/secmon-lab/nydus/pkg/domain/model"
 )
 
 type HTTPClient interface {
@@ -11,15 +14,48 @@ type HTTPClient interface {
 }
 
 type AzureBlobStorage interface {
-	NewReader(ctx context.Context, storageAccountName, containerName, blobName string) (io.ReadCloser, error)
+	// NewReader returns a reader of the blob. etag is ETag of the blob to read, or empty to read the latest blob. It returns model.ErrSourceChanged if the blob of etag no longer exists.
+	NewReader(ctx context.Context, storageAccountName, containerName, blobName, etag string) (io.ReadCloser, error)
+	NewWriter(ctx context.Context, storageAccountName, containerName, blobName string, attrs *model.ObjectAttrs, opts *model.AzureBlobStorageWriteOptions) (io.WriteCloser, error)
+	// List calls fn for each blob that has the prefix in lexical order of name. Blobs up to startAfter are skipped if it is not empty. Listing stops if fn returns error.
+	List(ctx context.Context, storageAccountName, containerName, prefix, startAfter string, fn func(*model.ObjectInfo) error) error
+	// Stat returns attributes of the blob. customerKey is the customer-provided key of the blob, or nil if it is not encrypted by the key. It returns model.ErrObjectNotFound if the blob does not exist.
+	Stat(ctx context.Context, storageAccountName, containerName, blobName string, customerKey []byte) (*model.ObjectStat, error)
 }
 
 type GoogleCloudStorage interface {
-	NewReader(ctx context.Context, bucketName, objectName string) (io.ReadCloser, error)
-	NewWriter(ctx context.Context, bucketName, objectName string) (io.WriteCloser, error)
+	// NewReader returns a reader of the object. generation is the generation of the object to read, or 0 to read the latest generation. It returns model.ErrSourceChanged if the object of generation no longer exists.
+	NewReader(ctx context.Context, bucketName, objectName string, generation int64) (io.ReadCloser, error)
+	NewWriter(ctx context.Context, bucketName, objectName string, attrs *model.ObjectAttrs, opts *model.GoogleCloudStorageWriteOptions) (io.WriteCloser, error)
+	// List calls fn for each object that has the prefix in lexical order of name. Objects up to startAfter are skipped if it is not empty. Listing stops if fn returns error.
+	List(ctx context.Context, bucketName, prefix, startAfter string, fn func(*model.ObjectInfo) error) error
+	// Stat returns attributes of the object. customerKey is the customer-supplied encryption key of the object, or nil if it is not encrypted by the key. It returns model.ErrObjectNotFound if the object does not exist.
+	Stat(ctx context.Context, bucketName, objectName string, customerKey []byte) (*model.ObjectStat, error)
 }
 
 type AmazonS3 interface {
-	NewReader(ctx context.Context, region, bucket, key string) (io.ReadCloser, error)
-	NewWriter(ctx context.Context, region, bucket, key string) (io.WriteCloser, error)
+	// NewReader returns a reader of the object. versionID or etag specifies the object to read, and the latest object is read if both are empty. It returns model.ErrSourceChanged if the specified object no longer exists.
+	NewReader(ctx context.Context, region, bucket, key, versionID, etag string) (io.ReadCloser, error)
+	NewWriter(ctx context.Context, region, bucket, key string, attrs *model.ObjectAttrs, opts *model.AmazonS3WriteOptions) (io.WriteCloser, error)
+	// List calls fn for each object that has the prefix in lexical order of key. Objects up to startAfter are skipped if it is not empty. Listing stops if fn returns error.
+	List(ctx context.Context, region, bucket, prefix, startAfter string, fn func(*model.ObjectInfo) error) error
+	// Stat returns attributes of the object. customerKey is the key of SSE-C, or nil if the object is not encrypted by the key. It returns model.ErrObjectNotFound if the object does not exist.
+	Stat(ctx context.Context, region, bucket, key string, customerKey []byte) (*model.ObjectStat, error)
+}
+
+// JobStore persists transfer jobs so that unfinished jobs can be resumed after restart
+type JobStore interface {
+	PutJob(ctx context.Context, job *model.Job) error
+	GetJob(ctx context.Context, id model.JobID) (*model.Job, error)
+	// ListJobs returns jobs in order of creation. All jobs are returned if no state is specified.
+	ListJobs(ctx context.Context, states ...model.JobState) ([]*model.Job, error)
+	// DeleteJobs deletes finished jobs updated before the time and returns number of deleted jobs
+	DeleteJobs(ctx context.Context, before time.Time) (int, error)
+}
+
+// DedupStore records completed transfers so that duplicated events do not transfer the same version of object again
+type DedupStore interface {
+	// HasTransfer returns true if the transfer of the key has been completed and the record is not expired
+	HasTransfer(ctx context.Context, key *model.TransferKey) (bool, error)
+	PutTransfer(ctx context.Context, key *model.TransferKey) error
 }
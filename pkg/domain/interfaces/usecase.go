@@ -7,7 +7,18 @@ import (
 )
 
 type UseCase interface {
+	// Async returns true if transfer is performed asynchronously after the request is accepted
+	Async() bool
+
 	ValidateAzureCloudEvent(ctx context.Context, callbackURL string) error
 
+	VerifyAzureEventGridCredential(ctx context.Context, cred *model.AzureEventGridCredential) error
 	HandleAzureCloudEvent(ctx context.Context, ev *model.CloudEventSchema) error
+
+	VerifyGooglePubSubToken(ctx context.Context, token string) error
+	HandleGooglePubSubEvent(ctx context.Context, ev *model.GooglePubSubEvent) error
+
+	VerifyAmazonSNSMessage(ctx context.Context, ev *model.AmazonSNSEvent) error
+	ConfirmAmazonSNSSubscription(ctx context.Context, ev *model.AmazonSNSEvent) error
+	HandleAmazonSNSEvent(ctx context.Context, ev *model.AmazonSNSEvent) error
 }
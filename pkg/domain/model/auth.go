@@ -0,0 +1,29 @@
+package model
+
+// GooglePubSubAuth is a configuration to verify Google-signed OIDC token attached to Pub/Sub push request
+type GooglePubSubAuth struct {
+	JWKSURL   string
+	Issuers   []string
+	Audiences []string
+	Emails    []string
+}
+
+// AzureEventGridAppID is application ID of Azure Event Grid first-party application that requests Entra ID token for webhook delivery
+const AzureEventGridAppID = "4962773b-9cdb-44cf-a8bf-237846a00ab7"
+
+// AzureEventGridAuth is a configuration to authenticate Azure Event Grid webhook delivery. Shared secret is verified if Secret is set, and Entra ID bearer token is verified if TenantID is set. Both are required if both are set.
+type AzureEventGridAuth struct {
+	Secret string
+
+	TenantID  string
+	Audiences []string
+	JWKSURL   string
+	// CallerAppIDs is allowed application IDs of the token requester (appid of v1 token or azp of v2 token). Only AzureEventGridAppID is allowed if empty.
+	CallerAppIDs []string
+}
+
+// AzureEventGridCredential is a credential attached to Azure Event Grid webhook delivery
+type AzureEventGridCredential struct {
+	Secret string
+	Token  string
+}
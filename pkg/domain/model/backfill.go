@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// BackfillOptions is options of backfill that routes existing objects under a prefix
+type BackfillOptions struct {
+	// Since and Until filter objects by updated time. Objects updated at Since or later and before Until are routed. Zero value means no limit.
+	Since time.Time
+	Until time.Time
+	// DryRun only evaluates the policy without transfer
+	DryRun      bool
+	Concurrency int
+	// Checkpoint is a file path to save progress of backfill. If the file exists, backfill resumes after the object recorded in it.
+	Checkpoint string
+}
+
+// InTimeRange returns true if the updated time of an object is in the range of Since and Until
+func (x BackfillOptions) InTimeRange(updatedAt time.Time) bool {
+	if !x.Since.IsZero() && updatedAt.Before(x.Since) {
+		return false
+	}
+	if !x.Until.IsZero() && !updatedAt.Before(x.Until) {
+		return false
+	}
+	return true
+}
+
+// BackfillCheckpoint is progress of backfill saved in a checkpoint file
+type BackfillCheckpoint struct {
+	// Source is URI of the prefix to backfill. A checkpoint can not be used for another source.
+	Source string `json:"source"`
+	// After is name of the last object that has been processed. All objects before it have been processed also.
+	After     string    `json:"after"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type BackfillReport struct {
+	DryRun bool
+	// Listed is number of listed objects including filtered ones
+	Listed int
+	// Filtered is number of objects that are out of time range
+	Filtered  int
+	Succeeded int
+	// Skipped is number of objects that have no destination
+	Skipped int
+	Failed  int
+	// Checkpoint is name of the last processed object
+	Checkpoint string
+}
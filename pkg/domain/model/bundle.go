@@ -0,0 +1,66 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+)
+
+// PolicyBundle is a location of OPA bundle to load route policy from
+type PolicyBundle struct {
+	// Source is URL of the bundle server (http or https) or URI of the bundle object, such as "gs://bucket/bundle.tar.gz"
+	Source string
+	// Interval is interval of polling the bundle. The bundle is loaded only at startup if 0.
+	Interval time.Duration
+	// Token is sent to the bundle server as bearer token if not empty
+	Token string
+	// Verification is a key to verify signature of the bundle. It is required unless Insecure is true.
+	Verification *PolicyBundleKey
+	// Insecure allows an unsigned bundle to be loaded without Verification. It is not allowed for a bundle server over plain HTTP.
+	Insecure bool
+}
+
+// PolicyBundleKey is a key to verify signature of OPA bundle in the same manner as OPA
+type PolicyBundleKey struct {
+	// Key is PEM encoded public key, or secret for HMAC algorithm
+	Key string
+	// KeyID is ID of the key. It must match "keyid" of the signature.
+	KeyID string
+	// Algorithm is signing algorithm such as RS256, ES256 and HS256
+	Algorithm string
+	// Scope is scope of the signature. It is not checked if empty.
+	Scope string
+}
+
+// IsHTTP returns true if the bundle is served by a bundle server
+func (x *PolicyBundle) IsHTTP() bool {
+	return strings.HasPrefix(x.Source, "http://") || strings.HasPrefix(x.Source, "https://")
+}
+
+// Validate checks that source is an HTTP URL or an object URI, and that the bundle is verified unless it is explicitly insecure
+func (x *PolicyBundle) Validate() error {
+	if x.Interval < 0 {
+		return goerr.New("bundle polling interval must not be negative").With("interval", x.Interval)
+	}
+	if x.Verification == nil {
+		// Bundle over plain HTTP can be replaced on the network, then it must be signed even if insecure
+		if strings.HasPrefix(x.Source, "http://") {
+			return goerr.New("verification key is required for bundle server over plain HTTP").With("source", x.Source)
+		}
+		if !x.Insecure {
+			return goerr.New("verification key is required for policy bundle unless insecure is set").With("source", x.Source)
+		}
+	}
+	if x.IsHTTP() {
+		return nil
+	}
+
+	if _, err := ParseDestination(x.Source); err != nil {
+		return goerr.Wrap(err, "bundle source must be http(s) URL or object URI").With("source", x.Source)
+	}
+	if x.Token != "" {
+		return goerr.New("bundle token is available only for bundle server").With("source", x.Source)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/m-mizutani/goerr"
+)
+
+// Checksum is size and digests of object content. Size is 0 and digests are nil if they are unknown.
+type Checksum struct {
+	Size   int64
+	MD5    []byte
+	CRC32C *uint32
+}
+
+func (x Checksum) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int64("size", x.Size),
+		slog.String("md5", hex.EncodeToString(x.MD5)),
+	}
+	if x.CRC32C != nil {
+		attrs = append(attrs, slog.Uint64("crc32c", uint64(*x.CRC32C)))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Verify compares actual checksum of copied content with x that is declared by the source. Only values known in x are compared. It returns ErrChecksumMismatch if any of them does not match.
+func (x *Checksum) Verify(actual *Checksum) error {
+	if x.Size > 0 && x.Size != actual.Size {
+		return goerr.Wrap(ErrChecksumMismatch, "size mismatch").With("expected", x.Size).With("actual", actual.Size)
+	}
+	if x.MD5 != nil && !bytes.Equal(x.MD5, actual.MD5) {
+		return goerr.Wrap(ErrChecksumMismatch, "MD5 mismatch").With("expected", hex.EncodeToString(x.MD5)).With("actual", hex.EncodeToString(actual.MD5))
+	}
+	if x.CRC32C != nil && actual.CRC32C != nil && *x.CRC32C != *actual.CRC32C {
+		return goerr.Wrap(ErrChecksumMismatch, "CRC32C mismatch").With("expected", *x.CRC32C).With("actual", *actual.CRC32C)
+	}
+	return nil
+}
+
+// Checksum returns size and digests of the source object declared in the event. Undeclared or malformed values are left unknown. ETag of Amazon S3 is not used as MD5, because ETag of an object uploaded by multipart upload or encrypted by KMS or customer key is not MD5 digest of the content.
+func (x *RouteInput) Checksum() *Checksum {
+	var sum Checksum
+
+	switch {
+	case x.AzureBlobStorage != nil:
+		sum.Size = x.AzureBlobStorage.Object.Size
+
+	case x.GoogleCloudStorage != nil:
+		obj := x.GoogleCloudStorage.Object
+		sum.Size = obj.Size
+		if md5, err := base64.StdEncoding.DecodeString(obj.MD5Hash); err == nil && len(md5) == 16 {
+			sum.MD5 = md5
+		}
+		if crc, err := base64.StdEncoding.DecodeString(obj.CRC32C); err == nil && len(crc) == 4 {
+			v := binary.BigEndian.Uint32(crc)
+			sum.CRC32C = &v
+		}
+
+	case x.AmazonS3 != nil:
+		sum.Size = x.AmazonS3.Object.Size
+	}
+
+	return &sum
+}
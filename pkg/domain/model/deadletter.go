@@ -0,0 +1,119 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/m-mizutani/goerr"
+)
+
+// DeadLetter is a record of transfer to a destination that failed finally. It has enough information to audit and replay the transfer.
+type DeadLetter struct {
+	ID          string         `json:"id"`
+	JobID       JobID          `json:"job_id"`
+	Input       *RouteInput    `json:"input"`
+	Destination Destination    `json:"destination"`
+	Attempts    int            `json:"attempts"`
+	Errors      []*ErrorRecord `json:"errors"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// ErrorRecord is an error in error chain. Values and stack trace are available only for goerr.Error.
+type ErrorRecord struct {
+	Message    string         `json:"message"`
+	Type       string         `json:"type"`
+	Values     map[string]any `json:"values,omitempty"`
+	StackTrace []string       `json:"stacktrace,omitempty"`
+}
+
+func NewDeadLetter(job *Job, transfer *JobTransfer, err error) *DeadLetter {
+	return &DeadLetter{
+		ID:          uuid.Must(uuid.NewV7()).String(),
+		JobID:       job.ID,
+		Input:       job.Input,
+		Destination: transfer.Destination,
+		Attempts:    transfer.Attempts,
+		Errors:      NewErrorChain(err),
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// Name returns object name of the dead letter, such as "2024/08/25/<id>.json"
+func (x *DeadLetter) Name() string {
+	return x.CreatedAt.Format("2006/01/02/") + x.ID + ".json"
+}
+
+// NewErrorChain returns errors from outermost to innermost. Joined errors are flattened in order.
+func NewErrorChain(err error) []*ErrorRecord {
+	var chain []*ErrorRecord
+	for err != nil {
+		record := &ErrorRecord{
+			Message: err.Error(),
+			Type:    fmt.Sprintf("%T", err),
+		}
+		chain = append(chain, record)
+
+		if ge, ok := err.(*goerr.Error); ok {
+			p := ge.Printable()
+			record.Message = p.Message
+			for k, v := range p.Values {
+				if record.Values == nil {
+					record.Values = map[string]any{}
+				}
+				// Values that can not be encoded to JSON are stored as string
+				if _, err := json.Marshal(v); err != nil {
+					v = fmt.Sprint(v)
+				}
+				record.Values[k] = v
+			}
+			for _, st := range p.StackTrace {
+				record.StackTrace = append(record.StackTrace, fmt.Sprintf("%s:%d %s", st.File, st.Line, st.Func))
+			}
+		}
+
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				chain = append(chain, NewErrorChain(e)...)
+			}
+			break
+		}
+		err = errors.Unwrap(err)
+	}
+
+	return chain
+}
+
+// DeadLetterLocation is where dead letters are written. Either Dir or Prefix is set.
+type DeadLetterLocation struct {
+	// Dir is a local directory
+	Dir string
+	// Prefix is a prefix of object in object storage
+	Prefix *Destination
+}
+
+// ParseDeadLetterLocation parses a local directory path (with or without "file://") or an object storage URI with prefix, such as "gs://bucket/dead-letter", "s3://region/bucket/dead-letter" or "abs://account/container/dead-letter"
+func ParseDeadLetterLocation(location string) (*DeadLetterLocation, error) {
+	if dir, ok := strings.CutPrefix(location, "file://"); ok {
+		return &DeadLetterLocation{Dir: dir}, nil
+	}
+	if !strings.Contains(location, "://") {
+		return &DeadLetterLocation{Dir: location}, nil
+	}
+
+	dst, err := ParseDestination(location)
+	if err != nil {
+		return nil, goerr.Wrap(err, "invalid dead letter location")
+	}
+	return &DeadLetterLocation{Prefix: dst}, nil
+}
+
+func (x DeadLetterLocation) String() string {
+	if x.Prefix != nil {
+		return x.Prefix.String()
+	}
+	return "file://" + x.Dir
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// TransferKey identifies a transfer of a version of source object to a destination. Transfers of the same key are duplicated, because Event Grid, Pub/Sub and SNS deliver an event at least once.
+type TransferKey struct {
+	// Source is URI of the source object
+	Source string `json:"source"`
+	// Version is ETag of Azure blob, generation of GCS object, or version ID (or ETag if versioning is disabled) of S3 object
+	Version string `json:"version"`
+	// Destination is URI of the destination object
+	Destination string `json:"destination"`
+}
+
+// NewTransferKey returns a key of transfer from the source object of input to dst. It returns nil if version of the source object is not available in input, because a transfer of unknown version can not be identified.
+func NewTransferKey(input *RouteInput, dst Destination) *TransferKey {
+	var source, version string
+	switch {
+	case input.AzureBlobStorage != nil:
+		obj := input.AzureBlobStorage.Object
+		source, version = obj.URI(), strings.Trim(obj.ETag, `"`)
+
+	case input.GoogleCloudStorage != nil:
+		obj := input.GoogleCloudStorage.Object
+		source = obj.URI()
+		if obj.Generation != 0 {
+			version = strconv.FormatInt(obj.Generation, 10)
+		}
+
+	case input.AmazonS3 != nil:
+		obj := input.AmazonS3.Object
+		source, version = obj.URI(), obj.VersionID
+		// ETag is quoted in listing but not in event notification
+		if version == "" {
+			version = strings.Trim(obj.ETag, `"`)
+		}
+	}
+
+	if version == "" {
+		return nil
+	}
+
+	return &TransferKey{
+		Source:      source,
+		Version:     version,
+		Destination: dst.String(),
+	}
+}
+
+// ID returns a fixed length ID of the key that can be used as a name of file or object
+func (x *TransferKey) ID() string {
+	h := sha256.New()
+	for _, v := range []string{x.Source, x.Version, x.Destination} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
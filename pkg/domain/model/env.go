@@ -0,0 +1,124 @@
+package model
+
+import (
+	"strings"
+)
+
+// Redacted replaces secret values in logs
+const Redacted = "[REDACTED]"
+
+// secretEnvNames are environment variables known to have secrets, of nydus and cloud SDKs
+var secretEnvNames = []string{
+	"NYDUS_AZURE_CLIENT_SECRET",
+	"NYDUS_AZURE_EVENTGRID_SECRET",
+	"NYDUS_S3_SECRET_ACCESS_KEY",
+	"NYDUS_S3_SESSION_TOKEN",
+	"NYDUS_POLICY_BUNDLE_TOKEN",
+	"NYDUS_POLICY_BUNDLE_VERIFICATION_KEY",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AZURE_CLIENT_SECRET",
+	"AZURE_CLIENT_CERTIFICATE_PASSWORD",
+	"AZURE_STORAGE_KEY",
+	"AZURE_STORAGE_CONNECTION_STRING",
+}
+
+// secretNameKeywords are parts of names that are likely to have secrets
+var secretNameKeywords = []string{
+	"SECRET",
+	"TOKEN",
+	"PASSWORD",
+	"PASSWD",
+	"PRIVATE_KEY",
+	"API_KEY",
+	"ACCESS_KEY",
+	"CREDENTIAL",
+	"CONNECTION_STRING",
+	"VERIFICATION_KEY",
+	"ENCRYPTION_KEY",
+	"CUSTOMER_KEY",
+}
+
+// minSecretLength is the shortest secret value to be redacted. Shorter values such as "true" are too common to be redacted in text.
+const minSecretLength = 6
+
+// IsSecretName returns true if the name of an environment variable or a log attribute looks like having a secret
+func IsSecretName(name string) bool {
+	upper := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	for _, known := range secretEnvNames {
+		if upper == known {
+			return true
+		}
+	}
+	for _, keyword := range secretNameKeywords {
+		if strings.Contains(upper, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnvFilter selects environment variables that are passed to route policy as input.env. Variables that have secrets are never passed even if allowed.
+type EnvFilter struct {
+	// Allow is a list of allowed variable names. A name ending with "*" is a prefix, such as "NYDUS_ROUTE_*". No variable is passed if empty.
+	Allow []string
+}
+
+func (x *EnvFilter) allowed(name string) bool {
+	for _, allow := range x.Allow {
+		if prefix, ok := strings.CutSuffix(allow, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == allow {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns allowed variables in environ, which is a list of "key=value" such as os.Environ()
+func (x *EnvFilter) Filter(environ []string) map[string]string {
+	env := map[string]string{}
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !x.allowed(name) || IsSecretName(name) {
+			continue
+		}
+		env[name] = value
+	}
+	return env
+}
+
+// Secrets is a set of secret values to be redacted from logs
+type Secrets []string
+
+// NewSecrets collects values of environment variables that have secrets in environ
+func NewSecrets(environ []string) Secrets {
+	var secrets Secrets
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if ok && len(value) >= minSecretLength && IsSecretName(name) {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+// Contains returns true if s has any of the secrets
+func (x Secrets) Contains(s string) bool {
+	for _, secret := range x {
+		if strings.Contains(s, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact replaces all secrets in s with Redacted
+func (x Secrets) Redact(s string) string {
+	for _, secret := range x {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
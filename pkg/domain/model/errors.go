@@ -0,0 +1,29 @@
+package model
+
+import "github.com/m-mizutani/goerr"
+
+var (
+	// ErrUnauthenticated indicates that the request has no or invalid credential
+	ErrUnauthenticated = goerr.New("unauthenticated")
+
+	// ErrForbidden indicates that the credential is valid but not allowed
+	ErrForbidden = goerr.New("forbidden")
+
+	// ErrQueueFull indicates that the transfer queue has no room for a new job
+	ErrQueueFull = goerr.New("transfer queue is full")
+
+	// ErrJobNotFound indicates that the job does not exist in the job store
+	ErrJobNotFound = goerr.New("job not found")
+
+	// ErrObjectNotFound indicates that the object does not exist in the storage
+	ErrObjectNotFound = goerr.New("object not found")
+
+	// ErrChecksumMismatch indicates that size or checksum of copied content does not match with the source object
+	ErrChecksumMismatch = goerr.New("checksum mismatch")
+
+	// ErrSourceChanged indicates that the source object of the event has been overwritten or deleted. The event is stale and the new object is transferred by its own event.
+	ErrSourceChanged = goerr.New("source object has been changed")
+
+	// ErrImmutabilityNotSet indicates that the object is committed but its immutability policy or legal hold can not be set. The transfer must not be retried, because the committed object may be already immutable.
+	ErrImmutabilityNotSet = goerr.New("immutability of committed object is not set")
+)
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JobID string
+
+func NewJobID() JobID {
+	// UUID v7 is sorted by creation time, so that jobs are listed in order of creation
+	return JobID(uuid.Must(uuid.NewV7()).String())
+}
+
+type JobState string
+
+const (
+	JobPending   JobState = "pending"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	// JobSkipped is a transfer that is not performed because the same transfer has been completed
+	JobSkipped JobState = "skipped"
+)
+
+// Finished returns true if the state will not be changed anymore
+func (x JobState) Finished() bool {
+	return x == JobSucceeded || x == JobFailed || x == JobSkipped
+}
+
+// Job is a record of routed transfer. A job has one source object and one or more destinations, and the state is tracked for each destination.
+type Job struct {
+	ID        JobID          `json:"id"`
+	Input     *RouteInput    `json:"input"`
+	Transfers []*JobTransfer `json:"transfers"`
+	State     JobState       `json:"state"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// JobTransfer is a transfer of a job to a destination
+type JobTransfer struct {
+	Destination Destination `json:"destination"`
+	State       JobState    `json:"state"`
+	Attempts    int         `json:"attempts"`
+	Bytes       int64       `json:"bytes"`
+	LastError   string      `json:"last_error,omitempty"`
+}
+
+func NewJob(input *RouteInput, dsts []Destination) *Job {
+	now := time.Now()
+	job := &Job{
+		ID:        NewJobID(),
+		Input:     input,
+		State:     JobPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, dst := range dsts {
+		job.Transfers = append(job.Transfers, &JobTransfer{
+			Destination: dst,
+			State:       JobPending,
+		})
+	}
+	return job
+}
+
+// Unfinished returns transfers that have not been finished
+func (x *Job) Unfinished() []*JobTransfer {
+	var transfers []*JobTransfer
+	for _, t := range x.Transfers {
+		if !t.State.Finished() {
+			transfers = append(transfers, t)
+		}
+	}
+	return transfers
+}
+
+// UpdateState sets state of the job from states of transfers. The job is failed if any transfer is failed after all transfers are finished. Skipped transfers are regarded as succeeded.
+func (x *Job) UpdateState() {
+	x.UpdatedAt = time.Now()
+
+	counts := map[JobState]int{}
+	for _, t := range x.Transfers {
+		counts[t.State]++
+	}
+
+	switch {
+	case counts[JobPending] == len(x.Transfers):
+		x.State = JobPending
+	case counts[JobPending] > 0 || counts[JobRunning] > 0:
+		x.State = JobRunning
+	case counts[JobFailed] > 0:
+		x.State = JobFailed
+	default:
+		x.State = JobSucceeded
+	}
+}
@@ -1,5 +1,7 @@
 package model
 
+import "fmt"
+
 type StorageType string
 
 const (
@@ -21,6 +23,12 @@ type AzureBlobStorageObject struct {
 	Size           int64  `json:"size"`
 	ContentType    string `json:"content_type"`
 	ETag           string `json:"etag"`
+
+	// IfExists, Attributes and write options are used only for destination
+	IfExists   IfExists     `json:"if_exists,omitempty"`
+	Attributes *ObjectAttrs `json:"attributes,omitempty"`
+
+	AzureBlobStorageWriteOptions
 }
 
 // CloudEventSchema is a struct for Azure Event Grid CloudEvent schema
@@ -68,23 +76,142 @@ type GoogleCloudStorageEvent struct {
 
 // GoogleCloudStorageObject is a struct for Google Cloud Storage object
 type GoogleCloudStorageObject struct {
-	Bucket string `json:"bucket"`
-	Name   string `json:"name"`
+	Bucket      string            `json:"bucket"`
+	Name        string            `json:"name"`
+	Generation  int64             `json:"generation"`
+	Size        int64             `json:"size"`
+	ContentType string            `json:"content_type"`
+	MD5Hash     string            `json:"md5_hash"`
+	CRC32C      string            `json:"crc32c"`
+	Metadata    map[string]string `json:"metadata"`
+
+	// IfExists, Attributes and write options are used only for destination
+	IfExists   IfExists     `json:"if_exists,omitempty"`
+	Attributes *ObjectAttrs `json:"attributes,omitempty"`
+
+	GoogleCloudStorageWriteOptions
 }
 
+// GooglePubSubEvent is a struct for Google Cloud Pub/Sub push message envelope
 type GooglePubSubEvent struct {
+	Message      GooglePubSubMessage `json:"message"`
+	Subscription string              `json:"subscription"`
+}
+
+type GooglePubSubMessage struct {
+	Attributes  map[string]string `json:"attributes"`
+	Data        []byte            `json:"data"`
+	MessageID   string            `json:"messageId"`
+	PublishTime string            `json:"publishTime"`
+}
+
+// GoogleCloudStorageNotification is a struct for the payload of Google Cloud Storage Pub/Sub notification. It is the object resource of JSON API v1 (payload format "JSON_API_V1").
+type GoogleCloudStorageNotification struct {
+	Kind           string            `json:"kind"`
+	ID             string            `json:"id"`
+	SelfLink       string            `json:"selfLink"`
+	Name           string            `json:"name"`
+	Bucket         string            `json:"bucket"`
+	Generation     string            `json:"generation"`
+	Metageneration string            `json:"metageneration"`
+	ContentType    string            `json:"contentType"`
+	TimeCreated    string            `json:"timeCreated"`
+	Updated        string            `json:"updated"`
+	StorageClass   string            `json:"storageClass"`
+	Size           string            `json:"size"`
+	MD5Hash        string            `json:"md5Hash"`
+	MediaLink      string            `json:"mediaLink"`
+	CRC32C         string            `json:"crc32c"`
+	ETag           string            `json:"etag"`
+	Metadata       map[string]string `json:"metadata"`
 }
 
 type AmazonS3Event struct {
-	Event  AmazonSNSEvent `json:"event"`
-	Object AmazonS3Object `json:"object"`
+	Event  AmazonSNSEvent      `json:"event"`
+	Record AmazonS3EventRecord `json:"record"`
+	Object AmazonS3Object      `json:"object"`
 }
 
+// AmazonSNSEvent is a struct for Amazon SNS message delivered to HTTP(S) endpoint
 type AmazonSNSEvent struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token,omitempty"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject,omitempty"`
+	Message          string `json:"Message"`
+	SubscribeURL     string `json:"SubscribeURL,omitempty"`
+	UnsubscribeURL   string `json:"UnsubscribeURL,omitempty"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// AmazonS3EventMessage is a struct for Amazon S3 event notification message
+type AmazonS3EventMessage struct {
+	Records []AmazonS3EventRecord `json:"Records"`
+}
+
+type AmazonS3EventRecord struct {
+	EventVersion string `json:"eventVersion"`
+	EventSource  string `json:"eventSource"`
+	AWSRegion    string `json:"awsRegion"`
+	EventTime    string `json:"eventTime"`
+	EventName    string `json:"eventName"`
+	UserIdentity struct {
+		PrincipalID string `json:"principalId"`
+	} `json:"userIdentity"`
+	RequestParameters struct {
+		SourceIPAddress string `json:"sourceIPAddress"`
+	} `json:"requestParameters"`
+	ResponseElements map[string]string `json:"responseElements"`
+	S3               struct {
+		S3SchemaVersion string `json:"s3SchemaVersion"`
+		ConfigurationID string `json:"configurationId"`
+		Bucket          struct {
+			Name          string `json:"name"`
+			OwnerIdentity struct {
+				PrincipalID string `json:"principalId"`
+			} `json:"ownerIdentity"`
+			ARN string `json:"arn"`
+		} `json:"bucket"`
+		Object struct {
+			Key       string `json:"key"`
+			Size      int64  `json:"size"`
+			ETag      string `json:"eTag"`
+			VersionID string `json:"versionId"`
+			Sequencer string `json:"sequencer"`
+		} `json:"object"`
+	} `json:"s3"`
 }
 
 type AmazonS3Object struct {
-	Region string `json:"region"`
-	Bucket string `json:"bucket"`
-	Key    string `json:"key"`
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	Size      int64  `json:"size"`
+	ETag      string `json:"etag"`
+	VersionID string `json:"version_id"`
+
+	// IfExists, Attributes and write options are used only for destination
+	IfExists   IfExists     `json:"if_exists,omitempty"`
+	Attributes *ObjectAttrs `json:"attributes,omitempty"`
+
+	AmazonS3WriteOptions
+}
+
+// URI returns URI of the blob. Example: "abs://account/container/blob"
+func (x AzureBlobStorageObject) URI() string {
+	return fmt.Sprintf("abs://%s/%s/%s", x.StorageAccount, x.Container, x.BlobName)
+}
+
+// URI returns URI of the object. Example: "gs://bucket/name"
+func (x GoogleCloudStorageObject) URI() string {
+	return fmt.Sprintf("gs://%s/%s", x.Bucket, x.Name)
+}
+
+// URI returns URI of the object. Example: "s3://region/bucket/key"
+func (x AmazonS3Object) URI() string {
+	return fmt.Sprintf("s3://%s/%s/%s", x.Region, x.Bucket, x.Key)
 }
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"slices"
+	"strings"
+)
+
+// MetadataSourceURI is the metadata key of destination objects that has URI of the source object
+const MetadataSourceURI = "nydus-source-uri"
+
+// ForStorage returns a copy of x of which metadata keys are converted to valid ones for the storage.
+//   - Google Cloud Storage: Keys are kept as is.
+//   - Amazon S3: Keys are lower-cased, because S3 stores them as lower-cased x-amz-meta-* headers. The "x-amz-meta-" prefix is removed if exists.
+//   - Azure Blob Storage: Keys must be valid C# identifiers and are case-insensitive. Keys are lower-cased and invalid characters are replaced with underscore, such as "nydus_source_uri".
+//
+// If multiple keys are converted into the same key, the first one in lexical order of original keys is used.
+func (x *ObjectAttrs) ForStorage(storage StorageType) *ObjectAttrs {
+	converted := *x
+	if len(x.Metadata) == 0 {
+		return &converted
+	}
+
+	var convert func(string) string
+	switch storage {
+	case S3Storage:
+		convert = s3MetadataKey
+	case AzureBlobStorage:
+		convert = absMetadataKey
+	default:
+		convert = func(key string) string { return key }
+	}
+
+	keys := make([]string, 0, len(x.Metadata))
+	for k := range x.Metadata {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	converted.Metadata = make(map[string]string, len(keys))
+	for _, k := range keys {
+		key := convert(k)
+		if _, ok := converted.Metadata[key]; ok || key == "" {
+			continue
+		}
+		converted.Metadata[key] = x.Metadata[k]
+	}
+	return &converted
+}
+
+func s3MetadataKey(key string) string {
+	key = strings.ToLower(key)
+	return strings.TrimPrefix(key, "x-amz-meta-")
+}
+
+// absMetadataKey converts key to valid metadata name of Azure Blob Storage. The name must be a valid C# identifier, then invalid characters are replaced with underscore.
+func absMetadataKey(key string) string {
+	var b strings.Builder
+	for i, c := range strings.ToLower(key) {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z':
+			b.WriteRune(c)
+		case '0' <= c && c <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"bytes"
+	"maps"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+)
+
+// ObjectAttrs is attributes of an object to be written into destination storage. It is also used to override attributes of a destination object in route policy.
+type ObjectAttrs struct {
+	ContentType        string            `json:"content_type,omitempty"`
+	ContentEncoding    string            `json:"content_encoding,omitempty"`
+	CacheControl       string            `json:"cache_control,omitempty"`
+	ContentDisposition string            `json:"content_disposition,omitempty"`
+	ContentLanguage    string            `json:"content_language,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+
+	// MD5 and CRC32C are expected checksums of the content. Storage that supports server-side verification rejects the upload if the content does not match. They are nil if unknown.
+	MD5    []byte  `json:"-"`
+	CRC32C *uint32 `json:"-"`
+}
+
+// Merge returns a copy of x overridden by y. Non-empty fields of y replace ones of x. Metadata of y is merged into metadata of x, and a key with empty value is removed.
+func (x *ObjectAttrs) Merge(y *ObjectAttrs) *ObjectAttrs {
+	merged := *x
+	merged.Metadata = maps.Clone(x.Metadata)
+	if y == nil {
+		return &merged
+	}
+
+	for _, v := range []struct {
+		dst *string
+		src string
+	}{
+		{&merged.ContentType, y.ContentType},
+		{&merged.ContentEncoding, y.ContentEncoding},
+		{&merged.CacheControl, y.CacheControl},
+		{&merged.ContentDisposition, y.ContentDisposition},
+		{&merged.ContentLanguage, y.ContentLanguage},
+	} {
+		if v.src != "" {
+			*v.dst = v.src
+		}
+	}
+
+	for k, v := range y.Metadata {
+		if v == "" {
+			delete(merged.Metadata, k)
+			continue
+		}
+		if merged.Metadata == nil {
+			merged.Metadata = map[string]string{}
+		}
+		merged.Metadata[k] = v
+	}
+
+	return &merged
+}
+
+// ObjectInfo is an object found by listing objects in storage
+type ObjectInfo struct {
+	Name      string
+	Size      int64
+	ETag      string
+	UpdatedAt time.Time
+}
+
+// ObjectStat is attributes of an existing object returned by Stat of storage client
+type ObjectStat struct {
+	Size int64
+	ETag string
+	// MD5 is MD5 digest of the content. It is nil if not available, such as an object uploaded by multipart upload.
+	MD5 []byte
+	// CRC32C is CRC32C checksum of the content. It is nil if not available.
+	CRC32C             *uint32
+	ContentType        string
+	ContentEncoding    string
+	CacheControl       string
+	ContentDisposition string
+	ContentLanguage    string
+	Metadata           map[string]string
+	UpdatedAt          time.Time
+}
+
+// Attrs returns content headers and metadata of the object to write them into another object
+func (x *ObjectStat) Attrs() *ObjectAttrs {
+	return &ObjectAttrs{
+		ContentType:        x.ContentType,
+		ContentEncoding:    x.ContentEncoding,
+		CacheControl:       x.CacheControl,
+		ContentDisposition: x.ContentDisposition,
+		ContentLanguage:    x.ContentLanguage,
+		Metadata:           maps.Clone(x.Metadata),
+	}
+}
+
+// Identical returns true if content of the objects is the same. Size and at least one checksum available in both must match. It returns false if there is no common checksum, because the content can not be compared.
+func (x *ObjectStat) Identical(y *ObjectStat) bool {
+	if x.Size != y.Size {
+		return false
+	}
+
+	var compared bool
+	if x.MD5 != nil && y.MD5 != nil {
+		if !bytes.Equal(x.MD5, y.MD5) {
+			return false
+		}
+		compared = true
+	}
+	if x.CRC32C != nil && y.CRC32C != nil {
+		if *x.CRC32C != *y.CRC32C {
+			return false
+		}
+		compared = true
+	}
+	return compared
+}
+
+// IfExists is behavior of transfer when the destination object already exists
+type IfExists string
+
+const (
+	// IfExistsOverwrite overwrites the existing object. It is the default.
+	IfExistsOverwrite IfExists = "overwrite"
+	// IfExistsSkip skips the transfer
+	IfExistsSkip IfExists = "skip"
+	// IfExistsSkipIfIdentical skips the transfer if size and checksum of the existing object are the same as the source object
+	IfExistsSkipIfIdentical IfExists = "skip_if_identical"
+	// IfExistsFail fails the transfer without retry
+	IfExistsFail IfExists = "fail"
+)
+
+func (x IfExists) Validate() error {
+	switch x {
+	case IfExistsOverwrite, IfExistsSkip, IfExistsSkipIfIdentical, IfExistsFail:
+		return nil
+	default:
+		return goerr.New("invalid if_exists, must be one of overwrite, skip, skip_if_identical or fail").With("if_exists", x)
+	}
+}
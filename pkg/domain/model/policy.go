@@ -1,5 +1,14 @@
 package model
 
+import (
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/m-mizutani/goerr"
+)
+
 type RouteInput struct {
 	AzureBlobStorage   *AzureBlobStorageEvent   `json:"abs"`
 	GoogleCloudStorage *GoogleCloudStorageEvent `json:"gcs"`
@@ -12,3 +21,173 @@ type RouteOutput struct {
 	GoogleCloudStorage []GoogleCloudStorageObject `json:"gcs"`
 	AmazonS3Storage    []AmazonS3Object           `json:"s3"`
 }
+
+// EvalResult is a pair of route input and output of the route policy
+type EvalResult struct {
+	Input  *RouteInput  `json:"input"`
+	Output *RouteOutput `json:"output"`
+}
+
+// Destination is a destination of object transfer. Only one of the fields is set.
+type Destination struct {
+	AzureBlobStorage   *AzureBlobStorageObject   `json:"abs,omitempty"`
+	GoogleCloudStorage *GoogleCloudStorageObject `json:"gcs,omitempty"`
+	AmazonS3           *AmazonS3Object           `json:"s3,omitempty"`
+}
+
+// Destinations returns all destinations in the route output
+func (x RouteOutput) Destinations() []Destination {
+	var dsts []Destination
+	for i := range x.GoogleCloudStorage {
+		dsts = append(dsts, Destination{GoogleCloudStorage: &x.GoogleCloudStorage[i]})
+	}
+	for i := range x.AmazonS3Storage {
+		dsts = append(dsts, Destination{AmazonS3: &x.AmazonS3Storage[i]})
+	}
+	for i := range x.AzureBlobStorage {
+		dsts = append(dsts, Destination{AzureBlobStorage: &x.AzureBlobStorage[i]})
+	}
+	return dsts
+}
+
+// String returns URI of the destination. Examples: "gs://bucket/name", "s3://region/bucket/key", "abs://account/container/blob"
+func (x Destination) String() string {
+	switch {
+	case x.GoogleCloudStorage != nil:
+		return x.GoogleCloudStorage.URI()
+	case x.AmazonS3 != nil:
+		return x.AmazonS3.URI()
+	case x.AzureBlobStorage != nil:
+		return x.AzureBlobStorage.URI()
+	default:
+		return ""
+	}
+}
+
+func (x Destination) LogValue() slog.Value {
+	return slog.StringValue(x.String())
+}
+
+// ParseDestination parses URI of an object in the same format as Destination.String()
+func ParseDestination(uri string) (*Destination, error) {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		return nil, goerr.New("invalid object URI").With("uri", uri)
+	}
+
+	// Number of path elements before object name
+	n := map[string]int{"gs": 1, "s3": 2, "abs": 2}[scheme]
+	if n == 0 {
+		return nil, goerr.New("unsupported scheme of object URI, it must be gs, s3 or abs").With("uri", uri)
+	}
+
+	parts := strings.SplitN(rest, "/", n+1)
+	if len(parts) != n+1 || slices.Contains(parts, "") {
+		return nil, goerr.New("invalid object URI").With("uri", uri)
+	}
+
+	switch scheme {
+	case "gs":
+		return &Destination{GoogleCloudStorage: &GoogleCloudStorageObject{Bucket: parts[0], Name: parts[1]}}, nil
+	case "s3":
+		return &Destination{AmazonS3: &AmazonS3Object{Region: parts[0], Bucket: parts[1], Key: parts[2]}}, nil
+	default:
+		return &Destination{AzureBlobStorage: &AzureBlobStorageObject{StorageAccount: parts[0], Container: parts[1], BlobName: parts[2]}}, nil
+	}
+}
+
+// Join returns a destination of which object name is joined with name. It is used to place an object under the destination as a prefix.
+func (x Destination) Join(name string) Destination {
+	switch {
+	case x.GoogleCloudStorage != nil:
+		obj := *x.GoogleCloudStorage
+		obj.Name = path.Join(obj.Name, name)
+		return Destination{GoogleCloudStorage: &obj}
+	case x.AmazonS3 != nil:
+		obj := *x.AmazonS3
+		obj.Key = path.Join(obj.Key, name)
+		return Destination{AmazonS3: &obj}
+	case x.AzureBlobStorage != nil:
+		obj := *x.AzureBlobStorage
+		obj.BlobName = path.Join(obj.BlobName, name)
+		return Destination{AzureBlobStorage: &obj}
+	default:
+		return x
+	}
+}
+
+// IfExists returns behavior of transfer when the destination object already exists. Default is IfExistsOverwrite.
+func (x Destination) IfExists() IfExists {
+	var v IfExists
+	switch {
+	case x.GoogleCloudStorage != nil:
+		v = x.GoogleCloudStorage.IfExists
+	case x.AmazonS3 != nil:
+		v = x.AmazonS3.IfExists
+	case x.AzureBlobStorage != nil:
+		v = x.AzureBlobStorage.IfExists
+	}
+	if v == "" {
+		return IfExistsOverwrite
+	}
+	return v
+}
+
+// Attributes returns attributes of the destination object specified by route policy. It is nil if not specified.
+func (x Destination) Attributes() *ObjectAttrs {
+	switch {
+	case x.GoogleCloudStorage != nil:
+		return x.GoogleCloudStorage.Attributes
+	case x.AmazonS3 != nil:
+		return x.AmazonS3.Attributes
+	case x.AzureBlobStorage != nil:
+		return x.AzureBlobStorage.Attributes
+	default:
+		return nil
+	}
+}
+
+// Root returns a destination of the bucket or the container of the destination, with empty object name
+func (x Destination) Root() Destination {
+	switch {
+	case x.GoogleCloudStorage != nil:
+		return Destination{GoogleCloudStorage: &GoogleCloudStorageObject{Bucket: x.GoogleCloudStorage.Bucket}}
+	case x.AmazonS3 != nil:
+		return Destination{AmazonS3: &AmazonS3Object{Region: x.AmazonS3.Region, Bucket: x.AmazonS3.Bucket}}
+	case x.AzureBlobStorage != nil:
+		return Destination{AzureBlobStorage: &AzureBlobStorageObject{StorageAccount: x.AzureBlobStorage.StorageAccount, Container: x.AzureBlobStorage.Container}}
+	default:
+		return x
+	}
+}
+
+// Source returns the source object of the input in the same form as destination. It returns nil if the input has no source object.
+func (x *RouteInput) Source() *Destination {
+	switch {
+	case x.GoogleCloudStorage != nil:
+		obj := x.GoogleCloudStorage.Object
+		return &Destination{GoogleCloudStorage: &obj}
+	case x.AmazonS3 != nil:
+		obj := x.AmazonS3.Object
+		return &Destination{AmazonS3: &obj}
+	case x.AzureBlobStorage != nil:
+		obj := x.AzureBlobStorage.Object
+		return &Destination{AzureBlobStorage: &obj}
+	default:
+		return nil
+	}
+}
+
+// NewRouteInput returns a route input for the object as if a notification of the object is received. Fields of the event are left empty.
+func NewRouteInput(obj Destination) *RouteInput {
+	switch {
+	case obj.GoogleCloudStorage != nil:
+		return &RouteInput{GoogleCloudStorage: &GoogleCloudStorageEvent{Object: *obj.GoogleCloudStorage}}
+	case obj.AmazonS3 != nil:
+		return &RouteInput{AmazonS3: &AmazonS3Event{Object: *obj.AmazonS3}}
+	case obj.AzureBlobStorage != nil:
+		return &RouteInput{AzureBlobStorage: &AzureBlobStorageEvent{Object: *obj.AzureBlobStorage}}
+	default:
+		return &RouteInput{}
+	}
+}
@@ -0,0 +1,34 @@
+package model
+
+// PolicyTestResult is a result of a fixture or a Rego test rule
+type PolicyTestResult struct {
+	// Name is path of the fixture file or "package.rule" of the Rego test
+	Name    string
+	Skipped bool
+	// Diff is difference between expected and actual route output. A line starts with "-" for missing destination and "+" for unexpected destination.
+	Diff []string
+	Err  error
+}
+
+func (x *PolicyTestResult) Passed() bool {
+	return !x.Skipped && len(x.Diff) == 0 && x.Err == nil
+}
+
+type PolicyTestReport struct {
+	Results []*PolicyTestResult
+}
+
+// Count returns number of passed, failed and skipped tests
+func (x *PolicyTestReport) Count() (passed, failed, skipped int) {
+	for _, result := range x.Results {
+		switch {
+		case result.Skipped:
+			skipped++
+		case result.Passed():
+			passed++
+		default:
+			failed++
+		}
+	}
+	return
+}
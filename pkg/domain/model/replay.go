@@ -0,0 +1,29 @@
+package model
+
+// ReplayResult is a result of replaying a stored route input
+type ReplayResult struct {
+	// Source is where the route input is read from, such as file path or object URI. "#n" is appended if the source has multiple documents.
+	Source       string
+	Destinations []Destination
+	Err          error
+}
+
+type ReplayReport struct {
+	DryRun  bool
+	Results []*ReplayResult
+}
+
+// Count returns number of route inputs that are transferred to all destinations, that have no destination, and that failed
+func (x *ReplayReport) Count() (succeeded, skipped, failed int) {
+	for _, result := range x.Results {
+		switch {
+		case result.Err != nil:
+			failed++
+		case len(result.Destinations) == 0:
+			skipped++
+		default:
+			succeeded++
+		}
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+)
+
+// RetryPolicy is a policy to retry transfer to a destination. Backoff of n-th retry is BaseBackoff * 2^(n-1) capped by MaxBackoff, and it is randomly reduced by Jitter ratio.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Jitter      float64
+}
+
+func (x *RetryPolicy) Validate() error {
+	if x.MaxAttempts < 1 {
+		return goerr.New("max attempts must be 1 or more").With("max_attempts", x.MaxAttempts)
+	}
+	if x.BaseBackoff < 0 || x.MaxBackoff < x.BaseBackoff {
+		return goerr.New("backoff must be 0 or more and max backoff must not be less than base backoff").With("base_backoff", x.BaseBackoff).With("max_backoff", x.MaxBackoff)
+	}
+	if x.Jitter < 0 || 1 < x.Jitter {
+		return goerr.New("jitter must be between 0 and 1").With("jitter", x.Jitter)
+	}
+	return nil
+}
+
+// Backoff returns wait time before the next attempt. attempt is number of attempts that have been done.
+func (x *RetryPolicy) Backoff(attempt int) time.Duration {
+	d := x.BaseBackoff
+	for i := 1; i < attempt && d < x.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, x.MaxBackoff)
+
+	if x.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * x.Jitter * float64(d))
+	}
+	return d
+}
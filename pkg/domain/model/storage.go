@@ -0,0 +1,126 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+)
+
+// Retention prevents deletion and overwrite of destination object until the period expires. The bucket or container must be configured to allow retention of objects.
+type Retention struct {
+	// Mode is mode of retention. Valid values are "Locked" or "Unlocked" for Google Cloud Storage and Azure Blob Storage, and "GOVERNANCE" or "COMPLIANCE" for Amazon S3.
+	Mode string `json:"mode"`
+	// Days is retention period from the time of transfer
+	Days int `json:"days"`
+}
+
+// RetainUntil returns the time when the retention expires
+func (x *Retention) RetainUntil(now time.Time) time.Time {
+	return now.AddDate(0, 0, x.Days)
+}
+
+func (x *Retention) validate(modes ...string) error {
+	if x == nil {
+		return nil
+	}
+	if !slices.Contains(modes, x.Mode) {
+		return goerr.New("invalid retention mode").With("mode", x.Mode).With("allowed", modes)
+	}
+	if x.Days <= 0 {
+		return goerr.New("retention days must be positive").With("days", x.Days)
+	}
+	return nil
+}
+
+// GoogleCloudStorageWriteOptions is options to write an object into Google Cloud Storage. It is used only for destination.
+type GoogleCloudStorageWriteOptions struct {
+	// StorageClass is storage class of the object, such as "NEARLINE", "COLDLINE" and "ARCHIVE"
+	StorageClass string `json:"storage_class,omitempty"`
+	// KMSKeyName is resource name of Cloud KMS key to encrypt the object (CMEK)
+	KMSKeyName string `json:"kms_key_name,omitempty"`
+	// CustomerKeyEnv is name of environment variable that has base64 encoded customer-supplied encryption key (CSEK)
+	CustomerKeyEnv string `json:"customer_key_env,omitempty"`
+	// PredefinedACL is predefined ACL of the object, such as "bucketOwnerFullControl"
+	PredefinedACL  string     `json:"predefined_acl,omitempty"`
+	Retention      *Retention `json:"retention,omitempty"`
+	TemporaryHold  bool       `json:"temporary_hold,omitempty"`
+	EventBasedHold bool       `json:"event_based_hold,omitempty"`
+
+	// CustomerKey is loaded from CustomerKeyEnv before writing
+	CustomerKey []byte `json:"-"`
+}
+
+func (x *GoogleCloudStorageWriteOptions) Validate() error {
+	if x.KMSKeyName != "" && x.CustomerKeyEnv != "" {
+		return goerr.New("kms_key_name and customer_key_env can not be used together")
+	}
+	if err := x.Retention.validate("Locked", "Unlocked"); err != nil {
+		return goerr.Wrap(err, "invalid retention of Google Cloud Storage")
+	}
+	return nil
+}
+
+// AmazonS3WriteOptions is options to write an object into Amazon S3. It is used only for destination.
+type AmazonS3WriteOptions struct {
+	// StorageClass is storage class of the object, such as "STANDARD_IA", "GLACIER_IR" and "DEEP_ARCHIVE"
+	StorageClass string `json:"storage_class,omitempty"`
+	// ServerSideEncryption is "AES256", "aws:kms" or "aws:kms:dsse". It is "aws:kms" if not set and KMSKeyID is set.
+	ServerSideEncryption string `json:"sse,omitempty"`
+	// KMSKeyID is ID or ARN of KMS key for SSE-KMS
+	KMSKeyID string `json:"kms_key_id,omitempty"`
+	// CustomerKeyEnv is name of environment variable that has base64 encoded customer-provided encryption key (SSE-C)
+	CustomerKeyEnv string `json:"customer_key_env,omitempty"`
+	// ACL is canned ACL of the object, such as "bucket-owner-full-control"
+	ACL string `json:"acl,omitempty"`
+	// Retention is retention of S3 Object Lock
+	Retention *Retention        `json:"retention,omitempty"`
+	LegalHold bool              `json:"legal_hold,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+
+	// CustomerKey is loaded from CustomerKeyEnv before writing
+	CustomerKey []byte `json:"-"`
+}
+
+func (x *AmazonS3WriteOptions) Validate() error {
+	if x.ServerSideEncryption != "" && !slices.Contains([]string{"AES256", "aws:kms", "aws:kms:dsse"}, x.ServerSideEncryption) {
+		return goerr.New("invalid sse, must be one of AES256, aws:kms or aws:kms:dsse").With("sse", x.ServerSideEncryption)
+	}
+	if x.KMSKeyID != "" && x.ServerSideEncryption == "AES256" {
+		return goerr.New("kms_key_id can not be used with sse AES256")
+	}
+	if x.CustomerKeyEnv != "" && (x.ServerSideEncryption != "" || x.KMSKeyID != "") {
+		return goerr.New("customer_key_env can not be used with sse or kms_key_id")
+	}
+	if err := x.Retention.validate("GOVERNANCE", "COMPLIANCE"); err != nil {
+		return goerr.Wrap(err, "invalid retention of Amazon S3")
+	}
+	return nil
+}
+
+// AzureBlobStorageWriteOptions is options to write a blob into Azure Blob Storage. It is used only for destination.
+type AzureBlobStorageWriteOptions struct {
+	// AccessTier is access tier of the blob, such as "Cool", "Cold" and "Archive"
+	AccessTier string `json:"access_tier,omitempty"`
+	// EncryptionScope is name of encryption scope to encrypt the blob, such as one with customer-managed key
+	EncryptionScope string `json:"encryption_scope,omitempty"`
+	// CustomerKeyEnv is name of environment variable that has base64 encoded customer-provided encryption key
+	CustomerKeyEnv string `json:"customer_key_env,omitempty"`
+	// Retention is time-based immutability policy of the blob
+	Retention *Retention        `json:"retention,omitempty"`
+	LegalHold bool              `json:"legal_hold,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+
+	// CustomerKey is loaded from CustomerKeyEnv before writing
+	CustomerKey []byte `json:"-"`
+}
+
+func (x *AzureBlobStorageWriteOptions) Validate() error {
+	if x.EncryptionScope != "" && x.CustomerKeyEnv != "" {
+		return goerr.New("encryption_scope and customer_key_env can not be used together")
+	}
+	if err := x.Retention.validate("Locked", "Unlocked"); err != nil {
+		return goerr.Wrap(err, "invalid retention of Azure Blob Storage")
+	}
+	return nil
+}
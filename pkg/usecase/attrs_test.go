@@ -0,0 +1,130 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+func TestObjectAttributes(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.gcs.object.name,
+	}
+}
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+
+abs[dst] {
+	dst := {
+		"storage_account": "nydus-account",
+		"container": "nydus-container",
+		"blob_name": input.gcs.object.name,
+		"attributes": {
+			"cache_control": "no-cache",
+			"metadata": {
+				"team": "red",
+				"nydus-source-uri": "",
+			},
+		},
+	}
+}
+`,
+	}))).NoError(t)
+
+	input := &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Object: model.GoogleCloudStorageObject{
+				Bucket:      "nydus-src-bucket",
+				Name:        "blue.txt",
+				ContentType: "text/plain",
+				Metadata:    map[string]string{"owner": "blue"},
+			},
+		},
+	}
+
+	t.Run("attributes of source object", func(t *testing.T) {
+		gcsMock := &mockGoogleCloudStorage{
+			stats: map[string]*model.ObjectStat{
+				"nydus-src-bucket/blue.txt": {
+					ContentType:     "text/csv",
+					ContentEncoding: "gzip",
+					CacheControl:    "max-age=3600",
+					Metadata:        map[string]string{"Owner-Name": "blue", "0day": "yes"},
+				},
+			},
+		}
+		s3Mock := &mockAmazonS3{}
+		absMock := &mockAzureBlobStorage{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(s3Mock),
+			adapter.WithAzureBlobStorage(absMock),
+		))
+		gt.NoError(t, uc.Route(context.Background(), input))
+
+		gcsAttrs := gcsMock.attrs["nydus-dst-bucket/blue.txt"]
+		gt.Equal(t, gcsAttrs.ContentType, "text/csv")
+		gt.Equal(t, gcsAttrs.ContentEncoding, "gzip")
+		gt.Equal(t, gcsAttrs.CacheControl, "max-age=3600")
+		gt.Equal(t, gcsAttrs.Metadata, map[string]string{
+			"Owner-Name":       "blue",
+			"0day":             "yes",
+			"nydus-source-uri": "gs://nydus-src-bucket/blue.txt",
+		})
+
+		s3Attrs := s3Mock.attrs["ap-northeast-1/nydus-dst-bucket/blue.txt"]
+		gt.Equal(t, s3Attrs.ContentEncoding, "gzip")
+		gt.Equal(t, s3Attrs.Metadata, map[string]string{
+			"owner-name":       "blue",
+			"0day":             "yes",
+			"nydus-source-uri": "gs://nydus-src-bucket/blue.txt",
+		})
+
+		// Overridden by policy, and keys are converted to C# identifiers
+		absAttrs := absMock.attrs["nydus-account/nydus-container/blue.txt"]
+		gt.Equal(t, absAttrs.ContentType, "text/csv")
+		gt.Equal(t, absAttrs.CacheControl, "no-cache")
+		gt.Equal(t, absAttrs.Metadata, map[string]string{
+			"owner_name": "blue",
+			"_0day":      "yes",
+			"team":       "red",
+		})
+	})
+
+	t.Run("attributes in event if source object is not available", func(t *testing.T) {
+		gcsMock := &mockGoogleCloudStorage{}
+		s3Mock := &mockAmazonS3{}
+		absMock := &mockAzureBlobStorage{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(s3Mock),
+			adapter.WithAzureBlobStorage(absMock),
+		))
+		gt.NoError(t, uc.Route(context.Background(), input))
+
+		s3Attrs := s3Mock.attrs["ap-northeast-1/nydus-dst-bucket/blue.txt"]
+		gt.Equal(t, s3Attrs.ContentType, "text/plain")
+		gt.Equal(t, s3Attrs.Metadata, map[string]string{
+			"owner":            "blue",
+			"nydus-source-uri": "gs://nydus-src-bucket/blue.txt",
+		})
+	})
+}
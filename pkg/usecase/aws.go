@@ -0,0 +1,331 @@
+package usecase
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1" // #nosec G505 SHA1 is required for SNS SignatureVersion 1
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// snsRegion extracts region from SNS topic ARN. Example: "arn:aws:sns:us-east-1:123456789012:my-topic"
+func snsRegion(topicArn string) (string, error) {
+	parts := strings.Split(topicArn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" || parts[3] == "" {
+		return "", goerr.New("invalid SNS topic ARN").With("topicArn", topicArn)
+	}
+	return parts[3], nil
+}
+
+// snsHost returns the SNS endpoint host of the region. Regions of China partition use amazonaws.com.cn.
+func snsHost(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return "sns." + region + ".amazonaws.com.cn"
+	}
+	return "sns." + region + ".amazonaws.com"
+}
+
+func (x *UseCase) ConfirmAmazonSNSSubscription(ctx context.Context, ev *model.AmazonSNSEvent) error {
+	region, err := snsRegion(ev.TopicArn)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ev.SubscribeURL, nil)
+	if err != nil {
+		return goerr.Wrap(err, "failed to create HTTP request").With("subscribeURL", ev.SubscribeURL)
+	}
+
+	// Example:
+	// https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&TopicArn=arn:aws:sns:us-east-1:123456789012:MyTopic&Token=XXXXXXX
+	if req.URL.Scheme != "https" ||
+		req.URL.Hostname() != snsHost(region) {
+		return goerr.New("SubscribeURL is invalid").With("subscribeURL", ev.SubscribeURL).With("topicArn", ev.TopicArn)
+	}
+
+	resp, err := x.clients.HTTPClient().Do(req)
+	if err != nil {
+		return goerr.Wrap(err, "failed to send HTTP request").With("subscribeURL", ev.SubscribeURL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return goerr.New("SubscribeURL response is not OK").With("statusCode", resp.StatusCode).With("body", string(body)).With("subscribeURL", ev.SubscribeURL)
+	}
+
+	logging.From(ctx).Info("Successfully confirmed Amazon SNS subscription",
+		"topicArn", ev.TopicArn,
+		"statusCode", resp.StatusCode,
+	)
+
+	return nil
+}
+
+func (x *UseCase) HandleAmazonSNSEvent(ctx context.Context, ev *model.AmazonSNSEvent) error {
+	logger := logging.From(ctx)
+	logger.Debug("Handle Amazon SNS event", "event", ev)
+
+	inputs, err := newAmazonRouteInputs(ctx, ev)
+	if err != nil {
+		return err
+	}
+
+	// All records are routed even if some of them fail, because SNS redelivers the whole message
+	var errs []error
+	for _, input := range inputs {
+		if err := x.Route(ctx, input); err != nil {
+			errs = append(errs, goerr.Wrap(err, "failed to emit route").With("input", input))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// newAmazonRouteInputs builds route inputs from SNS message of Amazon S3 event notification. Records other than ObjectCreated are ignored.
+func newAmazonRouteInputs(ctx context.Context, ev *model.AmazonSNSEvent) ([]*model.RouteInput, error) {
+	logger := logging.From(ctx)
+
+	var msg model.AmazonS3EventMessage
+	if err := json.Unmarshal([]byte(ev.Message), &msg); err != nil {
+		return nil, goerr.Wrap(err, "failed to unmarshal Amazon S3 event message").With("message", ev.Message)
+	}
+
+	// s3:TestEvent message has no records
+	if len(msg.Records) == 0 {
+		logger.Info("No Amazon S3 event record in SNS message", "message", ev.Message)
+		return nil, nil
+	}
+
+	var inputs []*model.RouteInput
+	for _, record := range msg.Records {
+		if !strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			logger.Info("ignore Amazon S3 event", "eventName", record.EventName)
+			continue
+		}
+
+		// Object key is URL encoded in the event notification
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to decode object key").With("record", record)
+		}
+
+		inputs = append(inputs, &model.RouteInput{
+			AmazonS3: &model.AmazonS3Event{
+				Event:  *ev,
+				Record: record,
+				Object: model.AmazonS3Object{
+					Region:    record.AWSRegion,
+					Bucket:    record.S3.Bucket.Name,
+					Key:       key,
+					Size:      record.S3.Object.Size,
+					ETag:      record.S3.Object.ETag,
+					VersionID: record.S3.Object.VersionID,
+				},
+			},
+		})
+	}
+
+	return inputs, nil
+}
+
+// maxSNSCerts is the maximum number of cached SNS signing certificates. SNS rotates the certificate rarely, so a few entries are enough.
+const maxSNSCerts = 16
+
+// maxSNSCertSize is the maximum size of SNS signing certificate response
+const maxSNSCertSize = 64 * 1024
+
+type snsCertCache struct {
+	mutex sync.RWMutex
+	certs map[string]*x509.Certificate
+}
+
+func newSNSCertCache() *snsCertCache {
+	return &snsCertCache{
+		certs: make(map[string]*x509.Certificate),
+	}
+}
+
+func (x *snsCertCache) get(certURL string) *x509.Certificate {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	return x.certs[certURL]
+}
+
+func (x *snsCertCache) set(certURL string, cert *x509.Certificate) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	if _, ok := x.certs[certURL]; !ok && len(x.certs) >= maxSNSCerts {
+		for k := range x.certs {
+			delete(x.certs, k)
+			break
+		}
+	}
+	x.certs[certURL] = cert
+}
+
+// VerifyAmazonSNSMessage verifies signature of SNS message. SignatureVersion 1 (SHA1withRSA) and 2 (SHA256withRSA) are supported. The signing certificate is fetched only from SNS endpoint of the topic region over HTTPS and cached.
+func (x *UseCase) VerifyAmazonSNSMessage(ctx context.Context, ev *model.AmazonSNSEvent) error {
+	sig, err := base64.StdEncoding.DecodeString(ev.Signature)
+	if err != nil {
+		return goerr.Wrap(err, "failed to decode SNS signature").With("messageID", ev.MessageID)
+	}
+
+	var hash crypto.Hash
+	switch ev.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return goerr.New("unsupported SNS SignatureVersion").With("signatureVersion", ev.SignatureVersion).With("messageID", ev.MessageID)
+	}
+
+	cert, err := x.getSNSSigningCert(ctx, ev)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return goerr.New("SNS signing certificate is expired or not yet valid").With("signingCertURL", ev.SigningCertURL).With("notBefore", cert.NotBefore).With("notAfter", cert.NotAfter)
+	}
+
+	pubKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return goerr.New("SNS signing certificate does not have RSA public key").With("signingCertURL", ev.SigningCertURL)
+	}
+
+	msg, err := snsStringToSign(ev)
+	if err != nil {
+		return err
+	}
+
+	var digest []byte
+	switch hash {
+	case crypto.SHA1:
+		h := sha1.Sum([]byte(msg)) // #nosec G401
+		digest = h[:]
+	case crypto.SHA256:
+		h := sha256.Sum256([]byte(msg))
+		digest = h[:]
+	}
+
+	if err := rsa.VerifyPKCS1v15(pubKey, hash, digest, sig); err != nil {
+		return goerr.Wrap(err, "invalid SNS signature").With("messageID", ev.MessageID).With("topicArn", ev.TopicArn)
+	}
+
+	return nil
+}
+
+func (x *UseCase) getSNSSigningCert(ctx context.Context, ev *model.AmazonSNSEvent) (*x509.Certificate, error) {
+	certURL := ev.SigningCertURL
+	region, err := snsRegion(ev.TopicArn)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to create HTTP request").With("signingCertURL", certURL)
+	}
+
+	// Example:
+	// https://sns.us-east-1.amazonaws.com/SimpleNotificationService-xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx.pem
+	host := snsHost(region)
+	if req.URL.Scheme != "https" || req.URL.Hostname() != host {
+		return nil, goerr.New("SigningCertURL is invalid").With("signingCertURL", certURL).With("topicArn", ev.TopicArn)
+	}
+
+	// Only verified certificates are cached, so the cache key is limited to URL of the SNS endpoint
+	if cert := x.snsCerts.get(certURL); cert != nil {
+		return cert, nil
+	}
+
+	resp, err := x.clients.HTTPClient().Do(req)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to send HTTP request").With("signingCertURL", certURL)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSNSCertSize))
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to read SNS signing certificate").With("signingCertURL", certURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, goerr.New("SigningCertURL response is not OK").With("statusCode", resp.StatusCode).With("body", string(body)).With("signingCertURL", certURL)
+	}
+
+	// The signing certificate is trusted because it is served by the SNS endpoint of the topic region over HTTPS. It is a leaf certificate without intermediates and its subject is not the regional host, so the chain and name are not verified.
+	block, _ := pem.Decode(body)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, goerr.New("SNS signing certificate is not PEM encoded certificate").With("signingCertURL", certURL)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to parse SNS signing certificate").With("signingCertURL", certURL)
+	}
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, goerr.New("SNS signing certificate is not valid now").With("signingCertURL", certURL).With("notBefore", cert.NotBefore).With("notAfter", cert.NotAfter)
+	}
+
+	x.snsCerts.set(certURL, cert)
+
+	return cert, nil
+}
+
+// snsStringToSign builds the string to sign of SNS message. See https://docs.aws.amazon.com/sns/latest/dg/sns-verify-signature-of-message.html
+func snsStringToSign(ev *model.AmazonSNSEvent) (string, error) {
+	type field struct {
+		key   string
+		value string
+	}
+
+	var fields []field
+	switch ev.Type {
+	case "Notification":
+		fields = append(fields, field{"Message", ev.Message}, field{"MessageId", ev.MessageID})
+		if ev.Subject != "" {
+			fields = append(fields, field{"Subject", ev.Subject})
+		}
+		fields = append(fields,
+			field{"Timestamp", ev.Timestamp},
+			field{"TopicArn", ev.TopicArn},
+			field{"Type", ev.Type},
+		)
+
+	case "SubscriptionConfirmation", "UnsubscribeConfirmation":
+		fields = append(fields,
+			field{"Message", ev.Message},
+			field{"MessageId", ev.MessageID},
+			field{"SubscribeURL", ev.SubscribeURL},
+			field{"Timestamp", ev.Timestamp},
+			field{"Token", ev.Token},
+			field{"TopicArn", ev.TopicArn},
+			field{"Type", ev.Type},
+		)
+
+	default:
+		return "", goerr.New("unsupported SNS message type").With("type", ev.Type)
+	}
+
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(f.key + "\n" + f.value + "\n")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,402 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"maps"
+	"math/big"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+//go:embed testdata/sns_notification.json
+var snsNotification []byte
+
+//go:embed testdata/sns_subscription.json
+var snsSubscription []byte
+
+type mockAmazonS3 struct {
+	mutex  sync.Mutex
+	reads  []string
+	writes map[string]*bytes.Buffer
+	attrs  map[string]*model.ObjectAttrs
+	opts   map[string]*model.AmazonS3WriteOptions
+	stats  map[string]*model.ObjectStat
+	// statKeys is customer keys passed to Stat
+	statKeys map[string][]byte
+	// newWriters is number of NewWriter calls for each object
+	newWriters map[string]int
+}
+
+func (x *mockAmazonS3) NewReader(ctx context.Context, region, bucket, key, versionID, etag string) (io.ReadCloser, error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.reads = append(x.reads, region+"/"+bucket+"/"+key)
+	return io.NopCloser(bytes.NewReader([]byte("timeless words"))), nil
+}
+
+func (x *mockAmazonS3) NewWriter(ctx context.Context, region, bucket, key string, attrs *model.ObjectAttrs, opts *model.AmazonS3WriteOptions) (io.WriteCloser, error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	if x.writes == nil {
+		x.writes = map[string]*bytes.Buffer{}
+	}
+	if x.attrs == nil {
+		x.attrs = map[string]*model.ObjectAttrs{}
+		x.opts = map[string]*model.AmazonS3WriteOptions{}
+	}
+	if x.newWriters == nil {
+		x.newWriters = map[string]int{}
+	}
+	x.newWriters[region+"/"+bucket+"/"+key]++
+	buf := &bytes.Buffer{}
+	x.writes[region+"/"+bucket+"/"+key] = buf
+	x.attrs[region+"/"+bucket+"/"+key] = attrs
+	x.opts[region+"/"+bucket+"/"+key] = opts
+	return nopWriteCloser{buf}, nil
+}
+
+func (x *mockAmazonS3) List(ctx context.Context, region, bucket, prefix, startAfter string, fn func(*model.ObjectInfo) error) error {
+	x.mutex.Lock()
+	writes := maps.Clone(x.writes)
+	x.mutex.Unlock()
+
+	return listWrites(writes, region+"/"+bucket+"/", prefix, startAfter, fn)
+}
+
+func (x *mockAmazonS3) Stat(ctx context.Context, region, bucket, key string, customerKey []byte) (*model.ObjectStat, error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	if x.statKeys == nil {
+		x.statKeys = map[string][]byte{}
+	}
+	x.statKeys[region+"/"+bucket+"/"+key] = customerKey
+	if stat, ok := x.stats[region+"/"+bucket+"/"+key]; ok {
+		return stat, nil
+	}
+	return statWrites(x.writes, region+"/"+bucket+"/"+key)
+}
+
+func TestAmazonSNSSubscription(t *testing.T) {
+	var ev model.AmazonSNSEvent
+	gt.NoError(t, json.Unmarshal(snsSubscription, &ev))
+
+	t.Run("valid SubscribeURL", func(t *testing.T) {
+		mock := &mockHTTPClient{}
+		uc := usecase.New(adapter.New(adapter.WithHTTPClient(mock)))
+
+		gt.NoError(t, uc.ConfirmAmazonSNSSubscription(context.Background(), &ev))
+		gt.A(t, mock.requests).Length(1).At(0, func(t testing.TB, v *http.Request) {
+			gt.Equal(t, v.URL.String(), ev.SubscribeURL)
+		})
+	})
+
+	t.Run("SubscribeURL of other region", func(t *testing.T) {
+		mock := &mockHTTPClient{}
+		uc := usecase.New(adapter.New(adapter.WithHTTPClient(mock)))
+
+		invalid := ev
+		invalid.SubscribeURL = "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription"
+		gt.Error(t, uc.ConfirmAmazonSNSSubscription(context.Background(), &invalid))
+		gt.A(t, mock.requests).Length(0)
+	})
+
+	t.Run("SubscribeURL of other host", func(t *testing.T) {
+		mock := &mockHTTPClient{}
+		uc := usecase.New(adapter.New(adapter.WithHTTPClient(mock)))
+
+		invalid := ev
+		invalid.SubscribeURL = "https://sns.ap-northeast-1.amazonaws.com.example.com/?Action=ConfirmSubscription"
+		gt.Error(t, uc.ConfirmAmazonSNSSubscription(context.Background(), &invalid))
+		gt.A(t, mock.requests).Length(0)
+	})
+}
+
+func TestAmazonSNSNotification(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	input.s3.record.eventName == "ObjectCreated:Put"
+	input.s3.object.size == 14
+	input.s3.object.etag == "0a755dbb29f923fb842fa43421a1ae75"
+
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": sprintf("%s/%s/%s", [input.s3.object.region, input.s3.object.bucket, input.s3.object.key]),
+	}
+}
+`,
+	}))).NoError(t)
+
+	s3Mock := &mockAmazonS3{}
+	gcsMock := &mockGoogleCloudStorage{}
+	uc := usecase.New(adapter.New(
+		adapter.WithPolicy(policy),
+		adapter.WithAmazonS3(s3Mock),
+		adapter.WithGoogleCloudStorage(gcsMock),
+	))
+
+	var ev model.AmazonSNSEvent
+	gt.NoError(t, json.Unmarshal(snsNotification, &ev))
+	gt.NoError(t, uc.HandleAmazonSNSEvent(context.Background(), &ev))
+
+	gt.A(t, s3Mock.reads).Length(1).At(0, func(t testing.TB, v string) {
+		gt.Equal(t, v, "ap-northeast-1/nydus-src-bucket/logs/2024/08/25/access log=1.txt")
+	})
+	gt.Equal(t, gcsMock.writes["nydus-dst-bucket/ap-northeast-1/nydus-src-bucket/logs/2024/08/25/access log=1.txt"].String(), "timeless words")
+}
+
+func TestAmazonSNSNotificationRoutesAllRecords(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+s3[dst] {
+	input.s3.object.key == "red.txt"
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.s3.object.key,
+	}
+}
+
+gcs[dst] {
+	input.s3.object.key == "blue.txt"
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.s3.object.key,
+	}
+}
+`,
+	}))).NoError(t)
+
+	s3Mock := &failingAmazonS3{}
+	gcsMock := &mockGoogleCloudStorage{}
+	uc := usecase.New(adapter.New(
+		adapter.WithPolicy(policy),
+		adapter.WithAmazonS3(s3Mock),
+		adapter.WithGoogleCloudStorage(gcsMock),
+	))
+
+	record := func(key string) string {
+		return `{"eventName": "ObjectCreated:Put", "awsRegion": "ap-northeast-1", "s3": {"bucket": {"name": "nydus-src-bucket"}, "object": {"key": "` + key + `", "size": 14}}}`
+	}
+	ev := model.AmazonSNSEvent{
+		Type:    "Notification",
+		Message: `{"Records": [` + record("red.txt") + `, ` + record("blue.txt") + `]}`,
+	}
+
+	// Failure of the first record does not stop routing the second record
+	gt.Error(t, uc.HandleAmazonSNSEvent(context.Background(), &ev))
+	gt.Equal(t, gcsMock.writes["nydus-dst-bucket/blue.txt"].String(), "timeless words")
+}
+
+type certHTTPClient struct {
+	cert     []byte
+	requests []*http.Request
+}
+
+func (x *certHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	x.requests = append(x.requests, req)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader(x.cert)),
+	}, nil
+}
+
+// newSigningCert issues a self-signed signing certificate like the one served by SNS endpoint: a single leaf certificate of sns.amazonaws.com without intermediates. It returns the signing key and PEM encoded certificate.
+func newSigningCert(t *testing.T, notAfter time.Time) (*rsa.PrivateKey, []byte) {
+	key := gt.R1(rsa.GenerateKey(rand.Reader, 2048)).NoError(t)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		DNSNames:     []string{"sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der := gt.R1(x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)).NoError(t)
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func signSNSMessage(t *testing.T, key *rsa.PrivateKey, ev *model.AmazonSNSEvent) {
+	var msg string
+	switch ev.Type {
+	case "Notification":
+		msg = "Message\n" + ev.Message + "\n" +
+			"MessageId\n" + ev.MessageID + "\n"
+		if ev.Subject != "" {
+			msg += "Subject\n" + ev.Subject + "\n"
+		}
+		msg += "Timestamp\n" + ev.Timestamp + "\n" +
+			"TopicArn\n" + ev.TopicArn + "\n" +
+			"Type\n" + ev.Type + "\n"
+	default:
+		msg = "Message\n" + ev.Message + "\n" +
+			"MessageId\n" + ev.MessageID + "\n" +
+			"SubscribeURL\n" + ev.SubscribeURL + "\n" +
+			"Timestamp\n" + ev.Timestamp + "\n" +
+			"Token\n" + ev.Token + "\n" +
+			"TopicArn\n" + ev.TopicArn + "\n" +
+			"Type\n" + ev.Type + "\n"
+	}
+
+	var sig []byte
+	switch ev.SignatureVersion {
+	case "1":
+		h := sha1.Sum([]byte(msg))
+		sig = gt.R1(rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, h[:])).NoError(t)
+	case "2":
+		h := sha256.Sum256([]byte(msg))
+		sig = gt.R1(rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])).NoError(t)
+	}
+	ev.Signature = base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestVerifyAmazonSNSMessage(t *testing.T) {
+	key, cert := newSigningCert(t, time.Now().Add(time.Hour))
+	expiredKey, expiredCert := newSigningCert(t, time.Now().Add(-time.Minute))
+
+	testCases := map[string]struct {
+		data    []byte
+		setup   func(ev *model.AmazonSNSEvent)
+		cert    []byte
+		wantErr bool
+	}{
+		"notification with SignatureVersion 1": {
+			data: snsNotification,
+			setup: func(ev *model.AmazonSNSEvent) {
+				ev.SignatureVersion = "1"
+				signSNSMessage(t, key, ev)
+			},
+		},
+		"notification with SignatureVersion 2": {
+			data: snsNotification,
+			setup: func(ev *model.AmazonSNSEvent) {
+				ev.SignatureVersion = "2"
+				signSNSMessage(t, key, ev)
+			},
+		},
+		"subscription confirmation": {
+			data: snsSubscription,
+			setup: func(ev *model.AmazonSNSEvent) {
+				signSNSMessage(t, key, ev)
+			},
+		},
+		"tampered message": {
+			data: snsNotification,
+			setup: func(ev *model.AmazonSNSEvent) {
+				signSNSMessage(t, key, ev)
+				ev.Message = `{"Records":[]}`
+			},
+			wantErr: true,
+		},
+		"unsupported SignatureVersion": {
+			data: snsNotification,
+			setup: func(ev *model.AmazonSNSEvent) {
+				signSNSMessage(t, key, ev)
+				ev.SignatureVersion = "3"
+			},
+			wantErr: true,
+		},
+		"SigningCertURL of other domain": {
+			data: snsNotification,
+			setup: func(ev *model.AmazonSNSEvent) {
+				signSNSMessage(t, key, ev)
+				ev.SigningCertURL = "https://example.com/SimpleNotificationService.pem"
+			},
+			wantErr: true,
+		},
+		"SigningCertURL of other amazonaws.com host": {
+			data: snsNotification,
+			setup: func(ev *model.AmazonSNSEvent) {
+				signSNSMessage(t, key, ev)
+				ev.SigningCertURL = "https://attacker-bucket.s3.amazonaws.com/SimpleNotificationService.pem"
+			},
+			wantErr: true,
+		},
+		"SigningCertURL of other region": {
+			data: snsNotification,
+			setup: func(ev *model.AmazonSNSEvent) {
+				signSNSMessage(t, key, ev)
+				ev.SigningCertURL = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService.pem"
+			},
+			wantErr: true,
+		},
+		"expired certificate": {
+			data: snsNotification,
+			setup: func(ev *model.AmazonSNSEvent) {
+				signSNSMessage(t, expiredKey, ev)
+			},
+			cert:    expiredCert,
+			wantErr: true,
+		},
+		"SigningCertURL without HTTPS": {
+			data: snsNotification,
+			setup: func(ev *model.AmazonSNSEvent) {
+				signSNSMessage(t, key, ev)
+				ev.SigningCertURL = "http://sns.ap-northeast-1.amazonaws.com/SimpleNotificationService.pem"
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var ev model.AmazonSNSEvent
+			gt.NoError(t, json.Unmarshal(tc.data, &ev))
+			tc.setup(&ev)
+
+			mock := &certHTTPClient{cert: cert}
+			if tc.cert != nil {
+				mock.cert = tc.cert
+			}
+			uc := usecase.New(adapter.New(adapter.WithHTTPClient(mock)))
+
+			err := uc.VerifyAmazonSNSMessage(context.Background(), &ev)
+			if tc.wantErr {
+				gt.Error(t, err)
+			} else {
+				gt.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyAmazonSNSMessageCachesCert(t *testing.T) {
+	key, cert := newSigningCert(t, time.Now().Add(time.Hour))
+	mock := &certHTTPClient{cert: cert}
+	uc := usecase.New(adapter.New(adapter.WithHTTPClient(mock)))
+
+	for i := 0; i < 3; i++ {
+		var ev model.AmazonSNSEvent
+		gt.NoError(t, json.Unmarshal(snsNotification, &ev))
+		signSNSMessage(t, key, &ev)
+		gt.NoError(t, uc.VerifyAmazonSNSMessage(context.Background(), &ev))
+	}
+
+	gt.A(t, mock.requests).Length(1).At(0, func(t testing.TB, v *http.Request) {
+		gt.Equal(t, v.URL.Host, "sns.ap-northeast-1.amazonaws.com")
+	})
+}
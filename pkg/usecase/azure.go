@@ -2,10 +2,13 @@ package usecase
 
 import (
 	"context"
+	"crypto/subtle"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/m-mizutani/goerr"
 	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
 	"github.com/secmon-lab/nydus/pkg/domain/model"
@@ -49,27 +52,41 @@ func (x *UseCase) HandleAzureCloudEvent(ctx context.Context, ev *model.CloudEven
 	logger := logging.From(ctx)
 	logger.Debug("Handle Azure CloudEvent", "event", ev)
 
+	input, err := newAzureRouteInput(ev)
+	if err != nil {
+		return err
+	}
+
+	if err := x.Route(ctx, input); err != nil {
+		return goerr.Wrap(err, "failed to emit route").With("input", input)
+	}
+
+	return nil
+}
+
+// newAzureRouteInput builds route input from CloudEvent of Microsoft.Storage.BlobCreated
+func newAzureRouteInput(ev *model.CloudEventSchema) (*model.RouteInput, error) {
 	// Example:
 	// "/blobServices/default/containers/xxx-logs/blobs/tenantId=1yyyyy-yyyy-yyyy-yyyyyyyyyyyy/y=2024/m=08/d=25/h=23/m=00/PT1H.json"
 	subject := strings.Split(ev.Subject, "/")
 	if len(subject) < 6 {
-		return goerr.New("Invalid Azure EventGrid message").With("subject", ev.Subject)
+		return nil, goerr.New("Invalid Azure EventGrid message").With("subject", ev.Subject)
 	}
 	if subject[1] != "blobServices" || subject[3] != "containers" || subject[5] != "blobs" {
-		return goerr.New("Invalid Azure EventGrid message").With("subject", ev.Subject)
+		return nil, goerr.New("Invalid Azure EventGrid message").With("subject", ev.Subject)
 	}
 
 	// Example:
 	// "/subscriptions/xxxx-xxxx-xxxx-xxxx/resourceGroups/xxxx/providers/Microsoft.Storage/storageAccounts/xxxx"
 	source := strings.Split(ev.Source, "/")
 	if len(source) != 9 {
-		return goerr.New("invalid Azure EventGrid message").With("source", ev.Source)
+		return nil, goerr.New("invalid Azure EventGrid message").With("source", ev.Source)
 	}
 	if source[1] != "subscriptions" || source[3] != "resourceGroups" || source[5] != "providers" || source[7] != "storageAccounts" {
-		return goerr.New("invalid Azure EventGrid message").With("source", ev.Source)
+		return nil, goerr.New("invalid Azure EventGrid message").With("source", ev.Source)
 	}
 
-	input := &model.RouteInput{
+	return &model.RouteInput{
 		AzureBlobStorage: &model.AzureBlobStorageEvent{
 			Event: *ev,
 			Object: model.AzureBlobStorageObject{
@@ -81,10 +98,80 @@ func (x *UseCase) HandleAzureCloudEvent(ctx context.Context, ev *model.CloudEven
 				ETag:           ev.Data.ETag,
 			},
 		},
+	}, nil
+}
+
+type entraIDTokenClaims struct {
+	jwt.RegisteredClaims
+	TenantID string `json:"tid"`
+	// AppID is application ID of the token requester in v1 token, and AuthorizedParty is that in v2 token
+	AppID           string `json:"appid"`
+	AuthorizedParty string `json:"azp"`
+}
+
+// VerifyAzureEventGridCredential authenticates Azure Event Grid webhook delivery with shared secret and/or Entra ID bearer token. It returns nil without verification if Event Grid authentication is not configured. It returns error wrapping model.ErrUnauthenticated if credential is missing or invalid, and model.ErrForbidden if audience or caller application of the token is not allowed.
+func (x *UseCase) VerifyAzureEventGridCredential(ctx context.Context, cred *model.AzureEventGridCredential) error {
+	auth := x.azureEventGridAuth
+	if auth == nil {
+		return nil
 	}
 
-	if err := x.Route(ctx, input); err != nil {
-		return goerr.Wrap(err, "failed to emit route").With("input", input)
+	if auth.Secret != "" {
+		if cred.Secret == "" {
+			return goerr.Wrap(model.ErrUnauthenticated, "no shared secret")
+		}
+		if subtle.ConstantTimeCompare([]byte(cred.Secret), []byte(auth.Secret)) != 1 {
+			return goerr.Wrap(model.ErrUnauthenticated, "shared secret is not matched")
+		}
+	}
+
+	if auth.TenantID != "" {
+		if cred.Token == "" {
+			return goerr.Wrap(model.ErrUnauthenticated, "no Entra ID token")
+		}
+
+		var claims entraIDTokenClaims
+		keyFunc := func(t *jwt.Token) (any, error) {
+			kid, _ := t.Header["kid"].(string)
+			return x.azureJWKS.get(ctx, x.clients.HTTPClient(), auth.JWKSURL, kid)
+		}
+
+		if _, err := jwt.ParseWithClaims(cred.Token, &claims, keyFunc,
+			jwt.WithValidMethods([]string{"RS256"}),
+			jwt.WithExpirationRequired(),
+		); err != nil {
+			return goerr.Wrap(model.ErrUnauthenticated, "invalid Entra ID token").With("err", err.Error())
+		}
+
+		// Both v1.0 and v2.0 tokens are accepted
+		issuers := []string{
+			"https://sts.windows.net/" + auth.TenantID + "/",
+			"https://login.microsoftonline.com/" + auth.TenantID + "/v2.0",
+		}
+		if claims.TenantID != auth.TenantID || !slices.Contains(issuers, claims.Issuer) {
+			return goerr.Wrap(model.ErrUnauthenticated, "tenant of Entra ID token is not allowed").With("iss", claims.Issuer).With("tid", claims.TenantID)
+		}
+
+		if !slices.ContainsFunc(claims.Audience, func(aud string) bool {
+			return slices.Contains(auth.Audiences, aud) || slices.Contains(auth.Audiences, strings.TrimPrefix(aud, "api://"))
+		}) {
+			return goerr.Wrap(model.ErrForbidden, "audience is not allowed").With("aud", claims.Audience)
+		}
+
+		// Any application in the tenant can get a token for the audience, then the caller must be Event Grid
+		callers := auth.CallerAppIDs
+		if len(callers) == 0 {
+			callers = []string{model.AzureEventGridAppID}
+		}
+		caller := claims.AppID
+		if caller == "" {
+			caller = claims.AuthorizedParty
+		}
+		if !slices.Contains(callers, caller) {
+			return goerr.Wrap(model.ErrForbidden, "caller application is not allowed").With("appid", claims.AppID).With("azp", claims.AuthorizedParty)
+		}
+
+		logging.From(ctx).Debug("Verified Entra ID token of Azure Event Grid delivery", "aud", claims.Audience, "sub", claims.Subject, "caller", caller)
 	}
 
 	return nil
@@ -3,12 +3,18 @@ package usecase_test
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
 	"io"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/m-mizutani/gt"
 	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
 	"github.com/secmon-lab/nydus/pkg/usecase"
 )
 
@@ -24,6 +30,40 @@ func (c *mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	}, nil
 }
 
+type mockAzureBlobStorage struct {
+	reads  []string
+	writes map[string]*bytes.Buffer
+	attrs  map[string]*model.ObjectAttrs
+	opts   map[string]*model.AzureBlobStorageWriteOptions
+}
+
+func (x *mockAzureBlobStorage) NewReader(ctx context.Context, storageAccountName, containerName, blobName, etag string) (io.ReadCloser, error) {
+	x.reads = append(x.reads, storageAccountName+"/"+containerName+"/"+blobName)
+	return io.NopCloser(bytes.NewReader([]byte("timeless words"))), nil
+}
+
+func (x *mockAzureBlobStorage) NewWriter(ctx context.Context, storageAccountName, containerName, blobName string, attrs *model.ObjectAttrs, opts *model.AzureBlobStorageWriteOptions) (io.WriteCloser, error) {
+	if x.writes == nil {
+		x.writes = map[string]*bytes.Buffer{}
+		x.attrs = map[string]*model.ObjectAttrs{}
+		x.opts = map[string]*model.AzureBlobStorageWriteOptions{}
+	}
+	key := storageAccountName + "/" + containerName + "/" + blobName
+	buf := &bytes.Buffer{}
+	x.writes[key] = buf
+	x.attrs[key] = attrs
+	x.opts[key] = opts
+	return nopWriteCloser{buf}, nil
+}
+
+func (x *mockAzureBlobStorage) List(ctx context.Context, storageAccountName, containerName, prefix, startAfter string, fn func(*model.ObjectInfo) error) error {
+	return listWrites(x.writes, storageAccountName+"/"+containerName+"/", prefix, startAfter, fn)
+}
+
+func (x *mockAzureBlobStorage) Stat(ctx context.Context, storageAccountName, containerName, blobName string, customerKey []byte) (*model.ObjectStat, error) {
+	return statWrites(x.writes, storageAccountName+"/"+containerName+"/"+blobName)
+}
+
 func TestAzureValidation(t *testing.T) {
 	const testURL = "https://rp-japaneast.eventgrid.azure.net:553/eventsubscriptions/xxxxxx/validate?id=XXXXX-XXXXXX-XXXXX&t=2024-12-17T08:20:31.2630520Z&apiVersion=2023-12-15-preview&token=Z%2f%oiujgoafasiodjfaposdijfasd%3d"
 
@@ -38,3 +78,170 @@ func TestAzureValidation(t *testing.T) {
 		gt.Equal(t, v.URL.String(), testURL)
 	})
 }
+
+func TestVerifyAzureEventGridCredential(t *testing.T) {
+	const (
+		kid      = "test-key"
+		tenantID = "11111111-2222-3333-4444-555555555555"
+		appID    = "66666666-7777-8888-9999-000000000000"
+	)
+	key := gt.R1(rsa.GenerateKey(rand.Reader, 2048)).NoError(t)
+	jwks := newJWKS(t, kid, key)
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		return gt.R1(token.SignedString(key)).NoError(t)
+	}
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": "https://login.microsoftonline.com/" + tenantID + "/v2.0",
+			"aud": appID,
+			"tid": tenantID,
+			"azp": model.AzureEventGridAppID,
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	secretAuth := &model.AzureEventGridAuth{Secret: "blue"}
+	tokenAuth := &model.AzureEventGridAuth{
+		TenantID:  tenantID,
+		Audiences: []string{appID},
+		JWKSURL:   "https://login.microsoftonline.com/" + tenantID + "/discovery/v2.0/keys",
+	}
+	bothAuth := &model.AzureEventGridAuth{
+		Secret:    "blue",
+		TenantID:  tokenAuth.TenantID,
+		Audiences: tokenAuth.Audiences,
+		JWKSURL:   tokenAuth.JWKSURL,
+	}
+
+	testCases := map[string]struct {
+		auth    *model.AzureEventGridAuth
+		cred    func() *model.AzureEventGridCredential
+		wantErr error
+	}{
+		"no authentication": {
+			cred: func() *model.AzureEventGridCredential { return &model.AzureEventGridCredential{} },
+		},
+		"valid secret": {
+			auth: secretAuth,
+			cred: func() *model.AzureEventGridCredential { return &model.AzureEventGridCredential{Secret: "blue"} },
+		},
+		"invalid secret": {
+			auth:    secretAuth,
+			cred:    func() *model.AzureEventGridCredential { return &model.AzureEventGridCredential{Secret: "orange"} },
+			wantErr: model.ErrUnauthenticated,
+		},
+		"no secret": {
+			auth:    secretAuth,
+			cred:    func() *model.AzureEventGridCredential { return &model.AzureEventGridCredential{} },
+			wantErr: model.ErrUnauthenticated,
+		},
+		"valid token": {
+			auth: tokenAuth,
+			cred: func() *model.AzureEventGridCredential {
+				return &model.AzureEventGridCredential{Token: sign(validClaims())}
+			},
+		},
+		"valid v1 token with app ID URI": {
+			auth: tokenAuth,
+			cred: func() *model.AzureEventGridCredential {
+				claims := validClaims()
+				claims["iss"] = "https://sts.windows.net/" + tenantID + "/"
+				claims["aud"] = "api://" + appID
+				delete(claims, "azp")
+				claims["appid"] = model.AzureEventGridAppID
+				return &model.AzureEventGridCredential{Token: sign(claims)}
+			},
+		},
+		"token of other tenant": {
+			auth: tokenAuth,
+			cred: func() *model.AzureEventGridCredential {
+				claims := validClaims()
+				claims["tid"] = "other-tenant"
+				claims["iss"] = "https://login.microsoftonline.com/other-tenant/v2.0"
+				return &model.AzureEventGridCredential{Token: sign(claims)}
+			},
+			wantErr: model.ErrUnauthenticated,
+		},
+		"token of other audience": {
+			auth: tokenAuth,
+			cred: func() *model.AzureEventGridCredential {
+				claims := validClaims()
+				claims["aud"] = "other-app"
+				return &model.AzureEventGridCredential{Token: sign(claims)}
+			},
+			wantErr: model.ErrForbidden,
+		},
+		"token of other caller app": {
+			auth: tokenAuth,
+			cred: func() *model.AzureEventGridCredential {
+				claims := validClaims()
+				claims["azp"] = "77777777-8888-9999-0000-111111111111"
+				return &model.AzureEventGridCredential{Token: sign(claims)}
+			},
+			wantErr: model.ErrForbidden,
+		},
+		"v1 token of other caller app": {
+			auth: tokenAuth,
+			cred: func() *model.AzureEventGridCredential {
+				claims := validClaims()
+				claims["iss"] = "https://sts.windows.net/" + tenantID + "/"
+				delete(claims, "azp")
+				claims["appid"] = "77777777-8888-9999-0000-111111111111"
+				return &model.AzureEventGridCredential{Token: sign(claims)}
+			},
+			wantErr: model.ErrForbidden,
+		},
+		"token of configured caller app": {
+			auth: &model.AzureEventGridAuth{
+				TenantID:     tokenAuth.TenantID,
+				Audiences:    tokenAuth.Audiences,
+				JWKSURL:      tokenAuth.JWKSURL,
+				CallerAppIDs: []string{"77777777-8888-9999-0000-111111111111"},
+			},
+			cred: func() *model.AzureEventGridCredential {
+				claims := validClaims()
+				claims["azp"] = "77777777-8888-9999-0000-111111111111"
+				return &model.AzureEventGridCredential{Token: sign(claims)}
+			},
+		},
+		"no token": {
+			auth:    tokenAuth,
+			cred:    func() *model.AzureEventGridCredential { return &model.AzureEventGridCredential{Secret: "blue"} },
+			wantErr: model.ErrUnauthenticated,
+		},
+		"both secret and token": {
+			auth: bothAuth,
+			cred: func() *model.AzureEventGridCredential {
+				return &model.AzureEventGridCredential{Secret: "blue", Token: sign(validClaims())}
+			},
+		},
+		"token without secret": {
+			auth: bothAuth,
+			cred: func() *model.AzureEventGridCredential {
+				return &model.AzureEventGridCredential{Token: sign(validClaims())}
+			},
+			wantErr: model.ErrUnauthenticated,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var options []usecase.Option
+			if tc.auth != nil {
+				options = append(options, usecase.WithAzureEventGridAuth(tc.auth))
+			}
+			uc := usecase.New(adapter.New(adapter.WithHTTPClient(&certHTTPClient{cert: jwks})), options...)
+
+			err := uc.VerifyAzureEventGridCredential(context.Background(), tc.cred())
+			if tc.wantErr != nil {
+				gt.True(t, errors.Is(err, tc.wantErr))
+			} else {
+				gt.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+type backfillItem struct {
+	seq      int
+	name     string
+	obj      model.Destination
+	filtered bool
+}
+
+type backfillDone struct {
+	seq  int
+	name string
+	// result is nil if the object is filtered
+	result *model.ReplayResult
+}
+
+// Backfill lists objects under the prefix of src and routes each object in the same way as a notification of the object is received. Objects are listed in lexical order and the progress is saved to the checkpoint file if specified, then interrupted backfill can be resumed. Objects that failed to be routed are counted in the report, and not retried by resuming.
+func (x *UseCase) Backfill(ctx context.Context, src model.Destination, options model.BackfillOptions) (*model.BackfillReport, error) {
+	if options.Concurrency < 1 {
+		return nil, goerr.New("concurrency must be 1 or more").With("concurrency", options.Concurrency)
+	}
+	if src.String() == "" {
+		return nil, goerr.New("source prefix is not specified")
+	}
+
+	logger := logging.From(ctx)
+	checkpoint := &model.BackfillCheckpoint{Source: src.String()}
+	if options.Checkpoint != "" {
+		saved, err := loadBackfillCheckpoint(options.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		if saved != nil {
+			if saved.Source != checkpoint.Source {
+				return nil, goerr.New("checkpoint is for another source").With("checkpoint", saved).With("source", checkpoint.Source)
+			}
+			checkpoint = saved
+			logger.Info("Resume backfill from checkpoint", "checkpoint", checkpoint)
+		}
+	}
+	report := &model.BackfillReport{DryRun: options.DryRun, Checkpoint: checkpoint.After}
+
+	items := make(chan *backfillItem)
+	dones := make(chan *backfillDone)
+	var wg sync.WaitGroup
+	for i := 0; i < options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				done := &backfillDone{seq: item.seq, name: item.name}
+				if !item.filtered {
+					done.result = x.replay(ctx, &replayRecord{
+						seq:    item.seq,
+						source: item.obj.String(),
+						input:  model.NewRouteInput(item.obj),
+					}, options.DryRun)
+				}
+				dones <- done
+			}
+		}()
+	}
+
+	var listErr error
+	go func() {
+		defer close(items)
+		var seq int
+		listErr = listObjects(ctx, x.clients, src, checkpoint.After, func(obj model.Destination, info *model.ObjectInfo) error {
+			item := &backfillItem{
+				seq:      seq,
+				name:     info.Name,
+				obj:      obj,
+				filtered: !options.InTimeRange(info.UpdatedAt),
+			}
+			seq++
+
+			select {
+			case items <- item:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	go func() {
+		wg.Wait()
+		close(dones)
+	}()
+
+	// Objects are processed concurrently, then checkpoint advances only to the object of which all preceding objects have been processed
+	finished := make(map[int]*backfillDone)
+	var next int
+	for done := range dones {
+		report.Listed++
+		switch {
+		case done.result == nil:
+			report.Filtered++
+		case done.result.Err != nil:
+			report.Failed++
+		case len(done.result.Destinations) == 0:
+			report.Skipped++
+		default:
+			report.Succeeded++
+		}
+
+		// Objects in progress at cancellation may fail by the cancellation. They must be processed again by resuming.
+		if ctx.Err() != nil {
+			continue
+		}
+
+		finished[done.seq] = done
+		advanced := false
+		for d, ok := finished[next]; ok; d, ok = finished[next] {
+			checkpoint.After = d.name
+			delete(finished, next)
+			next++
+			advanced = true
+		}
+
+		if advanced && options.Checkpoint != "" {
+			if err := saveBackfillCheckpoint(options.Checkpoint, checkpoint); err != nil {
+				logger.Warn("Failed to save backfill checkpoint", "error", err, "checkpoint", checkpoint)
+			}
+		}
+	}
+	report.Checkpoint = checkpoint.After
+
+	if options.Checkpoint != "" {
+		if err := saveBackfillCheckpoint(options.Checkpoint, checkpoint); err != nil {
+			return report, err
+		}
+	}
+	if listErr != nil {
+		return report, goerr.Wrap(listErr, "failed to list objects").With("source", src)
+	}
+
+	logger.Info("Backfill finished", "source", src, "checkpoint", checkpoint.After)
+	return report, nil
+}
+
+// loadBackfillCheckpoint reads checkpoint from the file. It returns nil if the file does not exist.
+func loadBackfillCheckpoint(path string) (*model.BackfillCheckpoint, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to read backfill checkpoint").With("path", path)
+	}
+
+	var checkpoint model.BackfillCheckpoint
+	if err := json.Unmarshal(raw, &checkpoint); err != nil {
+		return nil, goerr.Wrap(err, "failed to unmarshal backfill checkpoint").With("path", path)
+	}
+	return &checkpoint, nil
+}
+
+func saveBackfillCheckpoint(path string, checkpoint *model.BackfillCheckpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC()
+	raw, err := json.Marshal(checkpoint)
+	if err != nil {
+		return goerr.Wrap(err, "failed to marshal backfill checkpoint")
+	}
+
+	// Write to temporary file and rename it not to break checkpoint by interruption
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return goerr.Wrap(err, "failed to write backfill checkpoint").With("path", tmp)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return goerr.Wrap(err, "failed to rename backfill checkpoint").With("path", path)
+	}
+	return nil
+}
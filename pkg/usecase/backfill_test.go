@@ -0,0 +1,128 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+func TestBackfill(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+s3[dst] {
+	not startswith(input.gcs.object.name, "logs/ignored/")
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	newSource := func(names ...string) *mockGoogleCloudStorage {
+		mock := &mockGoogleCloudStorage{writes: map[string]*bytes.Buffer{}}
+		for _, name := range names {
+			mock.writes["nydus-src-bucket/"+name] = bytes.NewBufferString("content of " + name)
+		}
+		return mock
+	}
+
+	ctx := context.Background()
+	src := gt.R1(model.ParseDestination("gs://nydus-src-bucket/logs/")).NoError(t)
+
+	t.Run("route all objects under prefix", func(t *testing.T) {
+		gcsMock := newSource("logs/a.log", "logs/b.log", "logs/ignored/c.log", "other/d.log")
+		s3Mock := &mockAmazonS3{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(s3Mock),
+		))
+
+		report := gt.R1(uc.Backfill(ctx, *src, model.BackfillOptions{Concurrency: 2})).NoError(t)
+		gt.Equal(t, report.Listed, 3)
+		gt.Equal(t, report.Succeeded, 2)
+		gt.Equal(t, report.Skipped, 1)
+		gt.Equal(t, report.Failed, 0)
+		gt.Equal(t, report.Checkpoint, "logs/ignored/c.log")
+
+		gt.M(t, s3Mock.writes).Length(2)
+		gt.Equal(t, s3Mock.writes["ap-northeast-1/nydus-dst-bucket/logs/a.log"].String(), "content of logs/a.log")
+		gt.Equal(t, s3Mock.writes["ap-northeast-1/nydus-dst-bucket/logs/b.log"].String(), "content of logs/b.log")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		s3Mock := &mockAmazonS3{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(newSource("logs/a.log", "logs/b.log")),
+			adapter.WithAmazonS3(s3Mock),
+		))
+
+		report := gt.R1(uc.Backfill(ctx, *src, model.BackfillOptions{Concurrency: 1, DryRun: true})).NoError(t)
+		gt.Equal(t, report.Succeeded, 2)
+		gt.M(t, s3Mock.writes).Length(0)
+	})
+
+	t.Run("filter by updated time", func(t *testing.T) {
+		s3Mock := &mockAmazonS3{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(newSource("logs/a.log", "logs/b.log")),
+			adapter.WithAmazonS3(s3Mock),
+		))
+
+		// Updated time of mock objects is zero
+		report := gt.R1(uc.Backfill(ctx, *src, model.BackfillOptions{
+			Concurrency: 1,
+			Since:       time.Now().Add(-time.Hour),
+		})).NoError(t)
+		gt.Equal(t, report.Listed, 2)
+		gt.Equal(t, report.Filtered, 2)
+		gt.M(t, s3Mock.writes).Length(0)
+	})
+
+	t.Run("resume from checkpoint", func(t *testing.T) {
+		checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+		gcsMock := newSource("logs/a.log", "logs/b.log")
+		s3Mock := &mockAmazonS3{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(s3Mock),
+		))
+
+		options := model.BackfillOptions{Concurrency: 2, Checkpoint: checkpoint}
+		gt.R1(uc.Backfill(ctx, *src, options)).NoError(t)
+
+		var saved model.BackfillCheckpoint
+		gt.NoError(t, json.Unmarshal(gt.R1(os.ReadFile(checkpoint)).NoError(t), &saved))
+		gt.Equal(t, saved.Source, "gs://nydus-src-bucket/logs/")
+		gt.Equal(t, saved.After, "logs/b.log")
+
+		// Only objects after the checkpoint are routed
+		gcsMock.writes["nydus-src-bucket/logs/0.log"] = bytes.NewBufferString("before checkpoint")
+		gcsMock.writes["nydus-src-bucket/logs/c.log"] = bytes.NewBufferString("after checkpoint")
+		report := gt.R1(uc.Backfill(ctx, *src, options)).NoError(t)
+		gt.Equal(t, report.Listed, 1)
+		gt.Equal(t, report.Checkpoint, "logs/c.log")
+		gt.M(t, s3Mock.writes).Length(3)
+		gt.Equal(t, s3Mock.writes["ap-northeast-1/nydus-dst-bucket/logs/c.log"].String(), "after checkpoint")
+
+		// Checkpoint can not be used for another source
+		other := gt.R1(model.ParseDestination("gs://nydus-src-bucket/other/")).NoError(t)
+		gt.R1(uc.Backfill(ctx, *other, options)).Error(t)
+	})
+}
@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter/policy"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// policyBundle is a state of polling OPA bundle
+type policyBundle struct {
+	config *model.PolicyBundle
+	// etag is ETag of the last fetched bundle, including a bundle that failed to be compiled. The same bundle is not fetched again.
+	etag string
+}
+
+// WithPolicyBundle loads route policy from OPA bundle instead of policy files. LoadPolicyBundle must be called before routing.
+func WithPolicyBundle(bundle *model.PolicyBundle) Option {
+	return func(uc *UseCase) {
+		uc.policyBundle = &policyBundle{config: bundle}
+	}
+}
+
+// LoadPolicyBundle fetches the policy bundle and replaces route policy. It does nothing if policy bundle is not configured.
+func (x *UseCase) LoadPolicyBundle(ctx context.Context) error {
+	if x.policyBundle == nil {
+		return nil
+	}
+	return x.reloadPolicyBundle(ctx)
+}
+
+// WatchPolicyBundle polls the policy bundle at the configured interval until ctx is canceled. If the bundle can not be fetched, verified or compiled, the last good policy is kept.
+func (x *UseCase) WatchPolicyBundle(ctx context.Context) {
+	if x.policyBundle == nil || x.policyBundle.config.Interval <= 0 {
+		return
+	}
+
+	logger := logging.From(ctx).With("source", x.policyBundle.config.Source)
+	ticker := time.NewTicker(x.policyBundle.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := x.reloadPolicyBundle(ctx); err != nil {
+			_, version := x.clients.Policy()
+			logger.Warn("Failed to reload policy bundle, keep last good policy", "error", err, "version", version)
+		}
+	}
+}
+
+func (x *UseCase) reloadPolicyBundle(ctx context.Context) error {
+	logger := logging.From(ctx).With("source", x.policyBundle.config.Source)
+
+	raw, etag, err := x.fetchPolicyBundle(ctx)
+	if err != nil {
+		return err
+	}
+	if raw == nil {
+		logger.Debug("Policy bundle is not modified", "etag", etag)
+		return nil
+	}
+	// Fetched bundle is not fetched again even if it is broken. It will be fixed by a new bundle.
+	x.policyBundle.etag = etag
+
+	query, version, err := policy.ReadBundle(raw, x.policyBundle.config.Verification, x.policyBundle.config.Insecure)
+	if err != nil {
+		return goerr.Wrap(err, "failed to load policy bundle").With("etag", etag)
+	}
+
+	if _, current := x.clients.Policy(); current == version {
+		return nil
+	}
+	x.clients.SetPolicy(query, version)
+	logger.Info("Policy bundle is loaded", "version", version, "etag", etag)
+
+	return nil
+}
+
+// fetchPolicyBundle downloads the policy bundle. It returns nil content if the bundle is not modified since the last fetch.
+func (x *UseCase) fetchPolicyBundle(ctx context.Context) ([]byte, string, error) {
+	config := x.policyBundle.config
+	if config.IsHTTP() {
+		return x.fetchPolicyBundleFromServer(ctx)
+	}
+
+	obj, err := model.ParseDestination(config.Source)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// ETag is taken from attributes of the object so that the bundle is not downloaded if it is not modified
+	stat, err := statDestination(ctx, x.clients, *obj)
+	if errors.Is(err, model.ErrObjectNotFound) {
+		return nil, "", goerr.Wrap(err, "policy bundle is not found").With("source", config.Source)
+	}
+	if err != nil {
+		return nil, "", goerr.Wrap(err, "failed to get ETag of policy bundle").With("source", config.Source)
+	}
+	etag := stat.ETag
+	if etag != "" && etag == x.policyBundle.etag {
+		return nil, etag, nil
+	}
+
+	r, err := newReaderFromDestination(ctx, x.clients, *obj)
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", goerr.Wrap(err, "failed to read policy bundle").With("source", config.Source)
+	}
+	return raw, etag, nil
+}
+
+func (x *UseCase) fetchPolicyBundleFromServer(ctx context.Context) ([]byte, string, error) {
+	config := x.policyBundle.config
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.Source, nil)
+	if err != nil {
+		return nil, "", goerr.Wrap(err, "failed to create HTTP request").With("source", config.Source)
+	}
+	if x.policyBundle.etag != "" {
+		req.Header.Set("If-None-Match", x.policyBundle.etag)
+	}
+	if config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+config.Token)
+	}
+
+	resp, err := x.clients.HTTPClient().Do(req)
+	if err != nil {
+		return nil, "", goerr.Wrap(err, "failed to send HTTP request").With("source", config.Source)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, x.policyBundle.etag, nil
+
+	case http.StatusOK:
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, "", goerr.Wrap(err, "failed to read policy bundle").With("source", config.Source)
+		}
+		return raw, resp.Header.Get("ETag"), nil
+
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, "", goerr.New("bundle server response is not OK").With("statusCode", resp.StatusCode).With("body", string(body)).With("source", config.Source)
+	}
+}
@@ -0,0 +1,256 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/bundle"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+// bundleServer serves an OPA bundle in the same manner as a bundle server
+type bundleServer struct {
+	bundle   []byte
+	etag     string
+	status   int
+	requests []*http.Request
+}
+
+func (x *bundleServer) Do(req *http.Request) (*http.Response, error) {
+	x.requests = append(x.requests, req)
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(x.bundle)),
+	}
+	switch {
+	case x.status != 0:
+		resp.StatusCode = x.status
+	case x.etag != "" && req.Header.Get("If-None-Match") == x.etag:
+		resp.StatusCode = http.StatusNotModified
+		resp.Body = io.NopCloser(bytes.NewReader(nil))
+	default:
+		resp.Header.Set("ETag", x.etag)
+	}
+	return resp, nil
+}
+
+func newBundle(t *testing.T, bucket string, signingKey string) []byte {
+	rego := `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "` + bucket + `",
+		"name": input.gcs.object.name,
+	}
+}
+`
+	b := bundle.Bundle{
+		Manifest: bundle.Manifest{Revision: bucket},
+		Modules: []bundle.ModuleFile{
+			{
+				URL:    "/route.rego",
+				Path:   "/route.rego",
+				Raw:    []byte(rego),
+				Parsed: ast.MustParseModule(rego),
+			},
+		},
+		Data: map[string]any{},
+	}
+	b.Manifest.Init()
+
+	if signingKey != "" {
+		gt.NoError(t, b.GenerateSignature(bundle.NewSigningConfig(signingKey, "HS256", ""), "nydus", false))
+	}
+
+	var buf bytes.Buffer
+	gt.NoError(t, bundle.NewWriter(&buf).Write(b))
+	return buf.Bytes()
+}
+
+func TestPolicyBundle(t *testing.T) {
+	ctx := context.Background()
+	input := &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Object: model.GoogleCloudStorageObject{
+				Bucket: "nydus-src-bucket",
+				Name:   "blue.txt",
+			},
+		},
+	}
+
+	t.Run("bundle server", func(t *testing.T) {
+		server := &bundleServer{bundle: newBundle(t, "dst-v1", ""), etag: `"v1"`}
+		gcsMock := &mockGoogleCloudStorage{}
+		clients := adapter.New(
+			adapter.WithHTTPClient(server),
+			adapter.WithGoogleCloudStorage(gcsMock),
+		)
+		uc := usecase.New(clients, usecase.WithPolicyBundle(&model.PolicyBundle{
+			Source:   "https://bundle.example.com/bundles/nydus.tar.gz",
+			Token:    "secret-token",
+			Insecure: true,
+		}))
+
+		gt.NoError(t, uc.LoadPolicyBundle(ctx))
+		gt.A(t, server.requests).Length(1).At(0, func(t testing.TB, req *http.Request) {
+			gt.Equal(t, req.Header.Get("Authorization"), "Bearer secret-token")
+			gt.Equal(t, req.Header.Get("If-None-Match"), "")
+		})
+		_, v1 := clients.Policy()
+		gt.NoError(t, uc.Route(ctx, input))
+		gt.Equal(t, gcsMock.writes["dst-v1/blue.txt"].String(), "timeless words")
+
+		// Not modified bundle is not loaded again
+		gt.NoError(t, uc.LoadPolicyBundle(ctx))
+		gt.A(t, server.requests).Length(2).At(1, func(t testing.TB, req *http.Request) {
+			gt.Equal(t, req.Header.Get("If-None-Match"), `"v1"`)
+		})
+		_, version := clients.Policy()
+		gt.Equal(t, version, v1)
+
+		// Broken bundle keeps the last good policy
+		server.bundle, server.etag = []byte("not a bundle"), `"broken"`
+		gt.Error(t, uc.LoadPolicyBundle(ctx))
+		_, version = clients.Policy()
+		gt.Equal(t, version, v1)
+
+		// Server error also keeps the last good policy
+		server.status = http.StatusInternalServerError
+		gt.Error(t, uc.LoadPolicyBundle(ctx))
+		server.status = 0
+
+		// New bundle replaces the policy
+		server.bundle, server.etag = newBundle(t, "dst-v2", ""), `"v2"`
+		gt.NoError(t, uc.LoadPolicyBundle(ctx))
+		_, version = clients.Policy()
+		gt.NotEqual(t, version, v1)
+		gt.NoError(t, uc.Route(ctx, input))
+		gt.Equal(t, gcsMock.writes["dst-v2/blue.txt"].String(), "timeless words")
+	})
+
+	t.Run("signed bundle", func(t *testing.T) {
+		newUseCase := func(raw []byte) (*usecase.UseCase, *adapter.Clients) {
+			clients := adapter.New(adapter.WithHTTPClient(&bundleServer{bundle: raw}))
+			return usecase.New(clients, usecase.WithPolicyBundle(&model.PolicyBundle{
+				Source: "https://bundle.example.com/bundles/nydus.tar.gz",
+				Verification: &model.PolicyBundleKey{
+					Key:       "signing-secret",
+					KeyID:     "nydus",
+					Algorithm: "HS256",
+				},
+			})), clients
+		}
+
+		uc, clients := newUseCase(newBundle(t, "dst-v1", "signing-secret"))
+		gt.NoError(t, uc.LoadPolicyBundle(ctx))
+		query, _ := clients.Policy()
+		gt.True(t, query != nil)
+
+		// Signed by another key
+		uc, clients = newUseCase(newBundle(t, "dst-v1", "another-secret"))
+		gt.Error(t, uc.LoadPolicyBundle(ctx))
+		query, _ = clients.Policy()
+		gt.True(t, query == nil)
+
+		// Not signed
+		uc, _ = newUseCase(newBundle(t, "dst-v1", ""))
+		gt.Error(t, uc.LoadPolicyBundle(ctx))
+	})
+
+	t.Run("object storage", func(t *testing.T) {
+		gcsMock := &mockGoogleCloudStorage{writes: map[string]*bytes.Buffer{
+			"nydus-policy-bucket/bundle.tar.gz": bytes.NewBuffer(newBundle(t, "dst-v1", "")),
+		}}
+		clients := adapter.New(adapter.WithGoogleCloudStorage(gcsMock))
+		uc := usecase.New(clients, usecase.WithPolicyBundle(&model.PolicyBundle{
+			Source:   "gs://nydus-policy-bucket/bundle.tar.gz",
+			Insecure: true,
+		}))
+
+		gt.NoError(t, uc.LoadPolicyBundle(ctx))
+		gt.NoError(t, uc.Route(ctx, input))
+		gt.Equal(t, gcsMock.writes["dst-v1/blue.txt"].String(), "timeless words")
+
+		// Missing bundle object is an error even if another object has its name as prefix
+		gcsMock.writes["nydus-policy-bucket/missing.tar.gz.bak"] = bytes.NewBuffer(newBundle(t, "dst-v1", ""))
+		uc = usecase.New(clients, usecase.WithPolicyBundle(&model.PolicyBundle{
+			Source:   "gs://nydus-policy-bucket/missing.tar.gz",
+			Insecure: true,
+		}))
+		gt.True(t, errors.Is(uc.LoadPolicyBundle(ctx), model.ErrObjectNotFound))
+
+		// Unsigned bundle is not loaded without insecure
+		uc = usecase.New(clients, usecase.WithPolicyBundle(&model.PolicyBundle{
+			Source: "gs://nydus-policy-bucket/bundle.tar.gz",
+		}))
+		gt.Error(t, uc.LoadPolicyBundle(ctx))
+	})
+
+	t.Run("object storage bundle is not downloaded if ETag is not changed", func(t *testing.T) {
+		gcsMock := &mockGoogleCloudStorage{
+			writes: map[string]*bytes.Buffer{
+				"nydus-policy-bucket/bundle.tar.gz": bytes.NewBuffer(newBundle(t, "dst-v1", "")),
+			},
+			stats: map[string]*model.ObjectStat{
+				"nydus-policy-bucket/bundle.tar.gz": {ETag: "CJjV8s2Gt4gDEAE="},
+			},
+		}
+		uc := usecase.New(adapter.New(adapter.WithGoogleCloudStorage(gcsMock)), usecase.WithPolicyBundle(&model.PolicyBundle{
+			Source:   "gs://nydus-policy-bucket/bundle.tar.gz",
+			Insecure: true,
+		}))
+
+		gt.NoError(t, uc.LoadPolicyBundle(ctx))
+		gt.NoError(t, uc.LoadPolicyBundle(ctx))
+		gt.A(t, gcsMock.reads).Length(1)
+
+		gcsMock.stats["nydus-policy-bucket/bundle.tar.gz"] = &model.ObjectStat{ETag: "CJjV8s2Gt4gDEAI="}
+		gt.NoError(t, uc.LoadPolicyBundle(ctx))
+		gt.A(t, gcsMock.reads).Length(2)
+	})
+
+	t.Run("verification key is required", func(t *testing.T) {
+		key := &model.PolicyBundleKey{Key: "signing-secret", KeyID: "nydus", Algorithm: "HS256"}
+
+		testCases := map[string]struct {
+			bundle  model.PolicyBundle
+			wantErr bool
+		}{
+			"with key": {
+				bundle: model.PolicyBundle{Source: "http://bundle.example.com/nydus.tar.gz", Verification: key},
+			},
+			"insecure": {
+				bundle: model.PolicyBundle{Source: "https://bundle.example.com/nydus.tar.gz", Insecure: true},
+			},
+			"without key": {
+				bundle:  model.PolicyBundle{Source: "gs://nydus-policy-bucket/bundle.tar.gz"},
+				wantErr: true,
+			},
+			"plain HTTP without key": {
+				bundle:  model.PolicyBundle{Source: "http://bundle.example.com/nydus.tar.gz", Insecure: true},
+				wantErr: true,
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				err := tc.bundle.Validate()
+				if tc.wantErr {
+					gt.Error(t, err)
+				} else {
+					gt.NoError(t, err)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"context"
+	"crypto/md5"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// WithChecksumVerification enables or disables verification of copied content. If enabled, size and checksums of content read from the source are compared with ones declared in the event, and transfer fails on mismatch. It is enabled by default.
+func WithChecksumVerification(verify bool) Option {
+	return func(uc *UseCase) {
+		uc.verifyChecksum = verify
+	}
+}
+
+// declaredChecksum returns size and checksums of the source object to verify copied content. Amazon S3 event has only ETag that can not be distinguished from MD5 digest of an object encrypted by KMS or customer key, then digests of Amazon S3 object are taken from attributes of the source object that tell the encryption.
+func (x *UseCase) declaredChecksum(ctx context.Context, source *sourceStat) *model.Checksum {
+	sum := source.input.Checksum()
+	if source.input.AmazonS3 == nil {
+		return sum
+	}
+
+	// Failure is already logged when getting attributes of the source object, and only size is compared then. Attributes of an object overwritten after the event are not of the content to be read.
+	stat, err := source.get(ctx, x.clients)
+	if err != nil {
+		return sum
+	}
+	if etag := strings.Trim(source.input.AmazonS3.Object.ETag, `"`); etag != "" && etag != strings.Trim(stat.ETag, `"`) {
+		return sum
+	}
+	sum.MD5 = stat.MD5
+	sum.CRC32C = stat.CRC32C
+	return sum
+}
+
+// checksumReader computes size, MD5 and CRC32C of content while reading
+type checksumReader struct {
+	r      io.Reader
+	size   int64
+	md5    hash.Hash
+	crc32c hash.Hash32
+}
+
+func newChecksumReader(r io.Reader) *checksumReader {
+	return &checksumReader{
+		r:      r,
+		md5:    md5.New(),
+		crc32c: crc32.New(crc32.MakeTable(crc32.Castagnoli)),
+	}
+}
+
+func (x *checksumReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	if n > 0 {
+		x.size += int64(n)
+		_, _ = x.md5.Write(p[:n])
+		_, _ = x.crc32c.Write(p[:n])
+	}
+	return n, err
+}
+
+// Checksum returns checksum of content read so far
+func (x *checksumReader) Checksum() *model.Checksum {
+	crc := x.crc32c.Sum32()
+	return &model.Checksum{
+		Size:   x.size,
+		MD5:    x.md5.Sum(nil),
+		CRC32C: &crc,
+	}
+}
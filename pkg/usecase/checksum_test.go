@@ -0,0 +1,276 @@
+package usecase_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+func TestChecksumVerification(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	// Checksums of "timeless words" returned by mock reader
+	md5sum := md5.Sum([]byte("timeless words"))
+	crc32c := uint32(3498549280)
+
+	testCases := map[string]struct {
+		object    model.GoogleCloudStorageObject
+		options   []usecase.Option
+		wantErr   bool
+		wantReads int
+		wantAttrs *model.ObjectAttrs
+	}{
+		"matched checksums are passed to writer": {
+			object:    model.GoogleCloudStorageObject{Size: 14, MD5Hash: "CnVduyn5I/uEL6Q0IaGudQ==", CRC32C: "0IegIA=="},
+			wantReads: 1,
+			wantAttrs: &model.ObjectAttrs{MD5: md5sum[:], CRC32C: &crc32c},
+		},
+		"no checksum declared": {
+			object:    model.GoogleCloudStorageObject{},
+			wantReads: 1,
+			wantAttrs: &model.ObjectAttrs{},
+		},
+		"size mismatch": {
+			object:    model.GoogleCloudStorageObject{Size: 15},
+			wantErr:   true,
+			wantReads: 1,
+		},
+		"MD5 mismatch": {
+			object:    model.GoogleCloudStorageObject{MD5Hash: "q8A2tDMNmpWPQXz3PrrlxA=="},
+			wantErr:   true,
+			wantReads: 1,
+		},
+		"CRC32C mismatch": {
+			object:    model.GoogleCloudStorageObject{CRC32C: "yZRlqg=="},
+			wantErr:   true,
+			wantReads: 1,
+		},
+		"mismatch is retried": {
+			object: model.GoogleCloudStorageObject{MD5Hash: "q8A2tDMNmpWPQXz3PrrlxA=="},
+			options: []usecase.Option{usecase.WithRetryPolicy(&model.RetryPolicy{
+				MaxAttempts: 2,
+				BaseBackoff: time.Millisecond,
+				MaxBackoff:  time.Millisecond,
+			})},
+			wantErr:   true,
+			wantReads: 2,
+		},
+		"verification is disabled": {
+			object:    model.GoogleCloudStorageObject{Size: 15, MD5Hash: "q8A2tDMNmpWPQXz3PrrlxA=="},
+			options:   []usecase.Option{usecase.WithChecksumVerification(false)},
+			wantReads: 1,
+			wantAttrs: &model.ObjectAttrs{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gcsMock := &mockGoogleCloudStorage{}
+			s3Mock := &mockAmazonS3{}
+			uc := usecase.New(adapter.New(
+				adapter.WithPolicy(policy),
+				adapter.WithGoogleCloudStorage(gcsMock),
+				adapter.WithAmazonS3(s3Mock),
+			), tc.options...)
+
+			tc.object.Bucket = "nydus-src-bucket"
+			tc.object.Name = "blue.txt"
+			err := uc.Route(context.Background(), &model.RouteInput{
+				GoogleCloudStorage: &model.GoogleCloudStorageEvent{Object: tc.object},
+			})
+			gt.A(t, gcsMock.reads).Length(tc.wantReads)
+
+			if tc.wantErr {
+				gt.Error(t, err)
+				gt.True(t, errors.Is(err, model.ErrChecksumMismatch))
+				return
+			}
+
+			gt.NoError(t, err)
+			gt.Equal(t, s3Mock.writes["ap-northeast-1/nydus-dst-bucket/blue.txt"].String(), "timeless words")
+			attrs := s3Mock.attrs["ap-northeast-1/nydus-dst-bucket/blue.txt"]
+			gt.Equal(t, attrs.MD5, tc.wantAttrs.MD5)
+			gt.Equal(t, attrs.CRC32C, tc.wantAttrs.CRC32C)
+		})
+	}
+}
+
+func TestAmazonS3SourceChecksum(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.s3.object.key,
+	}
+}
+`,
+	}))).NoError(t)
+
+	md5sum := md5.Sum([]byte("timeless words"))
+	otherSum := md5.Sum([]byte("other words"))
+
+	// ETag of an object encrypted by KMS looks like MD5 digest, but it is not
+	const etag = "3b5ec2b2a6fcd4d1e4f0a9a7c3f1f0e2"
+
+	testCases := map[string]struct {
+		stat      *model.ObjectStat
+		wantErr   bool
+		wantAttrs *model.ObjectAttrs
+	}{
+		"MD5 of unencrypted object is verified": {
+			stat:      &model.ObjectStat{Size: 14, ETag: `"` + etag + `"`, MD5: md5sum[:]},
+			wantAttrs: &model.ObjectAttrs{MD5: md5sum[:]},
+		},
+		"only size of object encrypted by KMS is verified": {
+			stat:      &model.ObjectStat{Size: 14, ETag: `"` + etag + `"`},
+			wantAttrs: &model.ObjectAttrs{},
+		},
+		"MD5 mismatch": {
+			stat:    &model.ObjectStat{Size: 14, ETag: `"` + etag + `"`, MD5: otherSum[:]},
+			wantErr: true,
+		},
+		"MD5 of object overwritten after the event is not verified": {
+			stat:      &model.ObjectStat{Size: 11, ETag: `"` + hex.EncodeToString(otherSum[:]) + `"`, MD5: otherSum[:]},
+			wantAttrs: &model.ObjectAttrs{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s3Mock := &mockAmazonS3{stats: map[string]*model.ObjectStat{
+				"ap-northeast-1/nydus-src-bucket/blue.txt": tc.stat,
+			}}
+			gcsMock := &mockGoogleCloudStorage{}
+			uc := usecase.New(adapter.New(
+				adapter.WithPolicy(policy),
+				adapter.WithGoogleCloudStorage(gcsMock),
+				adapter.WithAmazonS3(s3Mock),
+			))
+
+			err := uc.Route(context.Background(), &model.RouteInput{
+				AmazonS3: &model.AmazonS3Event{Object: model.AmazonS3Object{
+					Region: "ap-northeast-1",
+					Bucket: "nydus-src-bucket",
+					Key:    "blue.txt",
+					Size:   14,
+					ETag:   etag,
+				}},
+			})
+
+			if tc.wantErr {
+				gt.True(t, errors.Is(err, model.ErrChecksumMismatch))
+				return
+			}
+
+			gt.NoError(t, err)
+			gt.Equal(t, gcsMock.writes["nydus-dst-bucket/blue.txt"].String(), "timeless words")
+			gt.Equal(t, gcsMock.attrs["nydus-dst-bucket/blue.txt"].MD5, tc.wantAttrs.MD5)
+		})
+	}
+}
+
+func TestRouteInputChecksum(t *testing.T) {
+	t.Run("ETag of Amazon S3 is not MD5", func(t *testing.T) {
+		input := &model.RouteInput{AmazonS3: &model.AmazonS3Event{Object: model.AmazonS3Object{
+			Size: 14,
+			ETag: `"0a755dbb29f923fb842fa43421a1ae75"`,
+		}}}
+		sum := input.Checksum()
+		gt.Equal(t, sum.Size, 14)
+		gt.A(t, sum.MD5).Length(0)
+	})
+
+	t.Run("content length of Azure Blob Storage", func(t *testing.T) {
+		input := &model.RouteInput{AzureBlobStorage: &model.AzureBlobStorageEvent{Object: model.AzureBlobStorageObject{
+			Size: 14,
+			ETag: "0x8DCC5A1B2C3D4E5",
+		}}}
+		sum := input.Checksum()
+		gt.Equal(t, sum.Size, 14)
+		gt.A(t, sum.MD5).Length(0)
+		gt.True(t, sum.CRC32C == nil)
+	})
+}
+
+func TestSourceChangedAfterEvent(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	ctx := context.Background()
+	gcsMock := &mockGoogleCloudStorage{generations: map[string]int64{
+		"nydus-src-bucket/blue.txt": 2,
+	}}
+	s3Mock := &mockAmazonS3{}
+	store := newJobStore(t)
+	uc := usecase.New(adapter.New(
+		adapter.WithPolicy(policy),
+		adapter.WithGoogleCloudStorage(gcsMock),
+		adapter.WithAmazonS3(s3Mock),
+		adapter.WithJobStore(store),
+	), usecase.WithRetryPolicy(&model.RetryPolicy{
+		MaxAttempts: 3,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	}))
+
+	// Delayed event of the overwritten generation is skipped without retry, and the event is not redelivered
+	gt.NoError(t, uc.Route(ctx, &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{Object: model.GoogleCloudStorageObject{
+			Bucket:     "nydus-src-bucket",
+			Name:       "blue.txt",
+			Generation: 1,
+			Size:       15,
+		}},
+	}))
+	gt.A(t, gcsMock.reads).Length(1)
+	gt.Equal(t, len(s3Mock.writes), 0)
+
+	jobs := gt.R1(store.ListJobs(ctx)).NoError(t)
+	gt.A(t, jobs).Length(1).At(0, func(t testing.TB, job *model.Job) {
+		gt.Equal(t, job.Transfers[0].State, model.JobSkipped)
+		gt.Equal(t, job.Transfers[0].Attempts, 1)
+	})
+
+	// Event of the latest generation is transferred
+	gt.NoError(t, uc.Route(ctx, &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{Object: model.GoogleCloudStorageObject{
+			Bucket:     "nydus-src-bucket",
+			Name:       "blue.txt",
+			Generation: 2,
+			Size:       14,
+		}},
+	}))
+	gt.Equal(t, s3Mock.writes["ap-northeast-1/nydus-dst-bucket/blue.txt"].String(), "timeless words")
+}
@@ -0,0 +1,22 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// Copy transfers the source object in the same way as a notification of the object is received. If dsts is given, the route policy is not evaluated and the object is transferred to dsts.
+func (x *UseCase) Copy(ctx context.Context, src model.Destination, dsts []model.Destination) error {
+	input := model.NewRouteInput(src)
+	if input.AzureBlobStorage == nil && input.GoogleCloudStorage == nil && input.AmazonS3 == nil {
+		return goerr.New("source object is not specified")
+	}
+
+	if len(dsts) == 0 {
+		return x.Route(ctx, input)
+	}
+
+	return x.dispatch(ctx, input, dsts)
+}
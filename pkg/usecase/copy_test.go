@@ -0,0 +1,68 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+func TestCopy(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	ctx := context.Background()
+	src := gt.R1(model.ParseDestination("gs://nydus-src-bucket/blue.txt")).NoError(t)
+
+	t.Run("routed by policy", func(t *testing.T) {
+		gcsMock := &mockGoogleCloudStorage{}
+		s3Mock := &mockAmazonS3{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(s3Mock),
+		))
+
+		gt.NoError(t, uc.Copy(ctx, *src, nil))
+		gt.A(t, gcsMock.reads).Length(1)
+		gt.M(t, s3Mock.writes).Length(1)
+		gt.Equal(t, s3Mock.writes["ap-northeast-1/nydus-dst-bucket/blue.txt"].String(), "timeless words")
+	})
+
+	t.Run("explicit destinations bypass policy", func(t *testing.T) {
+		gcsMock := &mockGoogleCloudStorage{}
+		s3Mock := &mockAmazonS3{}
+		// No policy client is configured
+		uc := usecase.New(adapter.New(
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(s3Mock),
+		))
+
+		dsts := []model.Destination{
+			*gt.R1(model.ParseDestination("gs://nydus-dst-bucket/copied/blue.txt")).NoError(t),
+			*gt.R1(model.ParseDestination("s3://us-east-1/nydus-dst-bucket/copied/blue.txt")).NoError(t),
+		}
+		gt.NoError(t, uc.Copy(ctx, *src, dsts))
+		gt.Equal(t, gcsMock.writes["nydus-dst-bucket/copied/blue.txt"].String(), "timeless words")
+		gt.Equal(t, s3Mock.writes["us-east-1/nydus-dst-bucket/copied/blue.txt"].String(), "timeless words")
+	})
+
+	t.Run("empty source", func(t *testing.T) {
+		uc := usecase.New(adapter.New())
+		gt.Error(t, uc.Copy(ctx, model.Destination{}, nil))
+	})
+}
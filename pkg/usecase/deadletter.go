@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// WithDeadLetter enables writing a dead letter for each transfer to a destination that failed finally
+func WithDeadLetter(location *model.DeadLetterLocation) Option {
+	return func(uc *UseCase) {
+		uc.deadLetter = location
+	}
+}
+
+// sendDeadLetter writes a dead letter of the failed transfer. Failure of writing is logged with the dead letter so that it is not lost.
+func (x *UseCase) sendDeadLetter(ctx context.Context, job *model.Job, transfer *model.JobTransfer, cause error) {
+	if x.deadLetter == nil {
+		return
+	}
+
+	logger := logging.From(ctx)
+	letter := model.NewDeadLetter(job, transfer, cause)
+
+	// Dead letter must be written even if the transfer is canceled
+	if err := x.writeDeadLetter(context.WithoutCancel(ctx), letter); err != nil {
+		logger.Error("Failed to write dead letter", "error", err, "dead_letter", letter)
+		return
+	}
+
+	logger.Info("Wrote dead letter", "job_id", job.ID, "destination", transfer.Destination, "location", x.deadLetter, "name", letter.Name())
+}
+
+func (x *UseCase) writeDeadLetter(ctx context.Context, letter *model.DeadLetter) error {
+	raw, err := json.MarshalIndent(letter, "", "  ")
+	if err != nil {
+		return goerr.Wrap(err, "failed to marshal dead letter")
+	}
+
+	if x.deadLetter.Prefix != nil {
+		dst := x.deadLetter.Prefix.Join(letter.Name())
+		if err := writeObject(ctx, x.clients, dst, &model.ObjectAttrs{ContentType: "application/json"}, raw); err != nil {
+			return goerr.Wrap(err, "failed to write dead letter")
+		}
+		return nil
+	}
+
+	path := filepath.Join(x.deadLetter.Dir, filepath.FromSlash(letter.Name()))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return goerr.Wrap(err, "failed to create dead letter directory").With("path", path)
+	}
+
+	// Write to temporary file and rename it so that a reader never sees partial dead letter
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return goerr.Wrap(err, "failed to write dead letter").With("path", tmp)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return goerr.Wrap(err, "failed to rename dead letter").With("path", path)
+	}
+
+	return nil
+}
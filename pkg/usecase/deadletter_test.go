@@ -0,0 +1,135 @@
+package usecase_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+// abortingAmazonS3 fails writing, and records whether the context of writer is canceled to abort upload before Close
+type abortingAmazonS3 struct {
+	mockAmazonS3
+	aborted []bool
+}
+
+type abortingWriter struct {
+	ctx    context.Context
+	client *abortingAmazonS3
+}
+
+func (x *abortingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func (x *abortingWriter) Close() error {
+	x.client.mutex.Lock()
+	defer x.client.mutex.Unlock()
+	x.client.aborted = append(x.client.aborted, x.ctx.Err() != nil)
+	return nil
+}
+
+func (x *abortingAmazonS3) NewWriter(ctx context.Context, region, bucket, key string, attrs *model.ObjectAttrs, opts *model.AmazonS3WriteOptions) (io.WriteCloser, error) {
+	return &abortingWriter{ctx: ctx, client: x}, nil
+}
+
+func TestDeadLetter(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	input := &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Object: model.GoogleCloudStorageObject{
+				Bucket: "nydus-src-bucket",
+				Name:   "blue.txt",
+			},
+		},
+	}
+
+	verify := func(t *testing.T, raw []byte) {
+		var letter model.DeadLetter
+		gt.NoError(t, json.Unmarshal(raw, &letter))
+		gt.Equal(t, letter.Input.GoogleCloudStorage.Object.Name, "blue.txt")
+		gt.Equal(t, letter.Destination.String(), "s3://ap-northeast-1/nydus-dst-bucket/blue.txt")
+		gt.Equal(t, letter.Attempts, 1)
+
+		// Error chain has values of goerr
+		gt.A(t, letter.Errors).Longer(1).At(0, func(t testing.TB, v *model.ErrorRecord) {
+			gt.Equal(t, v.Message, "failed to copy to destination")
+			dst, ok := v.Values["destination"].(map[string]any)
+			gt.True(t, ok)
+			gt.True(t, dst["s3"] != nil)
+		})
+		gt.Equal(t, letter.Errors[len(letter.Errors)-1].Message, "write failed")
+	}
+
+	t.Run("local directory", func(t *testing.T) {
+		dir := t.TempDir()
+		location := gt.R1(model.ParseDeadLetterLocation(dir)).NoError(t)
+
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(&mockGoogleCloudStorage{}),
+			adapter.WithAmazonS3(&failingAmazonS3{}),
+		), usecase.WithDeadLetter(location))
+		gt.Error(t, uc.Route(context.Background(), input))
+
+		files := gt.R1(filepath.Glob(filepath.Join(dir, "*", "*", "*", "*.json"))).NoError(t)
+		gt.A(t, files).Length(1)
+		verify(t, gt.R1(os.ReadFile(files[0])).NoError(t))
+	})
+
+	t.Run("object storage", func(t *testing.T) {
+		location := gt.R1(model.ParseDeadLetterLocation("gs://nydus-dlq-bucket/dead-letter")).NoError(t)
+
+		gcsMock := &mockGoogleCloudStorage{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(&failingAmazonS3{}),
+		), usecase.WithDeadLetter(location))
+		gt.Error(t, uc.Route(context.Background(), input))
+
+		gt.M(t, gcsMock.writes).Length(1)
+		for name, buf := range gcsMock.writes {
+			gt.True(t, strings.HasPrefix(name, "nydus-dlq-bucket/dead-letter/"))
+			gt.True(t, strings.HasSuffix(name, ".json"))
+			verify(t, buf.Bytes())
+		}
+	})
+
+	t.Run("partial dead letter is not committed", func(t *testing.T) {
+		location := gt.R1(model.ParseDeadLetterLocation("s3://ap-northeast-1/nydus-dlq-bucket/dead-letter")).NoError(t)
+
+		s3Mock := &abortingAmazonS3{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(&mockGoogleCloudStorage{}),
+			adapter.WithAmazonS3(s3Mock),
+		), usecase.WithDeadLetter(location))
+		gt.Error(t, uc.Route(context.Background(), input))
+
+		// Both of the transfer and the dead letter are aborted
+		gt.Equal(t, s3Mock.aborted, []bool{true, true})
+	})
+}
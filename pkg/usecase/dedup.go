@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/interfaces"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// WithDedupStore skips a transfer that has been completed for the same version of source object and destination. A transfer whose source version is unknown is always performed.
+func WithDedupStore(store interfaces.DedupStore) Option {
+	return func(uc *UseCase) {
+		uc.dedup = store
+	}
+}
+
+// WithDedupMarker records completed transfers as marker objects under prefix in the bucket or container of each destination. Markers older than ttl are ignored, or never expire if ttl is 0.
+func WithDedupMarker(prefix string, ttl time.Duration) Option {
+	return func(uc *UseCase) {
+		uc.dedup = &markerStore{clients: uc.clients, prefix: prefix, ttl: ttl}
+	}
+}
+
+// skipDuplicates marks transfers that have been completed as skipped and returns the rest. If dedup store fails, the transfer is performed because duplicated copy is better than lost copy.
+func (x *UseCase) skipDuplicates(ctx context.Context, job *model.Job, transfers []*model.JobTransfer) []*model.JobTransfer {
+	if x.dedup == nil {
+		return transfers
+	}
+
+	logger := logging.From(ctx).With("job_id", job.ID)
+
+	var rest []*model.JobTransfer
+	for _, t := range transfers {
+		key := model.NewTransferKey(job.Input, t.Destination)
+		if key == nil {
+			rest = append(rest, t)
+			continue
+		}
+
+		found, err := x.dedup.HasTransfer(ctx, key)
+		if err != nil {
+			logger.Warn("Failed to look up dedup store, transfer anyway", "error", err, "key", key)
+		}
+		if !found {
+			rest = append(rest, t)
+			continue
+		}
+
+		logger.Info("Skip transfer that has been completed", "key", key)
+		t.State = model.JobSkipped
+	}
+
+	if len(rest) < len(transfers) {
+		job.UpdateState()
+		x.saveJob(ctx, job)
+	}
+
+	return rest
+}
+
+// isDedupMarker returns true if the source object of input is a marker object of marker dedup store. A marker written into the destination bucket notifies a new event, and it must not be routed.
+func (x *UseCase) isDedupMarker(input *model.RouteInput) bool {
+	store, ok := x.dedup.(*markerStore)
+	return ok && store.isMarker(input)
+}
+
+// recordTransfer records the completed transfer to dedup store. Failure is logged because the transfer itself has been done.
+func (x *UseCase) recordTransfer(ctx context.Context, input *model.RouteInput, dst model.Destination) {
+	if x.dedup == nil {
+		return
+	}
+
+	key := model.NewTransferKey(input, dst)
+	if key == nil {
+		return
+	}
+
+	if err := x.dedup.PutTransfer(ctx, key); err != nil {
+		logging.From(ctx).Warn("Failed to record transfer to dedup store", "error", err, "key", key)
+	}
+}
+
+// markerStore is a dedup store that writes a marker object for each completed transfer into the destination storage. It does not need additional storage, and markers are shared by all nydus instances writing to the destination.
+type markerStore struct {
+	clients *adapter.Clients
+	prefix  string
+	ttl     time.Duration
+}
+
+// markerRecord is content of a marker object
+type markerRecord struct {
+	Key         *model.TransferKey `json:"key"`
+	CompletedAt time.Time          `json:"completed_at"`
+}
+
+// isMarker returns true if the source object of input is under prefix of marker objects in its bucket or container
+func (x *markerStore) isMarker(input *model.RouteInput) bool {
+	src := input.Source()
+	if src == nil {
+		return false
+	}
+	return strings.HasPrefix(src.String(), src.Root().Join(x.prefix).String()+"/")
+}
+
+func (x *markerStore) marker(key *model.TransferKey) (model.Destination, error) {
+	dst, err := model.ParseDestination(key.Destination)
+	if err != nil {
+		return model.Destination{}, err
+	}
+	return dst.Root().Join(path.Join(x.prefix, key.ID()+".json")), nil
+}
+
+func (x *markerStore) HasTransfer(ctx context.Context, key *model.TransferKey) (bool, error) {
+	marker, err := x.marker(key)
+	if err != nil {
+		return false, err
+	}
+
+	stat, err := statDestination(ctx, x.clients, marker)
+	if errors.Is(err, model.ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, goerr.Wrap(err, "failed to look up marker object").With("marker", marker)
+	}
+
+	// Storage that does not provide update time never expires markers
+	return x.ttl <= 0 || stat.UpdatedAt.IsZero() || time.Since(stat.UpdatedAt) <= x.ttl, nil
+}
+
+func (x *markerStore) PutTransfer(ctx context.Context, key *model.TransferKey) error {
+	marker, err := x.marker(key)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(&markerRecord{Key: key, CompletedAt: time.Now().UTC()})
+	if err != nil {
+		return goerr.Wrap(err, "failed to marshal marker").With("key", key)
+	}
+
+	if err := writeObject(ctx, x.clients, marker, &model.ObjectAttrs{ContentType: "application/json"}, raw); err != nil {
+		return goerr.Wrap(err, "failed to write marker object").With("key", key)
+	}
+
+	return nil
+}
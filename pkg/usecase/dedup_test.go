@@ -0,0 +1,191 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/adapter/boltdb"
+	"github.com/secmon-lab/nydus/pkg/adapter/memory"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+func TestDedup(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	newInput := func(name string, generation int64) *model.RouteInput {
+		return &model.RouteInput{
+			GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+				Object: model.GoogleCloudStorageObject{
+					Bucket:     "nydus-src-bucket",
+					Name:       name,
+					Generation: generation,
+				},
+			},
+		}
+	}
+
+	fileStore := gt.R1(boltdb.New(filepath.Join(t.TempDir(), "dedup.db"))).NoError(t)
+	t.Cleanup(func() { gt.NoError(t, fileStore.Close()) })
+
+	testCases := map[string]usecase.Option{
+		"memory": usecase.WithDedupStore(memory.NewDedupStore(100, time.Hour)),
+		"file":   usecase.WithDedupStore(fileStore),
+		"marker": usecase.WithDedupMarker(".nydus/transfers", time.Hour),
+	}
+
+	for name, option := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			mock := &mockGoogleCloudStorage{}
+			store := newJobStore(t)
+			uc := usecase.New(adapter.New(
+				adapter.WithPolicy(policy),
+				adapter.WithGoogleCloudStorage(mock),
+				adapter.WithJobStore(store),
+			), option)
+
+			// Duplicated event is skipped
+			gt.NoError(t, uc.Route(ctx, newInput("blue.txt", 1)))
+			gt.NoError(t, uc.Route(ctx, newInput("blue.txt", 1)))
+			gt.A(t, mock.reads).Length(1)
+
+			// New generation is transferred
+			gt.NoError(t, uc.Route(ctx, newInput("blue.txt", 2)))
+			gt.A(t, mock.reads).Length(2)
+
+			// Unknown version is always transferred
+			gt.NoError(t, uc.Route(ctx, newInput("orange.txt", 0)))
+			gt.NoError(t, uc.Route(ctx, newInput("orange.txt", 0)))
+			gt.A(t, mock.reads).Length(4)
+
+			jobs := gt.R1(store.ListJobs(ctx)).NoError(t)
+			gt.A(t, jobs).Length(5).At(1, func(t testing.TB, job *model.Job) {
+				gt.Equal(t, job.State, model.JobSucceeded)
+				gt.Equal(t, job.Transfers[0].State, model.JobSkipped)
+				gt.Equal(t, job.Transfers[0].Attempts, 0)
+			})
+
+			if name == "marker" {
+				var markers int
+				for key := range mock.writes {
+					if strings.HasPrefix(key, "nydus-dst-bucket/.nydus/transfers/") {
+						markers++
+					}
+				}
+				gt.Equal(t, markers, 2)
+
+				// Event of a marker object itself is not routed
+				gt.NoError(t, uc.Route(ctx, newInput(".nydus/transfers/0123456789abcdef.json", 1)))
+				gt.A(t, mock.reads).Length(4)
+			}
+		})
+	}
+
+	t.Run("marker is looked up by exact key", func(t *testing.T) {
+		ctx := context.Background()
+		input := newInput("blue.txt", 1)
+		dst := model.Destination{GoogleCloudStorage: &model.GoogleCloudStorageObject{Bucket: "nydus-dst-bucket", Name: "blue.txt"}}
+		key := model.NewTransferKey(input, dst)
+
+		// An object whose key has the marker key as prefix is not a marker of the transfer
+		mock := &mockGoogleCloudStorage{writes: map[string]*bytes.Buffer{
+			"nydus-dst-bucket/.nydus/transfers/" + key.ID() + ".json.bak": bytes.NewBufferString("{}"),
+		}}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(mock),
+		), usecase.WithDedupMarker(".nydus/transfers", time.Hour))
+
+		gt.NoError(t, uc.Route(ctx, input))
+		gt.A(t, mock.reads).Length(1)
+		gt.True(t, mock.writes["nydus-dst-bucket/.nydus/transfers/"+key.ID()+".json"] != nil)
+	})
+
+	t.Run("failed transfer is not recorded", func(t *testing.T) {
+		policy := gt.R1(opac.New(opac.Data(map[string]string{
+			"route.rego": `package route
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+`,
+		}))).NoError(t)
+
+		ctx := context.Background()
+		mock := &mockGoogleCloudStorage{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(mock),
+			adapter.WithAmazonS3(&failingAmazonS3{}),
+		), usecase.WithDedupStore(memory.NewDedupStore(100, time.Hour)))
+
+		gt.Error(t, uc.Route(ctx, newInput("blue.txt", 1)))
+		gt.Error(t, uc.Route(ctx, newInput("blue.txt", 1)))
+		gt.A(t, mock.reads).Length(2)
+	})
+}
+
+func TestNewTransferKey(t *testing.T) {
+	dst := model.Destination{GoogleCloudStorage: &model.GoogleCloudStorageObject{Bucket: "nydus-dst-bucket", Name: "blue.txt"}}
+	newInput := func(etag string) *model.RouteInput {
+		return &model.RouteInput{AmazonS3: &model.AmazonS3Event{Object: model.AmazonS3Object{
+			Region: "ap-northeast-1",
+			Bucket: "nydus-src-bucket",
+			Key:    "blue.txt",
+			ETag:   etag,
+		}}}
+	}
+
+	// ETag is quoted in listing but not in event notification
+	fromEvent := model.NewTransferKey(newInput("0a755dbb29f923fb842fa43421a1ae75"), dst)
+	fromList := model.NewTransferKey(newInput(`"0a755dbb29f923fb842fa43421a1ae75"`), dst)
+	gt.Equal(t, fromEvent.ID(), fromList.ID())
+}
+
+func TestMemoryDedupStore(t *testing.T) {
+	ctx := context.Background()
+	keyOf := func(name string) *model.TransferKey {
+		return &model.TransferKey{Source: "gs://src/" + name, Version: "1", Destination: "gs://dst/" + name}
+	}
+
+	t.Run("least recently used is evicted", func(t *testing.T) {
+		store := memory.NewDedupStore(2, 0)
+		gt.NoError(t, store.PutTransfer(ctx, keyOf("a")))
+		gt.NoError(t, store.PutTransfer(ctx, keyOf("b")))
+		gt.True(t, gt.R1(store.HasTransfer(ctx, keyOf("a"))).NoError(t))
+
+		gt.NoError(t, store.PutTransfer(ctx, keyOf("c")))
+		gt.True(t, gt.R1(store.HasTransfer(ctx, keyOf("a"))).NoError(t))
+		gt.False(t, gt.R1(store.HasTransfer(ctx, keyOf("b"))).NoError(t))
+		gt.True(t, gt.R1(store.HasTransfer(ctx, keyOf("c"))).NoError(t))
+	})
+
+	t.Run("expired record is ignored", func(t *testing.T) {
+		store := memory.NewDedupStore(2, time.Millisecond)
+		gt.NoError(t, store.PutTransfer(ctx, keyOf("a")))
+		time.Sleep(5 * time.Millisecond)
+		gt.False(t, gt.R1(store.HasTransfer(ctx, keyOf("a"))).NoError(t))
+	})
+}
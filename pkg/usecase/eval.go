@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// EvaluatePayload builds route inputs from the payload in the same way as the server and evaluates the route policy with them. Objects are not transferred. The payload is a route input, Azure CloudEvent, Google Pub/Sub push message or Amazon SNS message, and the format is detected by its fields.
+func (x *UseCase) EvaluatePayload(ctx context.Context, payload []byte) ([]*model.EvalResult, error) {
+	inputs, err := newRouteInputsFromPayload(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*model.EvalResult
+	for _, input := range inputs {
+		output, err := x.query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &model.EvalResult{Input: input, Output: output})
+	}
+
+	return results, nil
+}
+
+// newRouteInputsFromPayload detects the format of payload and converts it to route inputs. Events that the server ignores result in no route input.
+func newRouteInputsFromPayload(ctx context.Context, payload []byte) ([]*model.RouteInput, error) {
+	logger := logging.From(ctx)
+
+	// Field names are matched case-insensitively by encoding/json. Unique fields of each format are used to detect it.
+	var probe struct {
+		AzureBlobStorage   json.RawMessage `json:"abs"`
+		GoogleCloudStorage json.RawMessage `json:"gcs"`
+		AmazonS3           json.RawMessage `json:"s3"`
+		SpecVersion        string          `json:"specversion"`
+		TopicArn           string          `json:"TopicArn"`
+		Subscription       string          `json:"subscription"`
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return nil, goerr.Wrap(err, "failed to unmarshal payload")
+	}
+
+	switch {
+	case probe.AzureBlobStorage != nil || probe.GoogleCloudStorage != nil || probe.AmazonS3 != nil:
+		var input model.RouteInput
+		if err := json.Unmarshal(payload, &input); err != nil {
+			return nil, goerr.Wrap(err, "failed to unmarshal route input")
+		}
+		return []*model.RouteInput{&input}, nil
+
+	case probe.SpecVersion != "":
+		var ev model.CloudEventSchema
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			return nil, goerr.Wrap(err, "failed to unmarshal Azure CloudEvent")
+		}
+		if ev.Type != "Microsoft.Storage.BlobCreated" {
+			logger.Warn("Azure CloudEvent is ignored by server", "type", ev.Type)
+			return nil, nil
+		}
+		input, err := newAzureRouteInput(&ev)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.RouteInput{input}, nil
+
+	case probe.TopicArn != "":
+		var ev model.AmazonSNSEvent
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			return nil, goerr.Wrap(err, "failed to unmarshal Amazon SNS message")
+		}
+		if ev.Type != "Notification" {
+			logger.Warn("Amazon SNS message is not notification", "type", ev.Type)
+			return nil, nil
+		}
+		return newAmazonRouteInputs(ctx, &ev)
+
+	case probe.Subscription != "":
+		var ev model.GooglePubSubEvent
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			return nil, goerr.Wrap(err, "failed to unmarshal Google Pub/Sub message")
+		}
+		if eventType := ev.Message.Attributes["eventType"]; eventType != "OBJECT_FINALIZE" {
+			logger.Warn("Google Cloud Storage event is ignored by server", "eventType", eventType)
+			return nil, nil
+		}
+		input, err := newGoogleRouteInput(&ev)
+		if err != nil {
+			return nil, err
+		}
+		return []*model.RouteInput{input}, nil
+
+	default:
+		return nil, goerr.New("unknown payload format, it must be route input, Azure CloudEvent, Google Pub/Sub message or Amazon SNS message")
+	}
+}
@@ -0,0 +1,108 @@
+package usecase_test
+
+import (
+	"context"
+	_ "embed"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+//go:embed testdata/cloud_event.json
+var cloudEvent []byte
+
+func TestEvaluatePayload(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.gcs.object.name,
+	}
+}
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.s3.object.key,
+	}
+}
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.abs.object.blob_name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	testCases := map[string]struct {
+		payload []byte
+		want    []string
+		wantErr bool
+	}{
+		"route input": {
+			payload: []byte(`{"gcs":{"object":{"bucket":"nydus-src-bucket","name":"blue.txt"}}}`),
+			want:    []string{"gs://nydus-dst-bucket/blue.txt"},
+		},
+		"Azure CloudEvent": {
+			payload: cloudEvent,
+			want:    []string{"gs://nydus-dst-bucket/tenantId=1yyyyy-yyyy-yyyy-yyyyyyyyyyyy/y=2024/m=08/d=25/h=23/m=00/PT1H.json"},
+		},
+		"Google Pub/Sub message": {
+			payload: pubsubEvent,
+			want:    []string{"gs://nydus-dst-bucket/logs/2024/08/25/access.log"},
+		},
+		"Amazon SNS notification": {
+			payload: snsNotification,
+			want:    []string{"gs://nydus-dst-bucket/logs/2024/08/25/access log=1.txt"},
+		},
+		"Amazon SNS subscription is ignored": {
+			payload: snsSubscription,
+		},
+		"unknown format": {
+			payload: []byte(`{"color":"blue"}`),
+			wantErr: true,
+		},
+		"invalid JSON": {
+			payload: []byte(`{`),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Storage clients are not configured, then transfer must not be performed
+			uc := usecase.New(adapter.New(adapter.WithPolicy(policy)))
+
+			results, err := uc.EvaluatePayload(context.Background(), tc.payload)
+			if tc.wantErr {
+				gt.Error(t, err)
+				return
+			}
+			gt.NoError(t, err)
+
+			var got []string
+			for _, result := range results {
+				for _, dst := range result.Output.Destinations() {
+					got = append(got, dst.String())
+				}
+			}
+			gt.Equal(t, got, tc.want)
+		})
+	}
+
+	t.Run("ignored event type", func(t *testing.T) {
+		uc := usecase.New(adapter.New(adapter.WithPolicy(policy)))
+		results := gt.R1(uc.EvaluatePayload(context.Background(), []byte(`{
+			"message": {"attributes": {"eventType": "OBJECT_DELETE", "bucketId": "nydus-src-bucket", "objectId": "blue.txt"}},
+			"subscription": "projects/my-project/subscriptions/nydus-push"
+		}`))).NoError(t)
+		gt.A(t, results).Length(0)
+	})
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// sourceStat is attributes of the source object that are fetched once for all destinations
+type sourceStat struct {
+	input *model.RouteInput
+	stat  *model.ObjectStat
+	err   error
+	done  bool
+}
+
+func (x *sourceStat) get(ctx context.Context, clients *adapter.Clients) (*model.ObjectStat, error) {
+	if !x.done {
+		x.done = true
+		if src := x.input.Source(); src == nil {
+			x.err = goerr.New("route input has no source object")
+		} else {
+			x.stat, x.err = statDestination(ctx, clients, *src)
+		}
+	}
+	return x.stat, x.err
+}
+
+// checkExisting decides whether the transfer to dst is skipped by if_exists of dst. It returns error if the transfer must fail.
+func (x *UseCase) checkExisting(ctx context.Context, dst model.Destination, source *sourceStat) (bool, error) {
+	ifExists := dst.IfExists()
+	if err := ifExists.Validate(); err != nil {
+		return false, goerr.Wrap(err, "invalid destination").With("destination", dst)
+	}
+	if ifExists == model.IfExistsOverwrite {
+		return false, nil
+	}
+
+	stat, err := statDestination(ctx, x.clients, dst)
+	if errors.Is(err, model.ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, goerr.Wrap(err, "failed to get attributes of destination object").With("destination", dst)
+	}
+
+	switch ifExists {
+	case model.IfExistsSkip:
+		return true, nil
+
+	case model.IfExistsFail:
+		return false, goerr.New("destination object already exists").With("destination", dst)
+
+	case model.IfExistsSkipIfIdentical:
+		src, err := source.get(ctx, x.clients)
+		if err != nil {
+			// Content can not be compared, then the destination is overwritten
+			logging.From(ctx).Warn("Failed to get attributes of source object, overwrite destination", "error", err, "destination", dst)
+			return false, nil
+		}
+		return src.Identical(stat), nil
+	}
+
+	return false, nil
+}
+
+// statDestination returns attributes of an object specified in the same form as destination. The customer key of destination is used to read attributes of the encrypted object.
+func statDestination(ctx context.Context, clients *adapter.Clients, dst model.Destination) (*model.ObjectStat, error) {
+	switch {
+	case dst.GoogleCloudStorage != nil:
+		if clients.GoogleCloudStorage() == nil {
+			return nil, goerr.New("Google Cloud Storage is not enabled")
+		}
+		key, err := loadCustomerKey(dst.GoogleCloudStorage.CustomerKeyEnv)
+		if err != nil {
+			return nil, goerr.Wrap(err, "invalid destination").With("destination", dst)
+		}
+		return clients.GoogleCloudStorage().Stat(ctx, dst.GoogleCloudStorage.Bucket, dst.GoogleCloudStorage.Name, key)
+
+	case dst.AmazonS3 != nil:
+		if clients.AmazonS3() == nil {
+			return nil, goerr.New("Amazon S3 is not enabled")
+		}
+		key, err := loadCustomerKey(dst.AmazonS3.CustomerKeyEnv)
+		if err != nil {
+			return nil, goerr.Wrap(err, "invalid destination").With("destination", dst)
+		}
+		return clients.AmazonS3().Stat(ctx, dst.AmazonS3.Region, dst.AmazonS3.Bucket, dst.AmazonS3.Key, key)
+
+	case dst.AzureBlobStorage != nil:
+		if clients.AzureBlobStorage() == nil {
+			return nil, goerr.New("Azure Blob Storage is not enabled")
+		}
+		key, err := loadCustomerKey(dst.AzureBlobStorage.CustomerKeyEnv)
+		if err != nil {
+			return nil, goerr.Wrap(err, "invalid destination").With("destination", dst)
+		}
+		return clients.AzureBlobStorage().Stat(ctx, dst.AzureBlobStorage.StorageAccount, dst.AzureBlobStorage.Container, dst.AzureBlobStorage.BlobName, key)
+
+	default:
+		return nil, goerr.New("unsupported destination")
+	}
+}
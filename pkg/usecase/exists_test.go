@@ -0,0 +1,189 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+	"google.golang.org/api/googleapi"
+)
+
+// flakyStatGoogleCloudStorage fails getting attributes of objects until failures reach zero
+type flakyStatGoogleCloudStorage struct {
+	mockGoogleCloudStorage
+	err      error
+	failures int
+}
+
+func (x *flakyStatGoogleCloudStorage) Stat(ctx context.Context, bucketName, objectName string, customerKey []byte) (*model.ObjectStat, error) {
+	if x.failures > 0 {
+		x.failures--
+		return nil, goerr.Wrap(x.err, "fail to get object attributes")
+	}
+	return x.mockGoogleCloudStorage.Stat(ctx, bucketName, objectName, customerKey)
+}
+
+func TestRouteIfExists(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+destinations := {
+		"overwrite.txt": "overwrite",
+		"default.txt": "",
+		"skip.txt": "skip",
+		"identical.txt": "skip_if_identical",
+		"different.txt": "skip_if_identical",
+		"fail.txt": "fail",
+		"new.txt": "fail",
+}
+
+gcs[dst] {
+	if_exists := destinations[name]
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": name,
+		"if_exists": if_exists,
+	}
+}
+`,
+	}))).NoError(t)
+
+	mock := &mockGoogleCloudStorage{writes: map[string]*bytes.Buffer{
+		"nydus-src-bucket/blue.txt":      bytes.NewBufferString("timeless words"),
+		"nydus-dst-bucket/overwrite.txt": bytes.NewBufferString("old words"),
+		"nydus-dst-bucket/default.txt":   bytes.NewBufferString("old words"),
+		"nydus-dst-bucket/skip.txt":      bytes.NewBufferString("old words"),
+		"nydus-dst-bucket/identical.txt": bytes.NewBufferString("timeless words"),
+		"nydus-dst-bucket/different.txt": bytes.NewBufferString("timeless world"),
+		"nydus-dst-bucket/fail.txt":      bytes.NewBufferString("old words"),
+	}}
+	identical := mock.writes["nydus-dst-bucket/identical.txt"]
+
+	store := newJobStore(t)
+	uc := usecase.New(adapter.New(
+		adapter.WithPolicy(policy),
+		adapter.WithGoogleCloudStorage(mock),
+		adapter.WithJobStore(store),
+	))
+
+	ctx := context.Background()
+	err := uc.Route(ctx, &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Object: model.GoogleCloudStorageObject{
+				Bucket: "nydus-src-bucket",
+				Name:   "blue.txt",
+			},
+		},
+	})
+	gt.Error(t, err)
+
+	gt.Equal(t, mock.writes["nydus-dst-bucket/overwrite.txt"].String(), "timeless words")
+	gt.Equal(t, mock.writes["nydus-dst-bucket/default.txt"].String(), "timeless words")
+	gt.Equal(t, mock.writes["nydus-dst-bucket/skip.txt"].String(), "old words")
+	gt.True(t, mock.writes["nydus-dst-bucket/identical.txt"] == identical)
+	gt.Equal(t, mock.writes["nydus-dst-bucket/different.txt"].String(), "timeless words")
+	gt.Equal(t, mock.writes["nydus-dst-bucket/fail.txt"].String(), "old words")
+	gt.Equal(t, mock.writes["nydus-dst-bucket/new.txt"].String(), "timeless words")
+
+	jobs := gt.R1(store.ListJobs(ctx)).NoError(t)
+	gt.A(t, jobs).Length(1).At(0, func(t testing.TB, job *model.Job) {
+		gt.Equal(t, job.State, model.JobFailed)
+
+		states := map[string]model.JobState{}
+		for _, transfer := range job.Transfers {
+			states[transfer.Destination.GoogleCloudStorage.Name] = transfer.State
+		}
+		gt.Equal(t, states, map[string]model.JobState{
+			"overwrite.txt": model.JobSucceeded,
+			"default.txt":   model.JobSucceeded,
+			"skip.txt":      model.JobSkipped,
+			"identical.txt": model.JobSkipped,
+			"different.txt": model.JobSucceeded,
+			"fail.txt":      model.JobFailed,
+			"new.txt":       model.JobSucceeded,
+		})
+	})
+
+	t.Run("failure of checking existing object is retried", func(t *testing.T) {
+		retryPolicy := usecase.WithRetryPolicy(&model.RetryPolicy{
+			MaxAttempts: 3,
+			BaseBackoff: time.Millisecond,
+			MaxBackoff:  time.Millisecond,
+		})
+
+		testCases := map[string]struct {
+			err          error
+			failures     int
+			name         string
+			wantErr      bool
+			wantAttempts int
+		}{
+			"transient error": {
+				err:          &googleapi.Error{Code: http.StatusServiceUnavailable},
+				failures:     2,
+				name:         "new.txt",
+				wantAttempts: 3,
+			},
+			"permanent error": {
+				err:          &googleapi.Error{Code: http.StatusForbidden},
+				failures:     1,
+				name:         "new.txt",
+				wantErr:      true,
+				wantAttempts: 1,
+			},
+			"conflict of if_exists fail": {
+				name:         "fail.txt",
+				wantErr:      true,
+				wantAttempts: 1,
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				mock := &flakyStatGoogleCloudStorage{err: tc.err, failures: tc.failures}
+				mock.writes = map[string]*bytes.Buffer{
+					"nydus-dst-bucket/fail.txt": bytes.NewBufferString("old words"),
+				}
+				store := newJobStore(t)
+				uc := usecase.New(adapter.New(
+					adapter.WithGoogleCloudStorage(mock),
+					adapter.WithJobStore(store),
+				), retryPolicy)
+
+				err := uc.Copy(ctx, model.Destination{
+					GoogleCloudStorage: &model.GoogleCloudStorageObject{Bucket: "nydus-src-bucket", Name: "blue.txt"},
+				}, []model.Destination{
+					{GoogleCloudStorage: &model.GoogleCloudStorageObject{Bucket: "nydus-dst-bucket", Name: tc.name, IfExists: "fail"}},
+				})
+				if tc.wantErr {
+					gt.Error(t, err)
+				} else {
+					gt.NoError(t, err)
+					gt.Equal(t, mock.writes["nydus-dst-bucket/"+tc.name].String(), "timeless words")
+				}
+
+				jobs := gt.R1(store.ListJobs(ctx)).NoError(t)
+				gt.A(t, jobs).Length(1).At(0, func(t testing.TB, job *model.Job) {
+					gt.Equal(t, job.Transfers[0].Attempts, tc.wantAttempts)
+				})
+			})
+		}
+	})
+
+	t.Run("invalid if_exists", func(t *testing.T) {
+		uc := usecase.New(adapter.New(adapter.WithGoogleCloudStorage(&mockGoogleCloudStorage{})))
+		gt.Error(t, uc.Copy(ctx, model.Destination{
+			GoogleCloudStorage: &model.GoogleCloudStorageObject{Bucket: "nydus-src-bucket", Name: "blue.txt"},
+		}, []model.Destination{
+			{GoogleCloudStorage: &model.GoogleCloudStorageObject{Bucket: "nydus-dst-bucket", Name: "blue.txt", IfExists: "ignore"}},
+		}))
+	})
+}
@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+func (x *UseCase) HandleGooglePubSubEvent(ctx context.Context, ev *model.GooglePubSubEvent) error {
+	logger := logging.From(ctx)
+	logger.Debug("Handle Google Pub/Sub event", "event", ev)
+
+	input, err := newGoogleRouteInput(ev)
+	if err != nil {
+		return err
+	}
+
+	if err := x.Route(ctx, input); err != nil {
+		return goerr.Wrap(err, "failed to emit route").With("input", input)
+	}
+
+	return nil
+}
+
+// newGoogleRouteInput builds route input from Pub/Sub message of Google Cloud Storage notification
+func newGoogleRouteInput(ev *model.GooglePubSubEvent) (*model.RouteInput, error) {
+	obj, err := newGoogleCloudStorageObject(ev)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Event:  *ev,
+			Object: *obj,
+		},
+	}, nil
+}
+
+func newGoogleCloudStorageObject(ev *model.GooglePubSubEvent) (*model.GoogleCloudStorageObject, error) {
+	attrs := ev.Message.Attributes
+
+	// If payload format is "NONE", the message has no data and only attributes are available.
+	if attrs["payloadFormat"] == "NONE" || len(ev.Message.Data) == 0 {
+		obj := &model.GoogleCloudStorageObject{
+			Bucket: attrs["bucketId"],
+			Name:   attrs["objectId"],
+		}
+		if v := attrs["objectGeneration"]; v != "" {
+			gen, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, goerr.Wrap(err, "invalid objectGeneration in Pub/Sub attributes").With("attributes", attrs)
+			}
+			obj.Generation = gen
+		}
+
+		if obj.Bucket == "" || obj.Name == "" {
+			return nil, goerr.New("Invalid Google Pub/Sub message").With("attributes", attrs)
+		}
+		return obj, nil
+	}
+
+	var notification model.GoogleCloudStorageNotification
+	if err := json.Unmarshal(ev.Message.Data, &notification); err != nil {
+		return nil, goerr.Wrap(err, "failed to unmarshal Google Cloud Storage notification").With("data", string(ev.Message.Data))
+	}
+	if notification.Bucket == "" || notification.Name == "" {
+		return nil, goerr.New("Invalid Google Cloud Storage notification").With("notification", notification)
+	}
+
+	obj := &model.GoogleCloudStorageObject{
+		Bucket:      notification.Bucket,
+		Name:        notification.Name,
+		ContentType: notification.ContentType,
+		MD5Hash:     notification.MD5Hash,
+		CRC32C:      notification.CRC32C,
+		Metadata:    notification.Metadata,
+	}
+
+	if notification.Generation != "" {
+		gen, err := strconv.ParseInt(notification.Generation, 10, 64)
+		if err != nil {
+			return nil, goerr.Wrap(err, "invalid generation in Google Cloud Storage notification").With("notification", notification)
+		}
+		obj.Generation = gen
+	}
+
+	if notification.Size != "" {
+		size, err := strconv.ParseInt(notification.Size, 10, 64)
+		if err != nil {
+			return nil, goerr.Wrap(err, "invalid size in Google Cloud Storage notification").With("notification", notification)
+		}
+		obj.Size = size
+	}
+
+	return obj, nil
+}
+
+type googleIDTokenClaims struct {
+	jwt.RegisteredClaims
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// VerifyGooglePubSubToken verifies Google-signed OIDC token attached to Pub/Sub push request. It returns nil without verification if Pub/Sub authentication is not configured. It returns error wrapping model.ErrUnauthenticated if token is missing or invalid, and model.ErrForbidden if audience or email is not allowed.
+func (x *UseCase) VerifyGooglePubSubToken(ctx context.Context, token string) error {
+	auth := x.googlePubSubAuth
+	if auth == nil {
+		return nil
+	}
+
+	if token == "" {
+		return goerr.Wrap(model.ErrUnauthenticated, "no OIDC token")
+	}
+
+	var claims googleIDTokenClaims
+	keyFunc := func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return x.googleJWKS.get(ctx, x.clients.HTTPClient(), auth.JWKSURL, kid)
+	}
+
+	if _, err := jwt.ParseWithClaims(token, &claims, keyFunc,
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	); err != nil {
+		return goerr.Wrap(model.ErrUnauthenticated, "invalid OIDC token").With("err", err.Error())
+	}
+
+	if !slices.Contains(auth.Issuers, claims.Issuer) {
+		return goerr.Wrap(model.ErrUnauthenticated, "issuer is not allowed").With("iss", claims.Issuer)
+	}
+
+	if !slices.ContainsFunc(claims.Audience, func(aud string) bool {
+		return slices.Contains(auth.Audiences, aud)
+	}) {
+		return goerr.Wrap(model.ErrForbidden, "audience is not allowed").With("aud", claims.Audience)
+	}
+
+	if !claims.EmailVerified || !slices.Contains(auth.Emails, claims.Email) {
+		return goerr.Wrap(model.ErrForbidden, "email is not allowed").With("email", claims.Email).With("email_verified", claims.EmailVerified)
+	}
+
+	logging.From(ctx).Debug("Verified OIDC token of Pub/Sub push request", "email", claims.Email, "aud", claims.Audience)
+
+	return nil
+}
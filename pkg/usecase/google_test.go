@@ -0,0 +1,420 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"maps"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/m-mizutani/goerr"
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+//go:embed testdata/pubsub_event.json
+var pubsubEvent []byte
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+type mockGoogleCloudStorage struct {
+	mutex  sync.Mutex
+	data   []byte
+	reads  []string
+	writes map[string]*bytes.Buffer
+	attrs  map[string]*model.ObjectAttrs
+	opts   map[string]*model.GoogleCloudStorageWriteOptions
+	// stats is attributes of objects that are not written by the mock
+	stats map[string]*model.ObjectStat
+	// generations is the latest generation of objects. Reading other generation fails.
+	generations map[string]int64
+}
+
+func (x *mockGoogleCloudStorage) NewReader(ctx context.Context, bucketName, objectName string, generation int64) (io.ReadCloser, error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.reads = append(x.reads, bucketName+"/"+objectName)
+	if latest, ok := x.generations[bucketName+"/"+objectName]; ok && generation != 0 && generation != latest {
+		return nil, goerr.Wrap(model.ErrSourceChanged, "generation of object no longer exists").With("generation", generation)
+	}
+	if buf, ok := x.writes[bucketName+"/"+objectName]; ok {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	}
+	if x.data != nil {
+		return io.NopCloser(bytes.NewReader(x.data)), nil
+	}
+	return io.NopCloser(bytes.NewReader([]byte("timeless words"))), nil
+}
+
+func (x *mockGoogleCloudStorage) NewWriter(ctx context.Context, bucketName, objectName string, attrs *model.ObjectAttrs, opts *model.GoogleCloudStorageWriteOptions) (io.WriteCloser, error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	if x.writes == nil {
+		x.writes = map[string]*bytes.Buffer{}
+	}
+	if x.attrs == nil {
+		x.attrs = map[string]*model.ObjectAttrs{}
+		x.opts = map[string]*model.GoogleCloudStorageWriteOptions{}
+	}
+	buf := &bytes.Buffer{}
+	x.writes[bucketName+"/"+objectName] = buf
+	x.attrs[bucketName+"/"+objectName] = attrs
+	x.opts[bucketName+"/"+objectName] = opts
+	return nopWriteCloser{buf}, nil
+}
+
+func (x *mockGoogleCloudStorage) List(ctx context.Context, bucketName, prefix, startAfter string, fn func(*model.ObjectInfo) error) error {
+	x.mutex.Lock()
+	writes := maps.Clone(x.writes)
+	x.mutex.Unlock()
+
+	return listWrites(writes, bucketName+"/", prefix, startAfter, fn)
+}
+
+func (x *mockGoogleCloudStorage) Stat(ctx context.Context, bucketName, objectName string, customerKey []byte) (*model.ObjectStat, error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	if stat, ok := x.stats[bucketName+"/"+objectName]; ok {
+		return stat, nil
+	}
+	return statWrites(x.writes, bucketName+"/"+objectName)
+}
+
+// statWrites returns attributes of a written object. MD5 and CRC32C are calculated from the content.
+func statWrites(writes map[string]*bytes.Buffer, key string) (*model.ObjectStat, error) {
+	buf, ok := writes[key]
+	if !ok {
+		return nil, goerr.Wrap(model.ErrObjectNotFound, "object not found").With("key", key)
+	}
+
+	md5sum := md5.Sum(buf.Bytes())
+	crc := crc32.Checksum(buf.Bytes(), crc32.MakeTable(crc32.Castagnoli))
+	return &model.ObjectStat{
+		Size:   int64(buf.Len()),
+		MD5:    md5sum[:],
+		CRC32C: &crc,
+	}, nil
+}
+
+// listWrites lists written objects under root after startAfter in lexical order
+func listWrites(writes map[string]*bytes.Buffer, root, prefix, startAfter string, fn func(*model.ObjectInfo) error) error {
+	var names []string
+	for key := range writes {
+		if name, ok := strings.CutPrefix(key, root); ok && strings.HasPrefix(name, prefix) && name > startAfter {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if err := fn(&model.ObjectInfo{Name: name, Size: int64(writes[root+name].Len())}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestGooglePubSubEvent(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	input.gcs.object.generation == 1724627533123456
+	input.gcs.object.size == 14
+	input.gcs.object.content_type == "text/plain"
+	input.gcs.object.metadata.owner == "blue"
+	input.gcs.event.message.attributes.eventType == "OBJECT_FINALIZE"
+
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": sprintf("%s/%s", [input.gcs.object.bucket, input.gcs.object.name]),
+	}
+}
+`,
+	}))).NoError(t)
+
+	mock := &mockGoogleCloudStorage{}
+	uc := usecase.New(adapter.New(
+		adapter.WithPolicy(policy),
+		adapter.WithGoogleCloudStorage(mock),
+	))
+
+	var ev model.GooglePubSubEvent
+	gt.NoError(t, json.Unmarshal(pubsubEvent, &ev))
+
+	ctx := context.Background()
+	gt.NoError(t, uc.HandleGooglePubSubEvent(ctx, &ev))
+
+	gt.A(t, mock.reads).Length(1).At(0, func(t testing.TB, v string) {
+		gt.Equal(t, v, "nydus-src-bucket/logs/2024/08/25/access.log")
+	})
+	gt.M(t, mock.writes).Length(1)
+	gt.Equal(t, mock.writes["nydus-dst-bucket/nydus-src-bucket/logs/2024/08/25/access.log"].String(), "timeless words")
+}
+
+func TestGooglePubSubEventWithoutPayload(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	mock := &mockGoogleCloudStorage{}
+	uc := usecase.New(adapter.New(
+		adapter.WithPolicy(policy),
+		adapter.WithGoogleCloudStorage(mock),
+	))
+
+	ev := model.GooglePubSubEvent{
+		Message: model.GooglePubSubMessage{
+			Attributes: map[string]string{
+				"bucketId":         "nydus-src-bucket",
+				"objectId":         "blue.txt",
+				"objectGeneration": "1",
+				"eventType":        "OBJECT_FINALIZE",
+				"payloadFormat":    "NONE",
+			},
+		},
+	}
+
+	ctx := context.Background()
+	gt.NoError(t, uc.HandleGooglePubSubEvent(ctx, &ev))
+	gt.A(t, mock.reads).Length(1).At(0, func(t testing.TB, v string) {
+		gt.Equal(t, v, "nydus-src-bucket/blue.txt")
+	})
+	gt.Equal(t, mock.writes["nydus-dst-bucket/blue.txt"].String(), "timeless words")
+}
+
+func newJWKS(t *testing.T, kid string, key *rsa.PrivateKey) []byte {
+	jwks := map[string]any{
+		"keys": []map[string]string{
+			{
+				"kid": kid,
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			},
+		},
+	}
+	return gt.R1(json.Marshal(jwks)).NoError(t)
+}
+
+func TestVerifyGooglePubSubToken(t *testing.T) {
+	const kid = "test-key"
+	key := gt.R1(rsa.GenerateKey(rand.Reader, 2048)).NoError(t)
+	otherKey := gt.R1(rsa.GenerateKey(rand.Reader, 2048)).NoError(t)
+	jwks := newJWKS(t, kid, key)
+
+	auth := &model.GooglePubSubAuth{
+		JWKSURL:   "https://www.googleapis.com/oauth2/v3/certs",
+		Issuers:   []string{"https://accounts.google.com"},
+		Audiences: []string{"https://nydus.example.com/google/pubsub/cloud-storage"},
+		Emails:    []string{"pubsub@my-project.iam.gserviceaccount.com"},
+	}
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            "https://accounts.google.com",
+			"aud":            "https://nydus.example.com/google/pubsub/cloud-storage",
+			"email":          "pubsub@my-project.iam.gserviceaccount.com",
+			"email_verified": true,
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	sign := func(claims jwt.MapClaims, key *rsa.PrivateKey) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		return gt.R1(token.SignedString(key)).NoError(t)
+	}
+
+	testCases := map[string]struct {
+		token   func() string
+		wantErr error
+	}{
+		"valid token": {
+			token: func() string { return sign(validClaims(), key) },
+		},
+		"no token": {
+			token:   func() string { return "" },
+			wantErr: model.ErrUnauthenticated,
+		},
+		"signed by other key": {
+			token:   func() string { return sign(validClaims(), otherKey) },
+			wantErr: model.ErrUnauthenticated,
+		},
+		"expired": {
+			token: func() string {
+				claims := validClaims()
+				claims["exp"] = time.Now().Add(-time.Minute).Unix()
+				return sign(claims, key)
+			},
+			wantErr: model.ErrUnauthenticated,
+		},
+		"other issuer": {
+			token: func() string {
+				claims := validClaims()
+				claims["iss"] = "https://example.com"
+				return sign(claims, key)
+			},
+			wantErr: model.ErrUnauthenticated,
+		},
+		"other audience": {
+			token: func() string {
+				claims := validClaims()
+				claims["aud"] = "https://example.com"
+				return sign(claims, key)
+			},
+			wantErr: model.ErrForbidden,
+		},
+		"other email": {
+			token: func() string {
+				claims := validClaims()
+				claims["email"] = "attacker@example.com"
+				return sign(claims, key)
+			},
+			wantErr: model.ErrForbidden,
+		},
+		"unverified email": {
+			token: func() string {
+				claims := validClaims()
+				claims["email_verified"] = false
+				return sign(claims, key)
+			},
+			wantErr: model.ErrForbidden,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			mock := &certHTTPClient{cert: jwks}
+			uc := usecase.New(adapter.New(adapter.WithHTTPClient(mock)), usecase.WithGooglePubSubAuth(auth))
+
+			err := uc.VerifyGooglePubSubToken(context.Background(), tc.token())
+			if tc.wantErr != nil {
+				gt.True(t, errors.Is(err, tc.wantErr))
+			} else {
+				gt.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("JWKS is cached", func(t *testing.T) {
+		mock := &certHTTPClient{cert: jwks}
+		uc := usecase.New(adapter.New(adapter.WithHTTPClient(mock)), usecase.WithGooglePubSubAuth(auth))
+
+		for i := 0; i < 3; i++ {
+			gt.NoError(t, uc.VerifyGooglePubSubToken(context.Background(), sign(validClaims(), key)))
+		}
+		gt.A(t, mock.requests).Length(1)
+	})
+
+	t.Run("failed JWKS fetch is not retried within min refresh interval", func(t *testing.T) {
+		mock := &certHTTPClient{cert: []byte("broken")}
+		uc := usecase.New(adapter.New(adapter.WithHTTPClient(mock)), usecase.WithGooglePubSubAuth(auth))
+
+		for i := 0; i < 3; i++ {
+			err := uc.VerifyGooglePubSubToken(context.Background(), sign(validClaims(), key))
+			gt.True(t, errors.Is(err, model.ErrUnauthenticated))
+		}
+		gt.A(t, mock.requests).Length(1)
+	})
+
+	t.Run("JWKS is fetched once by concurrent requests", func(t *testing.T) {
+		mock := &blockingHTTPClient{
+			body:    jwks,
+			started: make(chan struct{}, 1),
+			release: make(chan struct{}),
+		}
+		uc := usecase.New(adapter.New(adapter.WithHTTPClient(mock)), usecase.WithGooglePubSubAuth(auth))
+
+		var wg sync.WaitGroup
+		errs := make([]error, 5)
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = uc.VerifyGooglePubSubToken(context.Background(), sign(validClaims(), key))
+			}()
+		}
+
+		<-mock.started
+		close(mock.release)
+		wg.Wait()
+
+		for _, err := range errs {
+			gt.NoError(t, err)
+		}
+		gt.Equal(t, mock.count(), 1)
+	})
+
+	t.Run("no verification without configuration", func(t *testing.T) {
+		mock := &certHTTPClient{cert: jwks}
+		uc := usecase.New(adapter.New(adapter.WithHTTPClient(mock)))
+
+		gt.NoError(t, uc.VerifyGooglePubSubToken(context.Background(), ""))
+		gt.A(t, mock.requests).Length(0)
+	})
+}
+
+type blockingHTTPClient struct {
+	body    []byte
+	started chan struct{}
+	release chan struct{}
+
+	mutex    sync.Mutex
+	requests int
+}
+
+func (x *blockingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	x.mutex.Lock()
+	x.requests++
+	x.mutex.Unlock()
+
+	select {
+	case x.started <- struct{}{}:
+	default:
+	}
+	<-x.release
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader(x.body)),
+	}, nil
+}
+
+func (x *blockingHTTPClient) count() int {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	return x.requests
+}
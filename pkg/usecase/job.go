@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// ResumeJobs resumes transfer jobs that were not finished before the last shutdown. Only unfinished destinations of the jobs are transferred again. Resumed jobs are performed by workers if asynchronous transfer is enabled, otherwise one by one in background. Close waits until all resumed jobs are finished.
+func (x *UseCase) ResumeJobs(ctx context.Context) error {
+	store := x.clients.JobStore()
+	if store == nil {
+		return nil
+	}
+
+	jobs, err := store.ListJobs(ctx, model.JobPending, model.JobRunning)
+	if err != nil {
+		return goerr.Wrap(err, "failed to list unfinished jobs")
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	logger := logging.From(ctx)
+	logger.Info("Resume unfinished transfer jobs", "count", len(jobs))
+
+	// Same as enqueue, resumed jobs must not be canceled by the caller but by shutdown
+	jobCtx := logging.Inject(x.jobCtx, logger)
+
+	x.resuming.Add(1)
+	go func() {
+		defer x.resuming.Done()
+
+		for _, job := range jobs {
+			// Jobs not resumed before shutdown are left in job store
+			if jobCtx.Err() != nil {
+				return
+			}
+
+			if x.queue != nil {
+				// Blocking send, because the workers are already consuming the queue
+				select {
+				case x.queue.jobs <- &transferJob{ctx: jobCtx, job: job}:
+				case <-jobCtx.Done():
+					return
+				}
+				continue
+			}
+
+			if err := x.execute(jobCtx, job); err != nil {
+				logger.Error("Failed to transfer object in resumed job", "error", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// PurgeJobs deletes finished jobs that were updated before retention period from job store
+func (x *UseCase) PurgeJobs(ctx context.Context, retention time.Duration) error {
+	store := x.clients.JobStore()
+	if store == nil {
+		return nil
+	}
+
+	before := time.Now().Add(-retention)
+	n, err := store.DeleteJobs(ctx, before)
+	if err != nil {
+		return goerr.Wrap(err, "failed to purge jobs").With("retention", retention)
+	}
+
+	logging.From(ctx).Info("Purged finished transfer jobs", "count", n, "before", before)
+	return nil
+}
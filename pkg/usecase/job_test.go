@@ -0,0 +1,153 @@
+package usecase_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/adapter/boltdb"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+func newJobStore(t *testing.T) *boltdb.Client {
+	store := gt.R1(boltdb.New(filepath.Join(t.TempDir(), "jobs.db"))).NoError(t)
+	t.Cleanup(func() { gt.NoError(t, store.Close()) })
+	return store
+}
+
+func TestJobStore(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.gcs.object.name,
+	}
+}
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	store := newJobStore(t)
+	gcsMock := &mockGoogleCloudStorage{}
+	uc := usecase.New(adapter.New(
+		adapter.WithPolicy(policy),
+		adapter.WithGoogleCloudStorage(gcsMock),
+		adapter.WithAmazonS3(&failingAmazonS3{}),
+		adapter.WithJobStore(store),
+	))
+
+	ctx := context.Background()
+	input := &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Object: model.GoogleCloudStorageObject{
+				Bucket: "nydus-src-bucket",
+				Name:   "blue.txt",
+			},
+		},
+	}
+	gt.Error(t, uc.Route(ctx, input))
+
+	jobs := gt.R1(store.ListJobs(ctx)).NoError(t)
+	gt.A(t, jobs).Length(1).At(0, func(t testing.TB, job *model.Job) {
+		gt.Equal(t, job.State, model.JobFailed)
+		gt.Equal(t, job.Input.GoogleCloudStorage.Object.Name, "blue.txt")
+		gt.A(t, job.Transfers).Length(2)
+
+		gt.True(t, job.Transfers[0].Destination.GoogleCloudStorage != nil)
+		gt.Equal(t, job.Transfers[0].State, model.JobSucceeded)
+		gt.Equal(t, job.Transfers[0].Attempts, 1)
+		gt.Equal(t, job.Transfers[0].Bytes, 14)
+
+		gt.True(t, job.Transfers[1].Destination.AmazonS3 != nil)
+		gt.Equal(t, job.Transfers[1].State, model.JobFailed)
+		gt.Equal(t, job.Transfers[1].Attempts, 1)
+		gt.True(t, job.Transfers[1].LastError != "")
+	})
+
+	gt.A(t, gt.R1(store.ListJobs(ctx, model.JobPending, model.JobRunning)).NoError(t)).Length(0)
+
+	// Finished jobs are purged after retention period
+	gt.NoError(t, uc.PurgeJobs(ctx, time.Hour))
+	gt.A(t, gt.R1(store.ListJobs(ctx)).NoError(t)).Length(1)
+	gt.NoError(t, uc.PurgeJobs(ctx, -time.Hour))
+	gt.A(t, gt.R1(store.ListJobs(ctx)).NoError(t)).Length(0)
+}
+
+func TestResumeJobs(t *testing.T) {
+	ctx := context.Background()
+
+	input := &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Object: model.GoogleCloudStorageObject{
+				Bucket: "nydus-src-bucket",
+				Name:   "blue.txt",
+			},
+		},
+	}
+
+	// A job interrupted by shutdown. Only the running destination must be transferred again.
+	interrupted := model.NewJob(input, []model.Destination{
+		{GoogleCloudStorage: &model.GoogleCloudStorageObject{Bucket: "dst-a", Name: "blue.txt"}},
+		{GoogleCloudStorage: &model.GoogleCloudStorageObject{Bucket: "dst-b", Name: "blue.txt"}},
+	})
+	interrupted.Transfers[0].State = model.JobSucceeded
+	interrupted.Transfers[0].Attempts = 1
+	interrupted.Transfers[1].State = model.JobRunning
+	interrupted.Transfers[1].Attempts = 1
+	interrupted.UpdateState()
+
+	// A job that has not been started
+	pending := model.NewJob(input, []model.Destination{
+		{GoogleCloudStorage: &model.GoogleCloudStorageObject{Bucket: "dst-c", Name: "blue.txt"}},
+	})
+
+	for _, tc := range []struct {
+		name    string
+		options []usecase.Option
+	}{
+		{name: "sync"},
+		{name: "async", options: []usecase.Option{usecase.WithTransferQueue(1, 1)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newJobStore(t)
+			gt.NoError(t, store.PutJob(ctx, interrupted))
+			gt.NoError(t, store.PutJob(ctx, pending))
+
+			mock := &mockGoogleCloudStorage{}
+			uc := usecase.New(adapter.New(
+				adapter.WithGoogleCloudStorage(mock),
+				adapter.WithJobStore(store),
+			), tc.options...)
+
+			gt.NoError(t, uc.ResumeJobs(ctx))
+			uc.Shutdown(ctx)
+
+			gt.A(t, mock.reads).Length(2)
+			gt.M(t, mock.writes).Length(2)
+			gt.Equal(t, mock.writes["dst-b/blue.txt"].String(), "timeless words")
+			gt.Equal(t, mock.writes["dst-c/blue.txt"].String(), "timeless words")
+
+			job := gt.R1(store.GetJob(ctx, interrupted.ID)).NoError(t)
+			gt.Equal(t, job.State, model.JobSucceeded)
+			gt.Equal(t, job.Transfers[0].Attempts, 1)
+			gt.Equal(t, job.Transfers[1].Attempts, 2)
+
+			job = gt.R1(store.GetJob(ctx, pending.ID)).NoError(t)
+			gt.Equal(t, job.State, model.JobSucceeded)
+		})
+	}
+}
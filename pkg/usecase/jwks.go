@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/interfaces"
+)
+
+// jwksRefreshInterval is the interval to refresh cached JWKS. JWKS is also refreshed when unknown key ID is found after the interval of jwksMinRefreshInterval. A failed fetch is not retried within jwksMinRefreshInterval either.
+const (
+	jwksRefreshInterval    = time.Hour
+	jwksMinRefreshInterval = time.Minute
+)
+
+type jwksCache struct {
+	mutex     sync.Mutex
+	url       string
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+
+	// failedURL, failedAt and failure are URL, time and error of the last failed fetch. They are cleared by a successful fetch.
+	failedURL string
+	failedAt  time.Time
+	failure   error
+
+	// fetching is closed when the in-flight fetch of JWKS finishes. It is nil if no fetch is in flight.
+	fetching chan struct{}
+}
+
+func newJWKSCache() *jwksCache {
+	return &jwksCache{}
+}
+
+func (x *jwksCache) get(ctx context.Context, client interfaces.HTTPClient, jwksURL, kid string) (*rsa.PublicKey, error) {
+	// JWKS is fetched without holding the mutex so that a slow JWKS endpoint does not block verification by cached keys. Only one fetch is in flight at a time and other callers wait for it.
+	for {
+		x.mutex.Lock()
+		elapsed := time.Since(x.fetchedAt)
+		key, ok := x.keys[kid]
+		if x.url == jwksURL && ok && elapsed < jwksRefreshInterval {
+			x.mutex.Unlock()
+			return key, nil
+		}
+		if x.url == jwksURL && !ok && elapsed < jwksMinRefreshInterval {
+			x.mutex.Unlock()
+			return nil, goerr.New("unknown key ID of OIDC token").With("kid", kid)
+		}
+		// Requests must not hit the JWKS endpoint one after another while it is down
+		if x.failedURL == jwksURL && x.failure != nil && time.Since(x.failedAt) < jwksMinRefreshInterval {
+			err, failedAt := x.failure, x.failedAt
+			x.mutex.Unlock()
+			return nil, goerr.Wrap(err, "JWKS fetch failed recently").With("failedAt", failedAt)
+		}
+
+		fetching := x.fetching
+		if fetching == nil {
+			x.fetching = make(chan struct{})
+			x.mutex.Unlock()
+			break
+		}
+		x.mutex.Unlock()
+
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, goerr.Wrap(ctx.Err(), "canceled while waiting for JWKS").With("jwksURL", jwksURL)
+		}
+	}
+
+	keys, err := fetchJWKS(ctx, client, jwksURL)
+
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	close(x.fetching)
+	x.fetching = nil
+
+	if err != nil {
+		// Cancellation of the caller is not a failure of the endpoint
+		if ctx.Err() == nil {
+			x.failedURL = jwksURL
+			x.failedAt = time.Now()
+			x.failure = err
+		}
+		return nil, err
+	}
+	x.url = jwksURL
+	x.keys = keys
+	x.fetchedAt = time.Now()
+	x.failedURL = ""
+	x.failure = nil
+
+	if key, ok := x.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, goerr.New("unknown key ID of OIDC token").With("kid", kid)
+}
+
+func fetchJWKS(ctx context.Context, client interfaces.HTTPClient, jwksURL string) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURL, nil)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to create HTTP request").With("jwksURL", jwksURL)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to send HTTP request").With("jwksURL", jwksURL)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to read JWKS").With("jwksURL", jwksURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, goerr.New("JWKS response is not OK").With("statusCode", resp.StatusCode).With("body", string(body)).With("jwksURL", jwksURL)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		return nil, goerr.Wrap(err, "failed to unmarshal JWKS").With("jwksURL", jwksURL)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, goerr.Wrap(err, "invalid modulus in JWKS").With("kid", k.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, goerr.Wrap(err, "invalid exponent in JWKS").With("kid", k.Kid)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
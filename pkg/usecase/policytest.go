@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/open-policy-agent/opa/tester"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// policyFixture is a test case of route policy. Input is a payload accepted by EvaluatePayload, and Output is expected route output.
+type policyFixture struct {
+	Input  json.RawMessage   `json:"input"`
+	Output model.RouteOutput `json:"output"`
+}
+
+// TestPolicyFixtures evaluates the route policy with each fixture file (*.json) in the directory and compares route output with the expected one. If a payload has multiple route inputs, their outputs are merged. Order of destinations is ignored.
+func (x *UseCase) TestPolicyFixtures(ctx context.Context, dir string) ([]*model.PolicyTestResult, error) {
+	var results []*model.PolicyTestResult
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			name = path
+		}
+		result := &model.PolicyTestResult{Name: name}
+		results = append(results, result)
+
+		raw, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			result.Err = goerr.Wrap(err, "failed to read fixture").With("path", path)
+			return nil
+		}
+
+		var fixture policyFixture
+		if err := json.Unmarshal(raw, &fixture); err != nil {
+			result.Err = goerr.Wrap(err, "failed to unmarshal fixture").With("path", path)
+			return nil
+		}
+		if len(fixture.Input) == 0 {
+			result.Err = goerr.New("fixture has no input").With("path", path)
+			return nil
+		}
+
+		evals, err := x.EvaluatePayload(ctx, fixture.Input)
+		if err != nil {
+			result.Err = err
+			return nil
+		}
+
+		var actual model.RouteOutput
+		for _, eval := range evals {
+			actual.AzureBlobStorage = append(actual.AzureBlobStorage, eval.Output.AzureBlobStorage...)
+			actual.GoogleCloudStorage = append(actual.GoogleCloudStorage, eval.Output.GoogleCloudStorage...)
+			actual.AmazonS3Storage = append(actual.AmazonS3Storage, eval.Output.AmazonS3Storage...)
+		}
+
+		result.Diff, err = diffRouteOutput(&fixture.Output, &actual)
+		if err != nil {
+			result.Err = err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to read fixture directory").With("dir", dir)
+	}
+
+	return results, nil
+}
+
+// diffRouteOutput returns destinations that are only in expected with "-" prefix, and that are only in actual with "+" prefix. Destinations are compared as JSON, so fields omitted in expected must be zero value in actual.
+func diffRouteOutput(expected, actual *model.RouteOutput) ([]string, error) {
+	want, err := routeOutputEntries(expected)
+	if err != nil {
+		return nil, err
+	}
+	got, err := routeOutputEntries(actual)
+	if err != nil {
+		return nil, err
+	}
+
+	var diff []string
+	for _, entry := range want {
+		if i := slices.Index(got, entry); i >= 0 {
+			got = slices.Delete(got, i, i+1)
+		} else {
+			diff = append(diff, "- "+entry)
+		}
+	}
+	for _, entry := range got {
+		diff = append(diff, "+ "+entry)
+	}
+
+	return diff, nil
+}
+
+// routeOutputEntries returns destinations in route output as sorted strings such as `gcs {"bucket":"x",...}`
+func routeOutputEntries(output *model.RouteOutput) ([]string, error) {
+	var entries []string
+	add := func(kind string, obj any) error {
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return goerr.Wrap(err, "failed to marshal destination").With("destination", obj)
+		}
+		entries = append(entries, kind+" "+string(raw))
+		return nil
+	}
+
+	for _, obj := range output.AzureBlobStorage {
+		if err := add("abs", obj); err != nil {
+			return nil, err
+		}
+	}
+	for _, obj := range output.GoogleCloudStorage {
+		if err := add("gcs", obj); err != nil {
+			return nil, err
+		}
+	}
+	for _, obj := range output.AmazonS3Storage {
+		if err := add("s3", obj); err != nil {
+			return nil, err
+		}
+	}
+
+	slices.Sort(entries)
+	return entries, nil
+}
+
+// TestRegoPolicy runs native Rego test rules (test_ prefix) in policy files (*.rego) of the paths, in the same way as "opa test"
+func TestRegoPolicy(ctx context.Context, paths ...string) ([]*model.PolicyTestResult, error) {
+	// Only Rego files are loaded as well as route policy. JSON files such as fixtures must not be loaded as data.
+	filter := func(abspath string, info fs.FileInfo, depth int) bool {
+		return !info.IsDir() && !strings.HasSuffix(info.Name(), ".rego")
+	}
+
+	modules, store, err := tester.Load(paths, filter)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to load policy files").With("paths", paths)
+	}
+
+	ch, err := tester.NewRunner().SetStore(store).CapturePrintOutput(true).Run(ctx, modules)
+	if err != nil {
+		return nil, goerr.Wrap(err, "failed to run Rego tests").With("paths", paths)
+	}
+
+	var results []*model.PolicyTestResult
+	for r := range ch {
+		result := &model.PolicyTestResult{
+			Name:    r.Package + "." + r.Name,
+			Skipped: r.Skip,
+		}
+		switch {
+		case r.Error != nil:
+			result.Err = goerr.Wrap(r.Error, "Rego test error").With("location", r.Location.String())
+		case r.Fail:
+			err := goerr.New("Rego test failed").With("location", r.Location.String())
+			if r.FailedAt != nil {
+				err = err.With("failed_at", r.FailedAt.String())
+			}
+			if len(r.Output) > 0 {
+				err = err.With("output", string(r.Output))
+			}
+			result.Err = err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
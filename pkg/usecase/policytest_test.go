@@ -0,0 +1,109 @@
+package usecase_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		gt.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		gt.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+}
+
+func TestPolicyTest(t *testing.T) {
+	policyDir := t.TempDir()
+	writeFiles(t, policyDir, map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	input.gcs.object.bucket == "nydus-src-bucket"
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.gcs.object.name,
+	}
+}
+`,
+		"route_test.rego": `package route
+
+test_routed {
+	count(gcs) == 1 with input as {"gcs": {"object": {"bucket": "nydus-src-bucket", "name": "blue.txt"}}}
+}
+
+test_broken {
+	count(gcs) == 2 with input as {"gcs": {"object": {"bucket": "nydus-src-bucket", "name": "blue.txt"}}}
+}
+
+todo_test_later {
+	false
+}
+`,
+		// JSON files in policy directory must not be loaded as data
+		"fixtures/ignored.json": `{"input": {}}`,
+	})
+
+	fixtureDir := t.TempDir()
+	writeFiles(t, fixtureDir, map[string]string{
+		"routed.json": `{
+			"input": {"gcs": {"object": {"bucket": "nydus-src-bucket", "name": "blue.txt"}}},
+			"output": {"gcs": [{"bucket": "nydus-dst-bucket", "name": "blue.txt"}]}
+		}`,
+		"pubsub/not_routed.json": `{
+			"input": {
+				"message": {"attributes": {"eventType": "OBJECT_FINALIZE", "payloadFormat": "NONE", "bucketId": "other-bucket", "objectId": "blue.txt"}},
+				"subscription": "projects/my-project/subscriptions/nydus-push"
+			},
+			"output": {}
+		}`,
+		"wrong.json": `{
+			"input": {"gcs": {"object": {"bucket": "nydus-src-bucket", "name": "blue.txt"}}},
+			"output": {"s3": [{"region": "ap-northeast-1", "bucket": "nydus-dst-bucket", "key": "blue.txt"}]}
+		}`,
+		"broken.json": `{"output": {}}`,
+		"README.md":   "not a fixture",
+	})
+
+	ctx := context.Background()
+
+	t.Run("fixtures", func(t *testing.T) {
+		policy := gt.R1(opac.New(opac.Files(policyDir))).NoError(t)
+		uc := usecase.New(adapter.New(adapter.WithPolicy(policy)))
+
+		results := gt.R1(uc.TestPolicyFixtures(ctx, fixtureDir)).NoError(t)
+		report := &model.PolicyTestReport{Results: results}
+		passed, failed, skipped := report.Count()
+		gt.Equal(t, passed, 2)
+		gt.Equal(t, failed, 2)
+		gt.Equal(t, skipped, 0)
+
+		for _, result := range results {
+			switch result.Name {
+			case "wrong.json":
+				gt.A(t, result.Diff).Length(2)
+				gt.S(t, result.Diff[0]).HasPrefix("- s3 ")
+				gt.S(t, result.Diff[1]).HasPrefix("+ gcs ")
+			case "broken.json":
+				gt.Error(t, result.Err)
+			}
+		}
+	})
+
+	t.Run("Rego tests", func(t *testing.T) {
+		results := gt.R1(usecase.TestRegoPolicy(ctx, policyDir)).NoError(t)
+		report := &model.PolicyTestReport{Results: results}
+		passed, failed, skipped := report.Count()
+		gt.Equal(t, passed, 1)
+		gt.Equal(t, failed, 1)
+		gt.Equal(t, skipped, 1)
+	})
+}
@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+type transferJob struct {
+	ctx context.Context
+	job *model.Job
+}
+
+// transferQueue is a bounded queue of transfer jobs processed by a pool of workers
+type transferQueue struct {
+	workers int
+	jobs    chan *transferJob
+	wg      sync.WaitGroup
+
+	// ctx is context of queued jobs. It is canceled by Shutdown of the usecase.
+	ctx context.Context
+
+	// mutex guards jobs from being closed while a job is sent
+	mutex     sync.Mutex
+	closed    bool
+	closeJobs sync.Once
+}
+
+// errShutdown is the cause of canceling jobs that run beyond requests. Transfers interrupted by it are left pending in job store to be resumed.
+var errShutdown = goerr.New("transfer job is canceled by shutdown")
+
+// isShutdown returns true if ctx is canceled by shutdown
+func isShutdown(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errShutdown)
+}
+
+// WithTransferQueue enables asynchronous transfer. Route enqueues a transfer job and returns immediately, and the workers perform the transfers. Route returns model.ErrQueueFull if the queue has no room or is already closed.
+func WithTransferQueue(workers, size int) Option {
+	return func(uc *UseCase) {
+		uc.queue = &transferQueue{
+			workers: workers,
+			jobs:    make(chan *transferJob, size),
+		}
+	}
+}
+
+func (x *transferQueue) start(uc *UseCase) {
+	x.ctx = uc.jobCtx
+	for i := 0; i < x.workers; i++ {
+		x.wg.Add(1)
+		go func() {
+			defer x.wg.Done()
+			for job := range x.jobs {
+				// Jobs left in the queue at shutdown are resumed from job store
+				if job.ctx.Err() != nil {
+					continue
+				}
+				if err := uc.execute(job.ctx, job.job); err != nil {
+					logging.From(job.ctx).Error("Failed to transfer object in worker", "error", err)
+				}
+			}
+		}()
+	}
+}
+
+func (x *transferQueue) enqueue(ctx context.Context, job *model.Job) error {
+	// The job must not be canceled when the request is finished. Only logger is taken over from the request context.
+	tj := &transferJob{
+		ctx: logging.Inject(x.ctx, logging.From(ctx)),
+		job: job,
+	}
+
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	if x.closed {
+		return goerr.Wrap(model.ErrQueueFull, "transfer queue is closed").With("job_id", job.ID)
+	}
+
+	select {
+	case x.jobs <- tj:
+		logging.From(ctx).Info("Enqueued transfer job", "job_id", job.ID, "destinations", len(job.Transfers), "queued", len(x.jobs))
+		return nil
+	default:
+		return goerr.Wrap(model.ErrQueueFull, "failed to enqueue transfer job").With("job_id", job.ID).With("size", cap(x.jobs))
+	}
+}
+
+// Async returns true if transfer is performed asynchronously by workers
+func (x *UseCase) Async() bool {
+	return x.queue != nil
+}
+
+// reject stops accepting new jobs. Jobs in the queue are still processed by workers.
+func (x *transferQueue) reject() {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	x.closed = true
+}
+
+// close rejects new jobs and stops workers after they process queued jobs. Resumed jobs must not be sent after close.
+func (x *transferQueue) close() {
+	x.reject()
+	x.closeJobs.Do(func() { close(x.jobs) })
+}
+
+// Shutdown stops accepting new transfer jobs and waits until queued and resumed jobs are finished or ctx is done. Then jobs still running are canceled and their unfinished transfers are left pending in job store, so that they are resumed by ResumeJobs of the next process. Route called after Shutdown returns model.ErrQueueFull.
+func (x *UseCase) Shutdown(ctx context.Context) {
+	if x.queue != nil {
+		x.queue.reject()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Resumed jobs are sent to the queue until resuming is finished
+		x.resuming.Wait()
+		if x.queue != nil {
+			x.queue.close()
+			x.queue.wg.Wait()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logging.From(ctx).Warn("Cancel unfinished transfer jobs by shutdown")
+		x.cancelJobs(errShutdown)
+		<-done
+	}
+	x.cancelJobs(errShutdown)
+}
+
+// Close cancels queued and resumed jobs without waiting for them to be finished, and waits until they stop. See Shutdown.
+func (x *UseCase) Close() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	x.Shutdown(ctx)
+}
@@ -0,0 +1,135 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+// blockingGoogleCloudStorage blocks NewReader until release is closed or ctx is canceled
+type blockingGoogleCloudStorage struct {
+	mockGoogleCloudStorage
+	started chan struct{}
+	release chan struct{}
+}
+
+func (x *blockingGoogleCloudStorage) NewReader(ctx context.Context, bucketName, objectName string, generation int64) (io.ReadCloser, error) {
+	x.started <- struct{}{}
+	select {
+	case <-x.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return x.mockGoogleCloudStorage.NewReader(ctx, bucketName, objectName, generation)
+}
+
+func TestTransferQueue(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	newInput := func(name string) *model.RouteInput {
+		return &model.RouteInput{
+			GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+				Object: model.GoogleCloudStorageObject{
+					Bucket: "nydus-src-bucket",
+					Name:   name,
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+
+	t.Run("queued jobs are finished by shutdown", func(t *testing.T) {
+		mock := &blockingGoogleCloudStorage{
+			started: make(chan struct{}, 3),
+			release: make(chan struct{}),
+		}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(mock),
+		), usecase.WithTransferQueue(1, 1))
+		gt.True(t, uc.Async())
+
+		// First job is taken by the worker and blocked
+		gt.NoError(t, uc.Route(ctx, newInput("a.txt")))
+		<-mock.started
+
+		// Second job is queued
+		gt.NoError(t, uc.Route(ctx, newInput("b.txt")))
+
+		// Third job is rejected because the queue is full
+		err := uc.Route(ctx, newInput("c.txt"))
+		gt.True(t, errors.Is(err, model.ErrQueueFull))
+
+		close(mock.release)
+		uc.Shutdown(ctx)
+
+		gt.A(t, mock.reads).Length(2)
+		gt.Equal(t, mock.writes["nydus-dst-bucket/a.txt"].String(), "timeless words")
+		gt.Equal(t, mock.writes["nydus-dst-bucket/b.txt"].String(), "timeless words")
+
+		// Late request after Shutdown is rejected instead of sending to the closed queue
+		err = uc.Route(ctx, newInput("d.txt"))
+		gt.True(t, errors.Is(err, model.ErrQueueFull))
+	})
+
+	t.Run("running jobs are canceled and left to job store by shutdown", func(t *testing.T) {
+		store := newJobStore(t)
+		mock := &blockingGoogleCloudStorage{
+			started: make(chan struct{}, 3),
+			release: make(chan struct{}),
+		}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(mock),
+			adapter.WithJobStore(store),
+		), usecase.WithTransferQueue(1, 1))
+
+		gt.NoError(t, uc.Route(ctx, newInput("a.txt")))
+		<-mock.started
+		gt.NoError(t, uc.Route(ctx, newInput("b.txt")))
+
+		// Shutdown with expired grace period cancels the blocked job without waiting for release
+		expired, cancel := context.WithCancel(ctx)
+		cancel()
+		uc.Shutdown(expired)
+
+		gt.Equal(t, len(mock.writes), 0)
+		jobs := gt.R1(store.ListJobs(ctx)).NoError(t)
+		gt.A(t, jobs).Length(2)
+		for _, job := range jobs {
+			gt.Equal(t, job.State, model.JobPending)
+			gt.Equal(t, job.Transfers[0].State, model.JobPending)
+		}
+
+		// Interrupted jobs are resumed by the next process
+		next := &mockGoogleCloudStorage{}
+		uc = usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(next),
+			adapter.WithJobStore(store),
+		), usecase.WithTransferQueue(1, 1))
+		gt.NoError(t, uc.ResumeJobs(ctx))
+		uc.Shutdown(ctx)
+
+		gt.Equal(t, next.writes["nydus-dst-bucket/a.txt"].String(), "timeless words")
+		gt.Equal(t, next.writes["nydus-dst-bucket/b.txt"].String(), "timeless words")
+	})
+}
@@ -0,0 +1,299 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+type replayRecord struct {
+	seq    int
+	source string
+	input  *model.RouteInput
+	err    error
+}
+
+// Replay reads stored route inputs from sources, evaluates them with the current policy and transfers the objects. A source is a file, a directory that has JSON files, or an object storage prefix such as "gs://bucket/dead-letter". A document can be a route input, a dead letter or a job. If dryRun is true, only the policy is evaluated.
+func (x *UseCase) Replay(ctx context.Context, sources []string, dryRun bool, concurrency int) (*model.ReplayReport, error) {
+	if concurrency < 1 {
+		return nil, goerr.New("concurrency must be 1 or more").With("concurrency", concurrency)
+	}
+
+	report := &model.ReplayReport{DryRun: dryRun}
+	var seqs []int
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	records := make(chan *replayRecord)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for record := range records {
+				result := x.replay(ctx, record, dryRun)
+
+				mutex.Lock()
+				report.Results = append(report.Results, result)
+				seqs = append(seqs, record.seq)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	var err error
+	var seq int
+	emit := func(record *replayRecord) {
+		record.seq = seq
+		seq++
+		records <- record
+	}
+	for _, source := range sources {
+		if err = x.readReplaySource(ctx, source, emit); err != nil {
+			break
+		}
+	}
+	close(records)
+	wg.Wait()
+
+	// Sort results in order of reading
+	results := make([]*model.ReplayResult, len(report.Results))
+	for i, result := range report.Results {
+		results[seqs[i]] = result
+	}
+	report.Results = results
+
+	return report, err
+}
+
+func (x *UseCase) replay(ctx context.Context, record *replayRecord, dryRun bool) *model.ReplayResult {
+	logger := logging.From(ctx).With("source", record.source)
+	result := &model.ReplayResult{Source: record.source}
+
+	if record.err != nil {
+		logger.Warn("Failed to read route input", "error", record.err)
+		result.Err = record.err
+		return result
+	}
+
+	dsts, err := x.Evaluate(ctx, record.input)
+	if err != nil {
+		logger.Warn("Failed to evaluate route input", "error", err)
+		result.Err = err
+		return result
+	}
+	result.Destinations = dsts
+
+	if dryRun || len(dsts) == 0 {
+		logger.Info("Evaluated route input", "destinations", dsts, "dry_run", dryRun)
+		return result
+	}
+
+	if err := x.dispatch(ctx, record.input, dsts); err != nil {
+		logger.Warn("Failed to replay route input", "error", err)
+		result.Err = err
+	}
+	return result
+}
+
+// readReplaySource reads route inputs from the source and calls emit for each route input. It returns error only if the source can not be listed.
+func (x *UseCase) readReplaySource(ctx context.Context, source string, emit func(*replayRecord)) error {
+	location, err := model.ParseDeadLetterLocation(source)
+	if err != nil {
+		return err
+	}
+
+	if location.Prefix != nil {
+		return listObjects(ctx, x.clients, *location.Prefix, "", func(dst model.Destination, _ *model.ObjectInfo) error {
+			if !strings.HasSuffix(dst.String(), ".json") {
+				return nil
+			}
+
+			r, err := newReaderFromDestination(ctx, x.clients, dst)
+			if err != nil {
+				emit(&replayRecord{source: dst.String(), err: err})
+				return nil
+			}
+			defer r.Close()
+
+			emitDocuments(dst.String(), r, emit)
+			return nil
+		})
+	}
+
+	info, err := os.Stat(location.Dir)
+	if err != nil {
+		return goerr.Wrap(err, "failed to open replay source").With("source", source)
+	}
+
+	var paths []string
+	if info.IsDir() {
+		if err := filepath.WalkDir(location.Dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && filepath.Ext(path) == ".json" {
+				paths = append(paths, path)
+			}
+			return nil
+		}); err != nil {
+			return goerr.Wrap(err, "failed to walk replay source").With("source", source)
+		}
+	} else {
+		paths = append(paths, location.Dir)
+	}
+
+	for _, path := range paths {
+		f, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			emit(&replayRecord{source: path, err: goerr.Wrap(err, "failed to open file").With("path", path)})
+			continue
+		}
+		emitDocuments(path, f, emit)
+		_ = f.Close()
+	}
+
+	return nil
+}
+
+// emitDocuments decodes JSON documents in r. A file may have multiple documents, such as JSON lines of jobs.
+func emitDocuments(source string, r io.Reader, emit func(*replayRecord)) {
+	inputs, err := decodeRouteInputs(r)
+	if err != nil {
+		emit(&replayRecord{source: source, err: goerr.Wrap(err, "failed to decode route input").With("source", source)})
+		return
+	}
+
+	for i, input := range inputs {
+		name := source
+		if len(inputs) > 1 {
+			name = fmt.Sprintf("%s#%d", source, i+1)
+		}
+		emit(&replayRecord{source: name, input: input})
+	}
+}
+
+// decodeRouteInputs decodes route inputs from route input, dead letter or job documents
+func decodeRouteInputs(r io.Reader) ([]*model.RouteInput, error) {
+	var inputs []*model.RouteInput
+
+	decoder := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, goerr.Wrap(err, "invalid JSON document")
+		}
+
+		// Dead letter and job have route input in "input" field
+		var doc struct {
+			Input *model.RouteInput `json:"input"`
+		}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, goerr.Wrap(err, "invalid JSON document")
+		}
+
+		input := doc.Input
+		if input == nil {
+			input = &model.RouteInput{}
+			if err := json.Unmarshal(raw, input); err != nil {
+				return nil, goerr.Wrap(err, "invalid route input")
+			}
+		}
+
+		if input.AzureBlobStorage == nil && input.GoogleCloudStorage == nil && input.AmazonS3 == nil {
+			return nil, goerr.New("no route input in document").With("document", string(raw))
+		}
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
+
+// listObjects calls fn for each object under the prefix after startAfter. Object name of prefix is used as prefix of name. Size and ETag of the object are set if available.
+func listObjects(ctx context.Context, clients *adapter.Clients, prefix model.Destination, startAfter string, fn func(model.Destination, *model.ObjectInfo) error) error {
+	switch {
+	case prefix.GoogleCloudStorage != nil:
+		if clients.GoogleCloudStorage() == nil {
+			return goerr.New("Google Cloud Storage is not enabled")
+		}
+		obj := prefix.GoogleCloudStorage
+		return clients.GoogleCloudStorage().List(ctx, obj.Bucket, obj.Name, startAfter, func(info *model.ObjectInfo) error {
+			return fn(model.Destination{GoogleCloudStorage: &model.GoogleCloudStorageObject{
+				Bucket: obj.Bucket,
+				Name:   info.Name,
+				Size:   info.Size,
+			}}, info)
+		})
+
+	case prefix.AmazonS3 != nil:
+		if clients.AmazonS3() == nil {
+			return goerr.New("Amazon S3 is not enabled")
+		}
+		obj := prefix.AmazonS3
+		return clients.AmazonS3().List(ctx, obj.Region, obj.Bucket, obj.Key, startAfter, func(info *model.ObjectInfo) error {
+			return fn(model.Destination{AmazonS3: &model.AmazonS3Object{
+				Region: obj.Region,
+				Bucket: obj.Bucket,
+				Key:    info.Name,
+				Size:   info.Size,
+				ETag:   info.ETag,
+			}}, info)
+		})
+
+	case prefix.AzureBlobStorage != nil:
+		if clients.AzureBlobStorage() == nil {
+			return goerr.New("Azure Blob Storage is not enabled")
+		}
+		obj := prefix.AzureBlobStorage
+		return clients.AzureBlobStorage().List(ctx, obj.StorageAccount, obj.Container, obj.BlobName, startAfter, func(info *model.ObjectInfo) error {
+			return fn(model.Destination{AzureBlobStorage: &model.AzureBlobStorageObject{
+				StorageAccount: obj.StorageAccount,
+				Container:      obj.Container,
+				BlobName:       info.Name,
+				Size:           info.Size,
+				ETag:           info.ETag,
+			}}, info)
+		})
+
+	default:
+		return goerr.New("unsupported storage")
+	}
+}
+
+// newReaderFromDestination opens an object specified in the same form as destination
+func newReaderFromDestination(ctx context.Context, clients *adapter.Clients, dst model.Destination) (io.ReadCloser, error) {
+	switch {
+	case dst.GoogleCloudStorage != nil:
+		if clients.GoogleCloudStorage() == nil {
+			return nil, goerr.New("Google Cloud Storage is not enabled")
+		}
+		return clients.GoogleCloudStorage().NewReader(ctx, dst.GoogleCloudStorage.Bucket, dst.GoogleCloudStorage.Name, 0)
+
+	case dst.AmazonS3 != nil:
+		if clients.AmazonS3() == nil {
+			return nil, goerr.New("Amazon S3 is not enabled")
+		}
+		return clients.AmazonS3().NewReader(ctx, dst.AmazonS3.Region, dst.AmazonS3.Bucket, dst.AmazonS3.Key, "", "")
+
+	case dst.AzureBlobStorage != nil:
+		if clients.AzureBlobStorage() == nil {
+			return nil, goerr.New("Azure Blob Storage is not enabled")
+		}
+		return clients.AzureBlobStorage().NewReader(ctx, dst.AzureBlobStorage.StorageAccount, dst.AzureBlobStorage.Container, dst.AzureBlobStorage.BlobName, "")
+
+	default:
+		return nil, goerr.New("unsupported storage")
+	}
+}
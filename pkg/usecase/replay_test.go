@@ -0,0 +1,110 @@
+package usecase_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+func TestReplay(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+s3[dst] {
+	input.gcs.object.name != "ignored.txt"
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	newInput := func(name string) *model.RouteInput {
+		return &model.RouteInput{
+			GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+				Object: model.GoogleCloudStorageObject{
+					Bucket: "nydus-src-bucket",
+					Name:   name,
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+
+	// Make dead letters in local directory and object storage by failed transfers
+	dir := t.TempDir()
+	dlqMock := &mockGoogleCloudStorage{}
+	for _, location := range []string{dir, "gs://nydus-dlq-bucket/dead-letter"} {
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(dlqMock),
+			adapter.WithAmazonS3(&failingAmazonS3{}),
+		), usecase.WithDeadLetter(gt.R1(model.ParseDeadLetterLocation(location)).NoError(t)))
+		gt.Error(t, uc.Route(ctx, newInput("blue.txt")))
+	}
+
+	// Route inputs in JSON lines, including one that has no destination
+	jsonl := filepath.Join(t.TempDir(), "inputs.jsonl")
+	gt.NoError(t, os.WriteFile(jsonl, []byte(`{"gcs":{"object":{"bucket":"nydus-src-bucket","name":"orange.txt"}}}
+{"gcs":{"object":{"bucket":"nydus-src-bucket","name":"ignored.txt"}}}
+`), 0600))
+
+	// Broken document
+	broken := filepath.Join(t.TempDir(), "broken.json")
+	gt.NoError(t, os.WriteFile(broken, []byte(`{"env":{}}`), 0600))
+
+	sources := []string{dir, "gs://nydus-dlq-bucket/dead-letter", jsonl, broken}
+
+	t.Run("dry run", func(t *testing.T) {
+		s3Mock := &mockAmazonS3{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(dlqMock),
+			adapter.WithAmazonS3(s3Mock),
+		))
+
+		report := gt.R1(uc.Replay(ctx, sources, true, 2)).NoError(t)
+		gt.A(t, report.Results).Length(5)
+		gt.Equal(t, report.Results[3].Source, jsonl+"#2")
+
+		succeeded, skipped, failed := report.Count()
+		gt.Equal(t, succeeded, 3)
+		gt.Equal(t, skipped, 1)
+		gt.Equal(t, failed, 1)
+		gt.M(t, s3Mock.writes).Length(0)
+	})
+
+	t.Run("transfer", func(t *testing.T) {
+		s3Mock := &mockAmazonS3{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(dlqMock),
+			adapter.WithAmazonS3(s3Mock),
+		))
+
+		report := gt.R1(uc.Replay(ctx, sources, false, 2)).NoError(t)
+		succeeded, skipped, failed := report.Count()
+		gt.Equal(t, succeeded, 3)
+		gt.Equal(t, skipped, 1)
+		gt.Equal(t, failed, 1)
+
+		gt.M(t, s3Mock.writes).Length(2)
+		gt.Equal(t, s3Mock.writes["ap-northeast-1/nydus-dst-bucket/blue.txt"].String(), "timeless words")
+		gt.Equal(t, s3Mock.writes["ap-northeast-1/nydus-dst-bucket/orange.txt"].String(), "timeless words")
+	})
+
+	t.Run("source not found", func(t *testing.T) {
+		uc := usecase.New(adapter.New(adapter.WithPolicy(policy)))
+		gt.R1(uc.Replay(ctx, []string{filepath.Join(dir, "not-found")}, true, 1)).Error(t)
+	})
+}
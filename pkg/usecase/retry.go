@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"google.golang.org/api/googleapi"
+)
+
+// WithRetryPolicy enables retry of transfer to a destination that failed with retryable error. Each retry reads the source object again. Transfer is not retried by default.
+func WithRetryPolicy(policy *model.RetryPolicy) Option {
+	return func(uc *UseCase) {
+		uc.retryPolicy = policy
+	}
+}
+
+func isRetryableStatusCode(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// isRetryableError returns true if the error is considered as transient. Errors of each provider SDK are classified by HTTP status code and error code. Other errors are not retried because they are caused by configuration or policy in most cases.
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// Content may be corrupted while reading the source. The source is read again by retry.
+	if errors.Is(err, model.ErrChecksumMismatch) {
+		return true
+	}
+	// The source object of the event has been overwritten, and reading it again returns the same result
+	if errors.Is(err, model.ErrSourceChanged) {
+		return false
+	}
+	// The object is already committed and may be immutable, then it must not be written again
+	if errors.Is(err, model.ErrImmutabilityNotSet) {
+		return false
+	}
+
+	// Google Cloud Storage
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		return isRetryableStatusCode(gErr.Code)
+	}
+
+	// Azure Blob Storage
+	var azErr *azcore.ResponseError
+	if errors.As(err, &azErr) {
+		return isRetryableStatusCode(azErr.StatusCode)
+	}
+
+	// Amazon S3
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.ErrorCode()
+		if _, ok := retry.DefaultThrottleErrorCodes[code]; ok {
+			return true
+		}
+		if _, ok := retry.DefaultRetryableErrorCodes[code]; ok {
+			return true
+		}
+	}
+	var awsErr *awshttp.ResponseError
+	if errors.As(err, &awsErr) {
+		return isRetryableStatusCode(awsErr.HTTPStatusCode())
+	}
+
+	// Network errors
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,194 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/m-mizutani/goerr"
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+	"google.golang.org/api/googleapi"
+)
+
+type errWriter struct{ err error }
+
+func (x errWriter) Write(p []byte) (int, error) { return 0, x.err }
+func (errWriter) Close() error                  { return nil }
+
+// flakyGoogleCloudStorage fails writing until failures reach zero
+type flakyGoogleCloudStorage struct {
+	mockGoogleCloudStorage
+	err      error
+	failures int
+}
+
+func (x *flakyGoogleCloudStorage) NewWriter(ctx context.Context, bucketName, objectName string, attrs *model.ObjectAttrs, opts *model.GoogleCloudStorageWriteOptions) (io.WriteCloser, error) {
+	if x.failures > 0 {
+		x.failures--
+		// Adapters wrap SDK errors with goerr
+		return errWriter{err: goerr.Wrap(x.err, "fail to write object")}, nil
+	}
+	return x.mockGoogleCloudStorage.NewWriter(ctx, bucketName, objectName, attrs, opts)
+}
+
+func TestRetry(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.gcs.object.name,
+	}
+}
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	awsErr := &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}},
+			Err:      errors.New("service unavailable"),
+		},
+	}
+
+	testCases := map[string]struct {
+		err       error
+		failures  int
+		wantErr   bool
+		wantReads int
+	}{
+		"Google API 503 is retried": {
+			err:       &googleapi.Error{Code: http.StatusServiceUnavailable},
+			failures:  2,
+			wantReads: 3,
+		},
+		"Azure 429 is retried": {
+			err:       &azcore.ResponseError{StatusCode: http.StatusTooManyRequests},
+			failures:  1,
+			wantReads: 2,
+		},
+		"AWS 503 is retried": {
+			err:       awsErr,
+			failures:  1,
+			wantReads: 2,
+		},
+		"retry is exhausted": {
+			err:       &googleapi.Error{Code: http.StatusInternalServerError},
+			failures:  3,
+			wantErr:   true,
+			wantReads: 3,
+		},
+		"Google API 403 is not retried": {
+			err:       &googleapi.Error{Code: http.StatusForbidden},
+			failures:  1,
+			wantErr:   true,
+			wantReads: 1,
+		},
+		"committed object without immutability is not retried": {
+			err:       goerr.Wrap(model.ErrImmutabilityNotSet, "fail to set immutability of blob").With("error", &azcore.ResponseError{StatusCode: http.StatusServiceUnavailable}),
+			failures:  1,
+			wantErr:   true,
+			wantReads: 1,
+		},
+		"unknown error is not retried": {
+			err:       errors.New("something wrong"),
+			failures:  1,
+			wantErr:   true,
+			wantReads: 1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gcsMock := &flakyGoogleCloudStorage{err: tc.err, failures: tc.failures}
+			s3Mock := &mockAmazonS3{}
+			store := newJobStore(t)
+			uc := usecase.New(adapter.New(
+				adapter.WithPolicy(policy),
+				adapter.WithGoogleCloudStorage(gcsMock),
+				adapter.WithAmazonS3(s3Mock),
+				adapter.WithJobStore(store),
+			), usecase.WithRetryPolicy(&model.RetryPolicy{
+				MaxAttempts: 3,
+				BaseBackoff: time.Millisecond,
+				MaxBackoff:  10 * time.Millisecond,
+				Jitter:      0.5,
+			}))
+
+			ctx := context.Background()
+			err := uc.Route(ctx, &model.RouteInput{
+				GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+					Object: model.GoogleCloudStorageObject{
+						Bucket: "nydus-src-bucket",
+						Name:   "blue.txt",
+					},
+				},
+			})
+			if tc.wantErr {
+				gt.Error(t, err)
+			} else {
+				gt.NoError(t, err)
+				gt.Equal(t, gcsMock.writes["nydus-dst-bucket/blue.txt"].String(), "timeless words")
+			}
+
+			// Source object is read again for each retry
+			gt.A(t, gcsMock.reads).Length(tc.wantReads)
+
+			// Succeeded destination is not transferred again
+			gt.Equal(t, s3Mock.newWriters, map[string]int{"ap-northeast-1/nydus-dst-bucket/blue.txt": 1})
+
+			jobs := gt.R1(store.ListJobs(ctx)).NoError(t)
+			gt.A(t, jobs).Length(1).At(0, func(t testing.TB, job *model.Job) {
+				gt.Equal(t, job.Transfers[0].Attempts, tc.wantReads)
+				gt.Equal(t, job.Transfers[1].Attempts, 1)
+				gt.Equal(t, job.Transfers[1].State, model.JobSucceeded)
+			})
+		})
+	}
+
+	t.Run("retry is given up when deadline comes before the next attempt", func(t *testing.T) {
+		gcsMock := &flakyGoogleCloudStorage{err: &googleapi.Error{Code: http.StatusServiceUnavailable}, failures: 3}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(&mockAmazonS3{}),
+		), usecase.WithRetryPolicy(&model.RetryPolicy{
+			MaxAttempts: 5,
+			BaseBackoff: time.Second,
+			MaxBackoff:  time.Second,
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		gt.Error(t, uc.Route(ctx, &model.RouteInput{
+			GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+				Object: model.GoogleCloudStorageObject{
+					Bucket: "nydus-src-bucket",
+					Name:   "blue.txt",
+				},
+			},
+		}))
+
+		// The first backoff exceeds the deadline, then the failure is responded without waiting for the deadline
+		gt.A(t, gcsMock.reads).Length(1)
+		gt.NoError(t, ctx.Err())
+	})
+}
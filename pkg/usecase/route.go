@@ -2,9 +2,10 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"io"
-	"os"
-	"strings"
+	"maps"
+	"time"
 
 	"github.com/m-mizutani/goerr"
 	"github.com/secmon-lab/nydus/pkg/adapter"
@@ -12,81 +13,305 @@ import (
 	"github.com/secmon-lab/nydus/pkg/domain/model"
 )
 
-func getEnv() map[string]string {
-	envMap := make(map[string]string)
-	for _, env := range os.Environ() {
-		parts := strings.SplitN(env, "=", 2)
-		if len(parts) == 2 {
-			envMap[parts[0]] = parts[1]
-		}
+// Evaluate queries the route policy with the input and returns destinations. It does not transfer the object.
+func (x *UseCase) Evaluate(ctx context.Context, input *model.RouteInput) ([]model.Destination, error) {
+	output, err := x.query(ctx, input)
+	if err != nil {
+		return nil, err
 	}
-	return envMap
+	return output.Destinations(), nil
 }
 
-func (x *UseCase) Route(ctx context.Context, input *model.RouteInput) (err error) {
-	input.Env = getEnv()
+func (x *UseCase) query(ctx context.Context, input *model.RouteInput) (*model.RouteOutput, error) {
+	// Policy may be reloaded during the query. Version must be of the client used for the query.
+	query, version := x.clients.Policy()
+	if query == nil {
+		return nil, goerr.New("route policy is not loaded")
+	}
+
+	input.Env = maps.Clone(x.env)
 	var output model.RouteOutput
 
-	logger := logging.From(ctx)
+	logger := logging.From(ctx).With("policy_version", version)
 	logger.Debug("Route query", "input", input)
-	if err := x.clients.Query().Query(ctx, "data.route", input, &output); err != nil {
-		return goerr.Wrap(err, "failed to route query").With("input", input)
+	if err := query.Query(ctx, "data.route", input, &output); err != nil {
+		return nil, goerr.Wrap(err, "failed to route query").With("input", input).With("policy_version", version)
 	}
 	logger.Info("Route query result", "input", input, "output", output)
 
-	for _, dst := range output.GoogleCloudStorage {
-		logger.Debug("Route to Google Cloud Storage", "destination", dst)
-		gcs := x.clients.GoogleCloudStorage()
-		if gcs == nil {
-			return goerr.New("Google Cloud Storage is not enabled").With("destination", dst).With("input", input)
+	return &output, nil
+}
+
+func (x *UseCase) Route(ctx context.Context, input *model.RouteInput) error {
+	if x.isDedupMarker(input) {
+		logging.From(ctx).Debug("Ignore dedup marker object", "source", input.Source())
+		return nil
+	}
+
+	dsts, err := x.Evaluate(ctx, input)
+	if err != nil {
+		return err
+	}
+	if len(dsts) == 0 {
+		return nil
+	}
+
+	return x.dispatch(ctx, input, dsts)
+}
+
+// dispatch records a new job to transfer the object of input to destinations, and performs or enqueues it
+func (x *UseCase) dispatch(ctx context.Context, input *model.RouteInput, dsts []model.Destination) error {
+	job := model.NewJob(input, dsts)
+	if store := x.clients.JobStore(); store != nil {
+		// The job must be recorded before responding to the event. Otherwise the event is lost if the process dies during transfer.
+		if err := store.PutJob(ctx, job); err != nil {
+			return goerr.Wrap(err, "failed to record transfer job").With("input", input)
 		}
+	}
 
-		r, err := newReaderFromRouteInput(ctx, x.clients, input)
-		if err != nil {
-			return goerr.Wrap(err, "failed to create reader from route input").With("input", input)
+	if x.queue != nil {
+		if err := x.queue.enqueue(ctx, job); err != nil {
+			// The event will be redelivered by upstream. The job is closed not to be resumed twice.
+			for _, t := range job.Transfers {
+				t.State = model.JobFailed
+				t.LastError = err.Error()
+			}
+			job.UpdateState()
+			x.saveJob(ctx, job)
+			return err
 		}
-		defer r.Close()
+		return nil
+	}
+
+	return x.execute(ctx, job)
+}
+
+// execute transfers the source object of the job to unfinished destinations and reports result of each destination. A destination that failed with retryable error is retried according to the retry policy, and the source object is read again for each retry. If ctx has deadline, retry is given up when the deadline comes before the next attempt, so that all attempts and backoffs end within the deadline.
+func (x *UseCase) execute(ctx context.Context, job *model.Job) error {
+	logger := logging.From(ctx).With("job_id", job.ID)
+
+	var errs []error
+	var failed []model.Destination
+	fail := func(t *model.JobTransfer, err error) {
+		t.State = model.JobFailed
+		t.LastError = err.Error()
+		errs = append(errs, err)
+		failed = append(failed, t.Destination)
+		x.sendDeadLetter(ctx, job, t, err)
+	}
+	// Transfers interrupted by shutdown are neither failed nor retried, but left pending in job store to be resumed
+	interrupt := func(t *model.JobTransfer, err error) {
+		logger.Warn("Transfer is interrupted by shutdown", "destination", t.Destination, "attempts", t.Attempts, "error", err)
+		t.State = model.JobPending
+		t.LastError = err.Error()
+	}
+
+	transfers := x.skipDuplicates(ctx, job, job.Unfinished())
+	total := len(transfers)
 
-		w, err := x.clients.GoogleCloudStorage().NewWriter(ctx, dst.Bucket, dst.Name)
-		if err != nil {
-			return goerr.Wrap(err, "failed to create writer to Google Cloud Storage").With("destination", dst)
+	// Existing destination objects are checked until the check succeeds, and not after the transfer is attempted. An object written by a failed attempt must not be regarded as existing.
+	source := &sourceStat{input: job.Input}
+	checked := map[*model.JobTransfer]bool{}
+
+	for attempt := 1; len(transfers) > 0; attempt++ {
+		var retries []*model.JobTransfer
+		backoff := x.retryPolicy.Backoff(attempt)
+		retryable := func(t *model.JobTransfer, err error) bool {
+			if attempt >= x.retryPolicy.MaxAttempts || !isRetryableError(err) {
+				return false
+			}
+			// Remaining time is checked after the attempt, then it includes time of the attempt as well as backoffs
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+				return false
+			}
+			t.State = model.JobPending
+			t.LastError = err.Error()
+			retries = append(retries, t)
+			return true
 		}
-		defer func() {
-			if err = w.Close(); err != nil {
-				logger.Warn("Failed to close writer", "destination", dst, "error", err)
+
+		var pending []*model.JobTransfer
+		for _, t := range transfers {
+			if checked[t] {
+				pending = append(pending, t)
+				continue
 			}
-		}()
 
-		n, err := io.Copy(w, r)
-		if err != nil {
-			return goerr.Wrap(err, "failed to copy from reader to writer")
+			skip, err := x.checkExisting(ctx, t.Destination, source)
+			switch {
+			case err != nil && isShutdown(ctx):
+				interrupt(t, err)
+			case err != nil:
+				t.Attempts++
+				if retryable(t, err) {
+					logger.Warn("Failed to check existing object, will retry", "destination", t.Destination, "attempts", t.Attempts, "error", err)
+					continue
+				}
+				fail(t, err)
+			case skip:
+				logger.Info("Skip transfer to existing object", "destination", t.Destination, "if_exists", t.Destination.IfExists())
+				t.State = model.JobSkipped
+			default:
+				checked[t] = true
+				pending = append(pending, t)
+			}
 		}
+		transfers = pending
+
+		if len(transfers) > 0 {
+			dsts := make([]model.Destination, len(transfers))
+			for i, t := range transfers {
+				t.State = model.JobRunning
+				t.Attempts++
+				dsts[i] = t.Destination
+			}
+			job.UpdateState()
+			x.saveJob(ctx, job)
+
+			for i, result := range x.transfer(ctx, job.Input, dsts, source) {
+				t := transfers[i]
+				t.Bytes = result.bytes
+
+				if result.err != nil && isShutdown(ctx) {
+					interrupt(t, result.err)
+					continue
+				}
+				if errors.Is(result.err, model.ErrSourceChanged) {
+					// The event is stale. The new object is transferred by its own event, then the transfer is not failed to stop redelivery of the event.
+					logger.Warn("Skip transfer of changed source object", "destination", result.dst, "attempts", t.Attempts, "error", result.err)
+					t.State = model.JobSkipped
+					t.LastError = result.err.Error()
+					continue
+				}
+				if result.err != nil {
+					if retryable(t, result.err) {
+						logger.Warn("Failed to transfer object, will retry", "destination", result.dst, "bytes", result.bytes, "attempts", t.Attempts, "error", result.err)
+						continue
+					}
+
+					logger.Warn("Failed to transfer object", "destination", result.dst, "bytes", result.bytes, "attempts", t.Attempts, "error", result.err)
+					fail(t, result.err)
+					continue
+				}
 
-		logger.Info("Copied from reader to writer", "destination", dst, "bytes", n)
+				logger.Info("Copied from reader to writer", "destination", result.dst, "bytes", result.bytes, "checksum", result.checksum, "attempts", t.Attempts)
+				t.State = model.JobSucceeded
+				t.LastError = ""
+				x.recordTransfer(ctx, job.Input, t.Destination)
+			}
+		}
+		job.UpdateState()
+		x.saveJob(ctx, job)
+
+		if len(retries) == 0 {
+			break
+		}
+
+		logger.Info("Wait before retrying transfer", "backoff", backoff, "destinations", len(retries), "attempts", attempt)
+		select {
+		case <-time.After(backoff):
+			transfers = retries
+		case <-ctx.Done():
+			for _, t := range retries {
+				if isShutdown(ctx) {
+					interrupt(t, context.Cause(ctx))
+					continue
+				}
+				fail(t, goerr.Wrap(ctx.Err(), "retry is canceled").With("destination", t.Destination))
+			}
+			job.UpdateState()
+			x.saveJob(ctx, job)
+			transfers = nil
+		}
+	}
+
+	if len(errs) > 0 {
+		return goerr.Wrap(errors.Join(errs...), "failed to transfer object").With("job_id", job.ID).With("failed", failed).With("succeeded", total-len(failed))
 	}
 
 	return nil
 }
 
+// saveJob records the job to job store if enabled. Failure of recording is logged but does not fail the transfer, because the transfer itself has been done. The job is recorded even if ctx is canceled, so that an interrupted job can be resumed.
+func (x *UseCase) saveJob(ctx context.Context, job *model.Job) {
+	store := x.clients.JobStore()
+	if store == nil {
+		return
+	}
+
+	if err := store.PutJob(context.WithoutCancel(ctx), job); err != nil {
+		logging.From(ctx).Error("Failed to record transfer job", "job_id", job.ID, "state", job.State, "error", err)
+	}
+}
+
+// sourceObjectAttrs returns attributes of source object to be written into destinations. Content headers and metadata are taken from the source object, or from the event if they can not be fetched. URI of the source object is added to metadata.
+func (x *UseCase) sourceObjectAttrs(ctx context.Context, source *sourceStat) *model.ObjectAttrs {
+	var attrs *model.ObjectAttrs
+	if stat, err := source.get(ctx, x.clients); err != nil {
+		logging.From(ctx).Warn("Failed to get attributes of source object, use attributes in the event", "error", err)
+		attrs = eventObjectAttrs(source.input)
+	} else {
+		attrs = stat.Attrs()
+	}
+
+	if src := source.input.Source(); src != nil {
+		attrs = attrs.Merge(&model.ObjectAttrs{
+			Metadata: map[string]string{model.MetadataSourceURI: src.String()},
+		})
+	}
+	return attrs
+}
+
+// eventObjectAttrs returns attributes of source object that are available in the event
+func eventObjectAttrs(input *model.RouteInput) *model.ObjectAttrs {
+	switch {
+	case input.AzureBlobStorage != nil:
+		return &model.ObjectAttrs{
+			ContentType: input.AzureBlobStorage.Object.ContentType,
+		}
+	case input.GoogleCloudStorage != nil:
+		return &model.ObjectAttrs{
+			ContentType: input.GoogleCloudStorage.Object.ContentType,
+			Metadata:    input.GoogleCloudStorage.Object.Metadata,
+		}
+	default:
+		return &model.ObjectAttrs{}
+	}
+}
+
+// newReaderFromRouteInput returns a reader of the source object of the version notified by the event. The latest object is read if the event has no version. Otherwise the read fails with model.ErrSourceChanged if the object has been overwritten after the event, because content of the new object does not match with the event.
 func newReaderFromRouteInput(ctx context.Context, clients *adapter.Clients, input *model.RouteInput) (io.ReadCloser, error) {
 	switch {
 	case input.AzureBlobStorage != nil:
+		if clients.AzureBlobStorage() == nil {
+			return nil, goerr.New("Azure Blob Storage is not enabled")
+		}
 		return clients.AzureBlobStorage().NewReader(ctx,
 			input.AzureBlobStorage.Object.StorageAccount,
 			input.AzureBlobStorage.Object.Container,
 			input.AzureBlobStorage.Object.BlobName,
+			input.AzureBlobStorage.Object.ETag,
 		)
 	case input.GoogleCloudStorage != nil:
+		if clients.GoogleCloudStorage() == nil {
+			return nil, goerr.New("Google Cloud Storage is not enabled")
+		}
 		return clients.GoogleCloudStorage().NewReader(ctx,
 			input.GoogleCloudStorage.Object.Bucket,
 			input.GoogleCloudStorage.Object.Name,
+			input.GoogleCloudStorage.Object.Generation,
 		)
 
 	case input.AmazonS3 != nil:
+		if clients.AmazonS3() == nil {
+			return nil, goerr.New("Amazon S3 is not enabled")
+		}
 		return clients.AmazonS3().NewReader(ctx,
 			input.AmazonS3.Object.Region,
 			input.AmazonS3.Object.Bucket,
 			input.AmazonS3.Object.Key,
+			input.AmazonS3.Object.VersionID,
+			input.AmazonS3.Object.ETag,
 		)
 	default:
 		return nil, goerr.New("unsupported route input")
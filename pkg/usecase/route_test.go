@@ -0,0 +1,349 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/context/logging"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+func TestRouteToAmazonS3(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+s3[dst] {
+	dst := {
+		"region": "us-east-1",
+		"bucket": "nydus-dst-bucket",
+		"key": sprintf("from-gcs/%s", [input.gcs.object.name]),
+	}
+}
+`,
+	}))).NoError(t)
+
+	gcsMock := &mockGoogleCloudStorage{}
+	s3Mock := &mockAmazonS3{}
+	uc := usecase.New(adapter.New(
+		adapter.WithPolicy(policy),
+		adapter.WithGoogleCloudStorage(gcsMock),
+		adapter.WithAmazonS3(s3Mock),
+	))
+
+	input := &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Object: model.GoogleCloudStorageObject{
+				Bucket: "nydus-src-bucket",
+				Name:   "blue.txt",
+			},
+		},
+	}
+	gt.NoError(t, uc.Route(context.Background(), input))
+
+	gt.A(t, gcsMock.reads).Length(1)
+	gt.M(t, s3Mock.writes).Length(1)
+	gt.Equal(t, s3Mock.writes["us-east-1/nydus-dst-bucket/from-gcs/blue.txt"].String(), "timeless words")
+}
+
+func TestRouteToDisabledStorage(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+s3[dst] {
+	dst := {
+		"region": "us-east-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	uc := usecase.New(adapter.New(
+		adapter.WithPolicy(policy),
+		adapter.WithGoogleCloudStorage(&mockGoogleCloudStorage{}),
+	))
+
+	input := &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Object: model.GoogleCloudStorageObject{
+				Bucket: "nydus-src-bucket",
+				Name:   "blue.txt",
+			},
+		},
+	}
+	gt.Error(t, uc.Route(context.Background(), input))
+}
+
+func TestRouteToAzureBlobStorage(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+abs[dst] {
+	dst := {
+		"storage_account": "nydusdst",
+		"container": "backup",
+		"blob_name": sprintf("from-gcs/%s", [input.gcs.object.name]),
+	}
+}
+`,
+	}))).NoError(t)
+
+	gcsMock := &mockGoogleCloudStorage{}
+	absMock := &mockAzureBlobStorage{}
+	uc := usecase.New(adapter.New(
+		adapter.WithPolicy(policy),
+		adapter.WithGoogleCloudStorage(gcsMock),
+		adapter.WithAzureBlobStorage(absMock),
+	))
+
+	input := &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Object: model.GoogleCloudStorageObject{
+				Bucket:      "nydus-src-bucket",
+				Name:        "blue.txt",
+				ContentType: "text/plain",
+				Metadata:    map[string]string{"owner": "blue"},
+			},
+		},
+	}
+	gt.NoError(t, uc.Route(context.Background(), input))
+
+	const key = "nydusdst/backup/from-gcs/blue.txt"
+	gt.Equal(t, absMock.writes[key].String(), "timeless words")
+	gt.Equal(t, absMock.attrs[key].ContentType, "text/plain")
+	gt.Equal(t, absMock.attrs[key].Metadata["owner"], "blue")
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) { return 0, errors.New("write failed") }
+func (failWriter) Close() error                { return nil }
+
+type failingAmazonS3 struct {
+	mockAmazonS3
+}
+
+func (x *failingAmazonS3) NewWriter(ctx context.Context, region, bucket, key string, attrs *model.ObjectAttrs, opts *model.AmazonS3WriteOptions) (io.WriteCloser, error) {
+	return failWriter{}, nil
+}
+
+func TestRouteFanOut(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	name := ["a.bin", "b.bin"][_]
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": name,
+	}
+}
+
+s3[dst] {
+	dst := {
+		"region": "us-east-1",
+		"bucket": "nydus-dst-bucket",
+		"key": "c.bin",
+	}
+}
+
+abs[dst] {
+	dst := {
+		"storage_account": "nydusdst",
+		"container": "backup",
+		"blob_name": "d.bin",
+	}
+}
+`,
+	}))).NoError(t)
+
+	input := func() *model.RouteInput {
+		return &model.RouteInput{
+			GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+				Object: model.GoogleCloudStorageObject{
+					Bucket: "nydus-src-bucket",
+					Name:   "src.bin",
+				},
+			},
+		}
+	}
+
+	// Larger than chunk size to be transferred in multiple chunks
+	data := make([]byte, 1024*1024+123)
+	gt.R1(rand.Read(data)).NoError(t)
+
+	t.Run("read once and write to all destinations", func(t *testing.T) {
+		gcsMock := &mockGoogleCloudStorage{data: data}
+		s3Mock := &mockAmazonS3{}
+		absMock := &mockAzureBlobStorage{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(s3Mock),
+			adapter.WithAzureBlobStorage(absMock),
+		))
+
+		gt.NoError(t, uc.Route(context.Background(), input()))
+
+		gt.A(t, gcsMock.reads).Length(1)
+		gt.True(t, bytes.Equal(gcsMock.writes["nydus-dst-bucket/a.bin"].Bytes(), data))
+		gt.True(t, bytes.Equal(gcsMock.writes["nydus-dst-bucket/b.bin"].Bytes(), data))
+		gt.True(t, bytes.Equal(s3Mock.writes["us-east-1/nydus-dst-bucket/c.bin"].Bytes(), data))
+		gt.True(t, bytes.Equal(absMock.writes["nydusdst/backup/d.bin"].Bytes(), data))
+	})
+
+	t.Run("failure of a destination does not affect others", func(t *testing.T) {
+		gcsMock := &mockGoogleCloudStorage{data: data}
+		absMock := &mockAzureBlobStorage{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(&failingAmazonS3{}),
+			adapter.WithAzureBlobStorage(absMock),
+		))
+
+		gt.Error(t, uc.Route(context.Background(), input()))
+
+		gt.A(t, gcsMock.reads).Length(1)
+		gt.True(t, bytes.Equal(gcsMock.writes["nydus-dst-bucket/a.bin"].Bytes(), data))
+		gt.True(t, bytes.Equal(gcsMock.writes["nydus-dst-bucket/b.bin"].Bytes(), data))
+		gt.True(t, bytes.Equal(absMock.writes["nydusdst/backup/d.bin"].Bytes(), data))
+	})
+}
+
+func TestRouteWithReloadedPolicy(t *testing.T) {
+	newPolicy := func(bucket string) *opac.Client {
+		return gt.R1(opac.New(opac.Data(map[string]string{
+			"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "` + bucket + `",
+		"name": input.gcs.object.name,
+	}
+}
+`,
+		}))).NoError(t)
+	}
+
+	mock := &mockGoogleCloudStorage{}
+	clients := adapter.New(
+		adapter.WithPolicy(newPolicy("nydus-dst-v1")),
+		adapter.WithGoogleCloudStorage(mock),
+	)
+	uc := usecase.New(clients)
+
+	input := func() *model.RouteInput {
+		return &model.RouteInput{
+			GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+				Object: model.GoogleCloudStorageObject{
+					Bucket: "nydus-src-bucket",
+					Name:   "blue.txt",
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+	gt.NoError(t, uc.Route(ctx, input()))
+
+	clients.SetPolicy(newPolicy("nydus-dst-v2"), "v2")
+	_, version := clients.Policy()
+	gt.Equal(t, version, "v2")
+	gt.NoError(t, uc.Route(ctx, input()))
+
+	gt.M(t, mock.writes).Length(2)
+	gt.Equal(t, mock.writes["nydus-dst-v1/blue.txt"].String(), "timeless words")
+	gt.Equal(t, mock.writes["nydus-dst-v2/blue.txt"].String(), "timeless words")
+}
+
+func TestRouteWithEnv(t *testing.T) {
+	policy := gt.R1(opac.New(opac.Data(map[string]string{
+		"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": input.env.NYDUS_ROUTE_BUCKET,
+		"name": input.gcs.object.name,
+	}
+}
+`,
+	}))).NoError(t)
+
+	t.Setenv("NYDUS_ROUTE_BUCKET", "nydus-dst-bucket")
+	t.Setenv("NYDUS_ROUTE_SECRET", "very-secret-value")
+	t.Setenv("NYDUS_AZURE_CLIENT_SECRET", "azure-client-secret")
+	t.Setenv("NYDUS_OTHER", "other")
+
+	newInput := func() *model.RouteInput {
+		return &model.RouteInput{
+			GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+				Object: model.GoogleCloudStorageObject{
+					Bucket: "nydus-src-bucket",
+					// Secret in event must be redacted in logs
+					Name: "very-secret-value.txt",
+				},
+			},
+		}
+	}
+
+	var logs bytes.Buffer
+	secrets := model.NewSecrets(os.Environ())
+	ctx := logging.Inject(context.Background(), slog.New(logging.NewRedactHandler(slog.NewJSONHandler(&logs, nil), secrets)))
+
+	t.Run("allowed variables are passed", func(t *testing.T) {
+		logs.Reset()
+		gcsMock := &mockGoogleCloudStorage{}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+		), usecase.WithEnvFilter(&model.EnvFilter{
+			Allow: []string{"NYDUS_ROUTE_*", "NYDUS_AZURE_CLIENT_SECRET"},
+		}))
+
+		input := newInput()
+		gt.NoError(t, uc.Route(ctx, input))
+		gt.Equal(t, gcsMock.writes["nydus-dst-bucket/very-secret-value.txt"].String(), "timeless words")
+
+		// Secrets are excluded even if allowed
+		gt.M(t, input.Env).Length(1)
+		gt.Equal(t, input.Env["NYDUS_ROUTE_BUCKET"], "nydus-dst-bucket")
+
+		gt.S(t, logs.String()).Contains("nydus-dst-bucket")
+		gt.S(t, logs.String()).NotContains("very-secret-value")
+		gt.S(t, logs.String()).Contains(model.Redacted)
+	})
+
+	t.Run("no variable is passed by default", func(t *testing.T) {
+		logs.Reset()
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(&mockGoogleCloudStorage{}),
+		))
+
+		input := newInput()
+		gt.NoError(t, uc.Route(ctx, input))
+		gt.M(t, input.Env).Length(0)
+	})
+
+	t.Run("secret in error values is redacted", func(t *testing.T) {
+		logs.Reset()
+		err := goerr.Wrap(errors.New("failed to read very-secret-value.txt"), "failed").With("input", newInput()).With("token", "short")
+		logging.From(ctx).Error("Failed", "error", err)
+		gt.S(t, logs.String()).NotContains("very-secret-value")
+		// Value of secret key is redacted regardless of its length
+		gt.S(t, logs.String()).NotContains("short")
+	})
+}
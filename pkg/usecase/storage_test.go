@@ -0,0 +1,176 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/m-mizutani/gt"
+	"github.com/m-mizutani/opac"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+	"github.com/secmon-lab/nydus/pkg/usecase"
+)
+
+func TestWriteOptions(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	t.Setenv("NYDUS_TEST_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(key))
+	t.Setenv("NYDUS_TEST_SHORT_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(key[:16]))
+	t.Setenv("NYDUS_TEST_PLAIN", base64.StdEncoding.EncodeToString(key))
+
+	input := &model.RouteInput{
+		GoogleCloudStorage: &model.GoogleCloudStorageEvent{
+			Object: model.GoogleCloudStorageObject{
+				Bucket: "nydus-src-bucket",
+				Name:   "blue.txt",
+			},
+		},
+	}
+
+	t.Run("options are passed to writers", func(t *testing.T) {
+		policy := gt.R1(opac.New(opac.Data(map[string]string{
+			"route.rego": `package route
+
+gcs[dst] {
+	dst := {
+		"bucket": "nydus-dst-bucket",
+		"name": input.gcs.object.name,
+		"storage_class": "COLDLINE",
+		"customer_key_env": "NYDUS_TEST_ENCRYPTION_KEY",
+		"retention": {"mode": "Unlocked", "days": 30},
+	}
+}
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+		"storage_class": "DEEP_ARCHIVE",
+		"kms_key_id": "alias/nydus",
+		"acl": "bucket-owner-full-control",
+		"retention": {"mode": "COMPLIANCE", "days": 365},
+		"legal_hold": true,
+		"tags": {"team": "blue"},
+	}
+}
+
+abs[dst] {
+	dst := {
+		"storage_account": "nydus-account",
+		"container": "nydus-container",
+		"blob_name": input.gcs.object.name,
+		"access_tier": "Cold",
+		"encryption_scope": "nydus-scope",
+		"tags": {"team": "blue"},
+	}
+}
+`,
+		}))).NoError(t)
+
+		gcsMock := &mockGoogleCloudStorage{}
+		s3Mock := &mockAmazonS3{}
+		absMock := &mockAzureBlobStorage{}
+		store := newJobStore(t)
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(gcsMock),
+			adapter.WithAmazonS3(s3Mock),
+			adapter.WithAzureBlobStorage(absMock),
+			adapter.WithJobStore(store),
+		))
+		ctx := context.Background()
+		gt.NoError(t, uc.Route(ctx, input))
+
+		gcsOpts := gcsMock.opts["nydus-dst-bucket/blue.txt"]
+		gt.Equal(t, gcsOpts.StorageClass, "COLDLINE")
+		gt.Equal(t, gcsOpts.CustomerKey, key)
+		gt.Equal(t, *gcsOpts.Retention, model.Retention{Mode: "Unlocked", Days: 30})
+
+		s3Opts := s3Mock.opts["ap-northeast-1/nydus-dst-bucket/blue.txt"]
+		gt.Equal(t, s3Opts.StorageClass, "DEEP_ARCHIVE")
+		gt.Equal(t, s3Opts.KMSKeyID, "alias/nydus")
+		gt.Equal(t, s3Opts.ACL, "bucket-owner-full-control")
+		gt.True(t, s3Opts.LegalHold)
+		gt.Equal(t, s3Opts.Tags, map[string]string{"team": "blue"})
+		gt.True(t, s3Opts.CustomerKey == nil)
+
+		absOpts := absMock.opts["nydus-account/nydus-container/blue.txt"]
+		gt.Equal(t, absOpts.AccessTier, "Cold")
+		gt.Equal(t, absOpts.EncryptionScope, "nydus-scope")
+
+		// Customer key is not recorded in the job
+		jobs := gt.R1(store.ListJobs(ctx)).NoError(t)
+		raw := gt.R1(json.Marshal(jobs)).NoError(t)
+		gt.S(t, string(raw)).Contains("NYDUS_TEST_ENCRYPTION_KEY")
+		gt.S(t, string(raw)).NotContains(base64.StdEncoding.EncodeToString(key))
+	})
+
+	t.Run("customer key is used to check existing object", func(t *testing.T) {
+		policy := gt.R1(opac.New(opac.Data(map[string]string{
+			"route.rego": `package route
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+		"customer_key_env": "NYDUS_TEST_ENCRYPTION_KEY",
+		"if_exists": "skip",
+	}
+}
+`,
+		}))).NoError(t)
+
+		s3Mock := &mockAmazonS3{writes: map[string]*bytes.Buffer{
+			"ap-northeast-1/nydus-dst-bucket/blue.txt": bytes.NewBufferString("old words"),
+		}}
+		uc := usecase.New(adapter.New(
+			adapter.WithPolicy(policy),
+			adapter.WithGoogleCloudStorage(&mockGoogleCloudStorage{}),
+			adapter.WithAmazonS3(s3Mock),
+		))
+		gt.NoError(t, uc.Route(context.Background(), input))
+
+		gt.Equal(t, s3Mock.writes["ap-northeast-1/nydus-dst-bucket/blue.txt"].String(), "old words")
+		gt.Equal(t, s3Mock.statKeys["ap-northeast-1/nydus-dst-bucket/blue.txt"], key)
+	})
+
+	testCases := map[string]string{
+		"invalid retention mode": `"retention": {"mode": "Locked", "days": 30}`,
+		"invalid retention days": `"retention": {"mode": "GOVERNANCE", "days": 0}`,
+		"invalid sse":            `"sse": "aws:unknown"`,
+		"customer key with sse":  `"sse": "AES256", "customer_key_env": "NYDUS_TEST_ENCRYPTION_KEY"`,
+		"not secret name":        `"customer_key_env": "NYDUS_TEST_PLAIN"`,
+		"customer key not set":   `"customer_key_env": "NYDUS_TEST_MISSING_ENCRYPTION_KEY"`,
+		"short customer key":     `"customer_key_env": "NYDUS_TEST_SHORT_ENCRYPTION_KEY"`,
+	}
+	for name, options := range testCases {
+		t.Run(name, func(t *testing.T) {
+			policy := gt.R1(opac.New(opac.Data(map[string]string{
+				"route.rego": `package route
+
+s3[dst] {
+	dst := {
+		"region": "ap-northeast-1",
+		"bucket": "nydus-dst-bucket",
+		"key": input.gcs.object.name,
+		` + options + `,
+	}
+}
+`,
+			}))).NoError(t)
+
+			s3Mock := &mockAmazonS3{}
+			uc := usecase.New(adapter.New(
+				adapter.WithPolicy(policy),
+				adapter.WithGoogleCloudStorage(&mockGoogleCloudStorage{}),
+				adapter.WithAmazonS3(s3Mock),
+			))
+			gt.Error(t, uc.Route(context.Background(), input))
+			gt.M(t, s3Mock.writes).Length(0)
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/m-mizutani/goerr"
+	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
+)
+
+// transferChunkSize is size of buffer to read source object. Memory usage of transfer is bounded by the chunk size regardless of object size, because the next chunk is not read until all destinations consume the current chunk.
+const transferChunkSize = 256 * 1024
+
+type transferResult struct {
+	dst   model.Destination
+	bytes int64
+	err   error
+
+	// checksum is computed from content read from the source. It is nil if reading source failed.
+	checksum *model.Checksum
+}
+
+// destinationStream is a stream from source object to a destination writer
+type destinationStream struct {
+	result *transferResult
+	pw     *io.PipeWriter
+	done   chan struct{}
+}
+
+// transfer reads the source object once and writes it to all destinations concurrently with attributes of the source object overridden by each destination. It returns the result of each destination in the same order as dsts. If checksum verification is enabled, content that does not match with size and checksums declared by the source is not committed to any destination.
+func (x *UseCase) transfer(ctx context.Context, input *model.RouteInput, dsts []model.Destination, source *sourceStat) []*transferResult {
+	results := make([]*transferResult, len(dsts))
+	for i, dst := range dsts {
+		results[i] = &transferResult{dst: dst}
+	}
+
+	r, err := newReaderFromRouteInput(ctx, x.clients, input)
+	if err != nil {
+		for _, result := range results {
+			result.err = goerr.Wrap(err, "failed to create reader from route input")
+		}
+		return results
+	}
+	defer r.Close()
+
+	srcAttrs := x.sourceObjectAttrs(ctx, source)
+	declared := x.declaredChecksum(ctx, source)
+
+	var streams []*destinationStream
+	for _, result := range results {
+		attrs := srcAttrs.Merge(result.dst.Attributes())
+		if x.verifyChecksum {
+			// Pass declared checksums to storage that supports server-side verification
+			attrs.MD5 = declared.MD5
+			attrs.CRC32C = declared.CRC32C
+		}
+
+		// Cancel the context to abort upload without committing partial object
+		dstCtx, cancel := context.WithCancel(ctx)
+
+		w, err := newWriterFromDestination(dstCtx, x.clients, result.dst, attrs)
+		if err != nil {
+			cancel()
+			result.err = err
+			continue
+		}
+
+		pr, pw := io.Pipe()
+		stream := &destinationStream{
+			result: result,
+			pw:     pw,
+			done:   make(chan struct{}),
+		}
+		streams = append(streams, stream)
+
+		go func() {
+			defer close(stream.done)
+			defer cancel()
+
+			n, err := io.Copy(w, pr)
+			stream.result.bytes = n
+			if err != nil {
+				_ = pr.CloseWithError(err)
+				cancel()
+				_ = w.Close()
+				stream.result.err = goerr.Wrap(err, "failed to copy to destination").With("destination", stream.result.dst)
+				return
+			}
+
+			if err := w.Close(); err != nil {
+				stream.result.err = goerr.Wrap(err, "failed to close writer").With("destination", stream.result.dst)
+			}
+		}()
+	}
+
+	if len(streams) > 0 {
+		cr := newChecksumReader(r)
+		err := fanout(cr, streams)
+		if err == nil {
+			checksum := cr.Checksum()
+			for _, result := range results {
+				result.checksum = checksum
+			}
+			if x.verifyChecksum {
+				// Writers are aborted by closing pipes with error, then partial or corrupted content is not committed
+				err = declared.Verify(checksum)
+			}
+		}
+
+		if err != nil {
+			for _, stream := range streams {
+				_ = stream.pw.CloseWithError(err)
+			}
+		} else {
+			for _, stream := range streams {
+				_ = stream.pw.Close()
+			}
+		}
+
+		for _, stream := range streams {
+			<-stream.done
+		}
+	}
+
+	return results
+}
+
+// fanout reads chunks from r and writes each chunk to all streams concurrently. A stream that fails to write is excluded from following writes. It returns error only when reading source fails.
+func fanout(r io.Reader, streams []*destinationStream) error {
+	buf := make([]byte, transferChunkSize)
+	failed := make([]bool, len(streams))
+
+	for {
+		// Stop reading source if all destinations failed
+		if !slices.Contains(failed, false) {
+			return nil
+		}
+
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			var wg sync.WaitGroup
+			for i, stream := range streams {
+				if failed[i] {
+					continue
+				}
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := stream.pw.Write(buf[:n]); err != nil {
+						failed[i] = true
+					}
+				}()
+			}
+			wg.Wait()
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return goerr.Wrap(readErr, "failed to read source object")
+		}
+	}
+}
+
+// writeObject writes raw as an object of dst. The object is not committed if writing fails.
+func writeObject(ctx context.Context, clients *adapter.Clients, dst model.Destination, attrs *model.ObjectAttrs, raw []byte) error {
+	// Cancel the context to abort upload without committing partial object
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w, err := newWriterFromDestination(ctx, clients, dst, attrs)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		cancel()
+		_ = w.Close()
+		return goerr.Wrap(err, "failed to write object").With("destination", dst)
+	}
+	if err := w.Close(); err != nil {
+		return goerr.Wrap(err, "failed to close writer").With("destination", dst)
+	}
+	return nil
+}
+
+func newWriterFromDestination(ctx context.Context, clients *adapter.Clients, dst model.Destination, attrs *model.ObjectAttrs) (io.WriteCloser, error) {
+	switch {
+	case dst.GoogleCloudStorage != nil:
+		if clients.GoogleCloudStorage() == nil {
+			return nil, goerr.New("Google Cloud Storage is not enabled").With("destination", dst)
+		}
+		opts := dst.GoogleCloudStorage.GoogleCloudStorageWriteOptions
+		if err := opts.Validate(); err != nil {
+			return nil, goerr.Wrap(err, "invalid destination").With("destination", dst)
+		}
+		key, err := loadCustomerKey(opts.CustomerKeyEnv)
+		if err != nil {
+			return nil, goerr.Wrap(err, "invalid destination").With("destination", dst)
+		}
+		opts.CustomerKey = key
+
+		w, err := clients.GoogleCloudStorage().NewWriter(ctx, dst.GoogleCloudStorage.Bucket, dst.GoogleCloudStorage.Name, attrs.ForStorage(model.GoogleCloudStorage), &opts)
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to create writer to Google Cloud Storage").With("destination", dst)
+		}
+		return w, nil
+
+	case dst.AmazonS3 != nil:
+		if clients.AmazonS3() == nil {
+			return nil, goerr.New("Amazon S3 is not enabled").With("destination", dst)
+		}
+		opts := dst.AmazonS3.AmazonS3WriteOptions
+		if err := opts.Validate(); err != nil {
+			return nil, goerr.Wrap(err, "invalid destination").With("destination", dst)
+		}
+		key, err := loadCustomerKey(opts.CustomerKeyEnv)
+		if err != nil {
+			return nil, goerr.Wrap(err, "invalid destination").With("destination", dst)
+		}
+		opts.CustomerKey = key
+
+		w, err := clients.AmazonS3().NewWriter(ctx, dst.AmazonS3.Region, dst.AmazonS3.Bucket, dst.AmazonS3.Key, attrs.ForStorage(model.S3Storage), &opts)
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to create writer to Amazon S3").With("destination", dst)
+		}
+		return w, nil
+
+	case dst.AzureBlobStorage != nil:
+		if clients.AzureBlobStorage() == nil {
+			return nil, goerr.New("Azure Blob Storage is not enabled").With("destination", dst)
+		}
+		opts := dst.AzureBlobStorage.AzureBlobStorageWriteOptions
+		if err := opts.Validate(); err != nil {
+			return nil, goerr.Wrap(err, "invalid destination").With("destination", dst)
+		}
+		key, err := loadCustomerKey(opts.CustomerKeyEnv)
+		if err != nil {
+			return nil, goerr.Wrap(err, "invalid destination").With("destination", dst)
+		}
+		opts.CustomerKey = key
+
+		w, err := clients.AzureBlobStorage().NewWriter(ctx, dst.AzureBlobStorage.StorageAccount, dst.AzureBlobStorage.Container, dst.AzureBlobStorage.BlobName, attrs.ForStorage(model.AzureBlobStorage), &opts)
+		if err != nil {
+			return nil, goerr.Wrap(err, "failed to create writer to Azure Blob Storage").With("destination", dst)
+		}
+		return w, nil
+
+	default:
+		return nil, goerr.New("unsupported destination")
+	}
+}
+
+// customerKeySize is size of customer-supplied encryption key, that is 256 bit AES key
+const customerKeySize = 32
+
+// loadCustomerKey reads base64 encoded customer-supplied encryption key from the environment variable. The variable name must look like a secret, such as "BACKUP_ENCRYPTION_KEY", so that the key is redacted from logs and not passed to route policy. It returns nil if env is empty.
+func loadCustomerKey(env string) ([]byte, error) {
+	if env == "" {
+		return nil, nil
+	}
+	if !model.IsSecretName(env) {
+		return nil, goerr.New("name of customer key variable must contain ENCRYPTION_KEY, CUSTOMER_KEY or other secret keyword").With("env", env)
+	}
+
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return nil, goerr.New("customer key variable is not set").With("env", env)
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != customerKeySize {
+		return nil, goerr.New("customer key must be base64 encoded 256 bit key").With("env", env)
+	}
+	return key, nil
+}
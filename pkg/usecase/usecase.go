@@ -1,15 +1,88 @@
 package usecase
 
 import (
+	"context"
+	"os"
+	"sync"
+
 	"github.com/secmon-lab/nydus/pkg/adapter"
+	"github.com/secmon-lab/nydus/pkg/domain/interfaces"
+	"github.com/secmon-lab/nydus/pkg/domain/model"
 )
 
 type UseCase struct {
-	clients *adapter.Clients
+	clients  *adapter.Clients
+	snsCerts *snsCertCache
+
+	// env is environment variables passed to route policy as input.env
+	env map[string]string
+
+	googlePubSubAuth *model.GooglePubSubAuth
+	googleJWKS       *jwksCache
+
+	azureEventGridAuth *model.AzureEventGridAuth
+	azureJWKS          *jwksCache
+
+	retryPolicy    *model.RetryPolicy
+	deadLetter     *model.DeadLetterLocation
+	verifyChecksum bool
+
+	policyBundle *policyBundle
+
+	dedup interfaces.DedupStore
+
+	queue    *transferQueue
+	resuming sync.WaitGroup
+
+	// jobCtx is context of queued and resumed jobs that run beyond requests. It is canceled by Shutdown.
+	jobCtx     context.Context
+	cancelJobs context.CancelCauseFunc
+}
+
+type Option func(*UseCase)
+
+func New(clients *adapter.Clients, options ...Option) *UseCase {
+	uc := &UseCase{
+		clients:    clients,
+		snsCerts:   newSNSCertCache(),
+		env:        map[string]string{},
+		googleJWKS: newJWKSCache(),
+		azureJWKS:  newJWKSCache(),
+		retryPolicy: &model.RetryPolicy{
+			MaxAttempts: 1,
+		},
+		verifyChecksum: true,
+	}
+
+	for _, opt := range options {
+		opt(uc)
+	}
+
+	uc.jobCtx, uc.cancelJobs = context.WithCancelCause(context.Background())
+	if uc.queue != nil {
+		uc.queue.start(uc)
+	}
+
+	return uc
+}
+
+// WithEnvFilter passes environment variables selected by the filter to route policy. No variable is passed by default.
+func WithEnvFilter(filter *model.EnvFilter) Option {
+	return func(uc *UseCase) {
+		uc.env = filter.Filter(os.Environ())
+	}
+}
+
+// WithGooglePubSubAuth enables verification of OIDC token attached to Pub/Sub push request
+func WithGooglePubSubAuth(auth *model.GooglePubSubAuth) Option {
+	return func(uc *UseCase) {
+		uc.googlePubSubAuth = auth
+	}
 }
 
-func New(clients *adapter.Clients) *UseCase {
-	return &UseCase{
-		clients: clients,
+// WithAzureEventGridAuth enables authentication of Azure Event Grid webhook delivery
+func WithAzureEventGridAuth(auth *model.AzureEventGridAuth) Option {
+	return func(uc *UseCase) {
+		uc.azureEventGridAuth = auth
 	}
 }